@@ -1,18 +1,25 @@
 package config
 
+import "fmt"
+
 // Struktura koja se podudara sa JSON strukturom
 type Config struct {
 	// Memtable
-	MaxMemtableSize  int    `json:"MaxMemtableSize"`
-	MemtableNum      int    `json:"MemtableNum"`
-	MemtableStruct   string `json:"MemtableStruct"`
-	SkipListLevelNum int    `json:"SkipListLevelNum"`
-	BTreeDegree      int    `json:"BTreeDegree"`
+	MaxMemtableSize       int    `json:"MaxMemtableSize"`
+	MaxMemtableBytes      int    `json:"MaxMemtableBytes"`
+	MaxImmutableMemtables int    `json:"MaxImmutableMemtables"`
+	MemtableStruct        string `json:"MemtableStruct"`
+	SkipListLevelNum      int    `json:"SkipListLevelNum"`
+	SkipListArenaBytes    int    `json:"SkipListArenaBytes"`
+	BTreeDegree           int    `json:"BTreeDegree"`
 
 	// Block Manager and Block Cache
-	BlockSize      int `json:"BlockSize"`
-	BlockCacheSize int `json:"BlockCacheSize"`
-	LRUCacheSize   int `json:"LRUCacheSize"`
+	BlockSize        int    `json:"BlockSize"`
+	BlockCacheSize   int    `json:"BlockCacheSize"`
+	BlockCachePolicy string `json:"BlockCachePolicy"`
+	RowCacheBytes    int64  `json:"RowCacheBytes"`
+	RowCachePolicy   string `json:"RowCachePolicy"`
+	TableCacheSize   int    `json:"TableCacheSize"`
 
 	// Access Control
 	TokenRate     int `json:"TokenRate"`
@@ -27,7 +34,40 @@ type Config struct {
 	SSTableSingleFile  bool `json:"SSTableSingleFile"`
 	SSTableCompression bool `json:"SSTableCompression"`
 
+	// Bloom filter
+	BloomFPRates    []float64 `json:"BloomFPRates"`
+	BloomBitsPerKey int       `json:"BloomBitsPerKey"`
+	PrefixExtractor string    `json:"PrefixExtractor"`
+	PrefixLength    int       `json:"PrefixLength"`
+	PrefixDelimiter string    `json:"PrefixDelimiter"`
+
+	// Filter opsega
+	RangeFilterPrefixLength int `json:"RangeFilterPrefixLength"`
+
+	// Value log
+	ValueLogThreshold   int   `json:"ValueLogThreshold"`
+	ValueLogSegmentSize int64 `json:"ValueLogSegmentSize"`
+
 	// Compactions
-	CompactionAlgorithm string `json:"CompactionAlgorithm"`
-	MaxCountInLevel     int    `json:"MaxCountInLevel"`
+	CompactionAlgorithm    string `json:"CompactionAlgorithm"`
+	MaxCountInLevel        int    `json:"MaxCountInLevel"`
+	MaxSSTableSize         int64  `json:"MaxSSTableSize"`
+	LevelBaseBytes         int64  `json:"LevelBaseBytes"`
+	LevelSizeMultiplier    int    `json:"LevelSizeMultiplier"`
+	CompactionVictimPolicy string `json:"CompactionVictimPolicy"`
+	TimeWindowSize         int    `json:"TimeWindowSize"`
+	HybridTieredLevels     int    `json:"HybridTieredLevels"`
+}
+
+// Validate proverava parametre koji bi inače tiho napravili neispravne strukture
+func (cfg Config) Validate() error {
+	for i, rate := range cfg.BloomFPRates {
+		if !(rate > 0 && rate < 1) {
+			return fmt.Errorf("BloomFPRates[%d] = %v mora biti u opsegu (0, 1)", i, rate)
+		}
+	}
+	if cfg.BloomBitsPerKey < 0 {
+		return fmt.Errorf("BloomBitsPerKey = %d ne sme biti negativan", cfg.BloomBitsPerKey)
+	}
+	return nil
 }
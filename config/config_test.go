@@ -0,0 +1,23 @@
+package config
+
+import "testing"
+
+func TestValidateBloomFilter(t *testing.T) {
+	for _, tc := range []struct {
+		rates      []float64
+		bitsPerKey int
+		valid      bool
+	}{
+		{[]float64{0.05, 0.01}, 0, true},
+		{nil, 10, true},
+		{[]float64{0.01, 0}, 0, false},
+		{[]float64{1}, 0, false},
+		{[]float64{-0.5}, 0, false},
+		{[]float64{0.01}, -1, false},
+	} {
+		err := Config{BloomFPRates: tc.rates, BloomBitsPerKey: tc.bitsPerKey}.Validate()
+		if (err == nil) != tc.valid {
+			t.Fatalf("BloomFPRates %v, BloomBitsPerKey %d: greška %v", tc.rates, tc.bitsPerKey, err)
+		}
+	}
+}
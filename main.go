@@ -2,7 +2,6 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -10,6 +9,7 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -44,42 +44,58 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Neispravna konfiguracija: %v", err)
+	}
 
 	// -------------------------------------------------------------------------------------------------------------------------------
 	// Block Manager i Block Cache
 	// -------------------------------------------------------------------------------------------------------------------------------
 
-	// Inicijalizacija LRU keša
-	lru := lrucache.NewLRUCache(cfg.LRUCacheSize)
+	// Inicijalizacija keša redova (rezultata čitanja sa diska)
+	rowCache := lrucache.NewRowCache(cfg.RowCacheBytes, cfg.RowCachePolicy)
 
 	// Inicijalizacija globalnog BlockManager
-	bm := blockmanager.NewBlockManager(cfg.BlockSize, cfg.BlockCacheSize)
+	bm := blockmanager.NewBlockManagerWithPolicy(cfg.BlockSize, cfg.BlockCacheSize, cfg.BlockCachePolicy)
 
 	// -------------------------------------------------------------------------------------------------------------------------------
 	// Memtabable
 	// -------------------------------------------------------------------------------------------------------------------------------
 
-	// Niz memtable instanci
-	memtableInstances := make([]memtable.MemtableInterface, cfg.MemtableNum)
-	mtIndex := 0
-
-	// Inicijalizacija niza instanci Memtable-a
+	// Konstruktor Memtable-a izabrane strukture
+	var newMemtable func() memtable.MemtableInterface
 	switch cfg.MemtableStruct {
 	case "hashMap":
-		for i := 0; i < cfg.MemtableNum; i++ {
-			memtableInstances[i] = containers.NewHashMapMemtable(cfg.MaxMemtableSize)
+		newMemtable = func() memtable.MemtableInterface {
+			return containers.NewHashMapMemtable(cfg.MaxMemtableSize, cfg.MaxMemtableBytes)
 		}
 	case "skipList":
-		for i := 0; i < cfg.MemtableNum; i++ {
-			memtableInstances[i] = containers.NewSkipListMemtable(cfg.SkipListLevelNum, cfg.MaxMemtableSize)
+		newMemtable = func() memtable.MemtableInterface {
+			return containers.NewSkipListMemtable(cfg.SkipListLevelNum, cfg.MaxMemtableSize, cfg.MaxMemtableBytes)
 		}
 
 	case "BTree":
-		for i := 0; i < cfg.MemtableNum; i++ {
-			memtableInstances[i] = containers.NewBTreeMemtable(cfg.MaxMemtableSize, cfg.BTreeDegree)
+		newMemtable = func() memtable.MemtableInterface {
+			return containers.NewBTreeMemtable(cfg.MaxMemtableSize, cfg.MaxMemtableBytes, cfg.BTreeDegree)
+		}
+
+	case "ART":
+		newMemtable = func() memtable.MemtableInterface {
+			return containers.NewARTMemtable(cfg.MaxMemtableSize, cfg.MaxMemtableBytes)
+		}
+
+	case "arenaSkipList":
+		newMemtable = func() memtable.MemtableInterface {
+			return containers.NewArenaSkipListMemtable(cfg.SkipListLevelNum, cfg.MaxMemtableSize, cfg.MaxMemtableBytes, cfg.SkipListArenaBytes)
 		}
+
+	default:
+		log.Fatalf("Nepoznata struktura Memtable-a: %s", cfg.MemtableStruct)
 	}
 
+	// Aktivni Memtable i red punih (nepromenljivih) Memtable-a koji čekaju upis na disk
+	memtables := memtable.NewMemtables(newMemtable, cfg.MaxImmutableMemtables)
+
 	// -------------------------------------------------------------------------------------------------------------------------------
 	// WAL (Write Ahead Log)
 	// -------------------------------------------------------------------------------------------------------------------------------
@@ -120,23 +136,22 @@ func main() {
 				continue
 			}
 			for _, rec := range records {
-				// Dodavanje zapisa u memtable
-				memtableInstances[mtIndex].Add(rec.Timestamp, rec.Tombstone, string(rec.Key), rec.Value)
-
-				// Postavljanje watermarka za svaki Memtable
-				memtableInstances[mtIndex].SetWatermark(i)
+				// Brisanje opsega se primenjuje na sve Memtable-e, a u trenutni ide tombstone početnog ključa
+				if rec.RangeTombstone {
+					utils.ApplyRangeTombstone(rec.Timestamp, string(rec.Key), string(rec.Value), memtables.All())
+				}
 
-				// Provera da li je trenutni Memtable pun
-				if memtableInstances[mtIndex].IsFull() {
-					mtIndex = (mtIndex + 1) % cfg.MemtableNum
+				// Dodavanje zapisa u memtable (uz watermark); pun Memtable čeka flush do pokretanja flusher-a.
+				// Brisanje se vraća kao i DELETE, bez vrednosti koja je upisana u WAL uz tombstone.
+				if rec.Tombstone {
+					memtables.Delete(rec.Timestamp, string(rec.Key), i)
+				} else {
+					memtables.Add(rec.Timestamp, rec.Tombstone, string(rec.Key), rec.Value, i)
 				}
 			}
 		}
 	}
 
-	// Čuvanje prvog slobodnog indeksa za Token Bucket
-	tokenIndex := mtIndex
-
 	// -------------------------------------------------------------------------------------------------------------------------------
 	// SSTable i LSM stablo
 	// -------------------------------------------------------------------------------------------------------------------------------
@@ -146,6 +161,7 @@ func main() {
 
 	// Putanja do fajla sa globalnim recnikom kompresije
 	dictPath := filepath.Join("data", "dictionary.db")
+	vlogDir := filepath.Join("data", "vlog")
 
 	// Globalni rečnik za SSTable
 	dict := sstable.NewDictionaryWithThreshold(cfg.BlockSize)
@@ -164,6 +180,29 @@ func main() {
 		lsm[0] = make([]string, 0)
 	}
 
+	// Value log za velike vrednosti (SSTabele čuvaju samo pokazivače na njih)
+	valueLog, err := sstable.OpenValueLog(vlogDir, bm, cfg.BlockSize, cfg.ValueLogThreshold, cfg.ValueLogSegmentSize)
+	if err != nil {
+		log.Fatalf("Greška pri otvaranju value log-a: %v", err)
+	}
+
+	// Keš otvorenih SSTabela (header, summary i Bloom filter ostaju u memoriji)
+	tableCache := sstable.NewTableCache(cfg.TableCacheSize, bm, cfg.BlockSize, valueLog)
+
+	// Strategija kompakcije izabrana u konfiguraciji
+	strategy, err := sstable.NewCompactionStrategy(cfg, bm, sstableDir, dict, dictPath, tableCache)
+	if err != nil {
+		log.Fatalf("Greška pri izboru strategije kompakcije: %v", err)
+	}
+
+	// Flusher upisuje nepromenljive Memtable-e na disk u pozadini. Glavna petlja drži zaključavanje
+	// dok izvršava komandu i otpušta ga dok čeka unos; flusher upisuje SSTabelu bez zaključavanja,
+	// a uzima ga samo da bi je objavio u LSM stablu, pa se LSM stablo ne menja tokom komande.
+	memtables.Lock()
+	memtables.StartFlusher(func(mt memtable.MemtableInterface) (func(), error) {
+		return utils.FlushMemtable(mt, walInstance, sstableDir, bm, &lsm, cfg, dict, dictPath, strategy, rowCache, valueLog)
+	})
+
 	// -------------------------------------------------------------------------------------------------------------------------------
 	// Interfejs petlja
 	// -------------------------------------------------------------------------------------------------------------------------------
@@ -177,10 +216,16 @@ func main() {
 	for {
 		fmt.Print("> ")
 
-		// Citanje linije iz inputa
-		if !scanner.Scan() {
+		// Citanje linije iz inputa (flusher radi dok se čeka unos)
+		memtables.Unlock()
+		scanned := scanner.Scan()
+		memtables.Lock()
+		if !scanned {
 			break
 		}
+
+		// Memtable-i od najnovijeg ka najstarijem
+		memtableInstances := memtables.All()
 		input := strings.TrimSpace(scanner.Text())
 
 		// Podela komande na delove
@@ -198,16 +243,22 @@ func main() {
 		command := strings.ToUpper(parts[0])
 
 		// Kontrola pristupa
-		// Token bucket će se uvek čuvati u prvoj slobodnoj memtabeli pri pokretanju
+		// Token bucket se čuva u Memtable-ima: čita se najnoviji, a upisuje u aktivni
 		if utils.CommandsWithTokens[command] {
-			bucket, _, ok := memtableInstances[tokenIndex].Get("__sys__TOKEN_BUCKET")
+			var bucket []byte
+			ok := false
+			for _, mt := range memtableInstances {
+				if bucket, _, ok = mt.Get("__sys__TOKEN_BUCKET"); ok {
+					break
+				}
+			}
 			if !ok {
 				newtimestamp := uint64(time.Now().Unix())
 				newtokens := uint8(cfg.TokenRate)
 				newbucket := make([]byte, 0)
 				newbucket = binary.BigEndian.AppendUint64(newbucket, newtimestamp)
 				newbucket = append(newbucket, newtokens)
-				memtableInstances[tokenIndex].Add([16]byte{}, false, "__sys__TOKEN_BUCKET", newbucket)
+				memtables.Active().Add([16]byte{}, false, "__sys__TOKEN_BUCKET", newbucket)
 				bucket = newbucket
 			}
 			timestamp := binary.BigEndian.Uint64(bucket[0:8])
@@ -225,7 +276,7 @@ func main() {
 				bucket = binary.BigEndian.AppendUint64(bucket, timestamp)
 				bucket = append(bucket, tokens)
 			}
-			memtableInstances[tokenIndex].Add([16]byte{}, false, "__sys__TOKEN_BUCKET", bucket)
+			memtables.Active().Add([16]byte{}, false, "__sys__TOKEN_BUCKET", bucket)
 		}
 
 		// --------------------------------------------------------------------------------------------------------------------------
@@ -251,26 +302,13 @@ func main() {
 			value := []byte(parts[2])
 			tombstone := false
 
-			// Izmena LRU keša (ukoliko je potrebno)
-			_, exists := lru.CheckCache(parts[1])
-			if exists {
-				lru.UpdateCache(parts[1], value)
-			}
-
 			// Dodavanje zapisa u Write-Ahead Log (WAL)
 			ts, err := walInstance.AppendRecord(tombstone, key, value)
 			if err != nil {
 				// Ako dodje do greske prilikom upisa u WAL, ispisuje se poruka o gresci
 				fmt.Printf("Greška pri pisanju u WAL: %v\n", err)
 			} else {
-				sstrecords := utils.WriteToMemory(ts, tombstone, parts[1], value, bm, &memtableInstances, &mtIndex, walInstance, cfg.MemtableNum)
-
-				if sstrecords != nil {
-					err := utils.WriteToDisk(sstrecords, sstableDir, bm, &lsm, cfg, dict, dictPath)
-					if err != nil {
-						fmt.Printf("Greška pri kreiranju SSTable: %v\n", err)
-					}
-				}
+				utils.WriteToMemory(ts, tombstone, parts[1], value, memtables, walInstance, rowCache)
 			}
 
 		// --------------------------------------------------------------------------------------------------------------------------
@@ -295,22 +333,36 @@ func main() {
 			var value []byte
 			var record *sstable.Record
 
-			// Pretrazi Memtable
-			for i := 0; i < cfg.MemtableNum; i++ {
+			// Pretrazi Memtable-e od najnovijeg; prvi koji sadrži ključ ima njegovu najnoviju verziju
+			for i := range memtableInstances {
 				value, deleted, found = memtableInstances[i].Get(key)
-				if found && !deleted {
-					fmt.Printf("Pronađena vrednost: [%s -> %s]\n", utils.MaybeQuote(key), utils.MaybeQuote(string(value)))
+				if found {
 					break
 				}
 			}
+			if found && !deleted {
+				fmt.Printf("Pronađena vrednost: [%s -> %s]\n", utils.MaybeQuote(key), utils.MaybeQuote(string(value)))
+				continue
+			}
 			if found {
+				fmt.Printf("Nije pronadjena vrednost za kljuc: [%s]\n", utils.MaybeQuote(key))
 				continue
 			}
 
-			// Pretrazi Cache
-			value, found = lru.CheckCache(key)
-			if found {
-				fmt.Printf("Pronađena vrednost: [%s -> %s]\n", utils.MaybeQuote(key), utils.MaybeQuote(string(value)))
+			// Ključ obrisan brisanjem opsega iz Memtable-a ne postoji ni na disku
+			if utils.DeletedByRange(key, memtableInstances) {
+				fmt.Printf("Nije pronadjena vrednost za kljuc: [%s]\n", utils.MaybeQuote(key))
+				continue
+			}
+
+			// Pretrazi Cache (keširan može biti i podatak da ključ ne postoji)
+			value, exists, cached := rowCache.Get(key)
+			if cached {
+				if exists {
+					fmt.Printf("Pronađena vrednost: [%s -> %s]\n", utils.MaybeQuote(key), utils.MaybeQuote(string(value)))
+				} else {
+					fmt.Printf("Nije pronadjena vrednost za kljuc: [%s]\n", utils.MaybeQuote(key))
+				}
 				continue
 			}
 
@@ -321,10 +373,12 @@ func main() {
 					maxLevel = level
 				}
 			}
-			record = utils.ReadFromDisk(key, maxLevel, lsm, cfg, bm, dict)
-			if record != nil {
+			record = utils.ReadFromDisk(key, maxLevel, lsm, cfg, bm, dict, tableCache)
+			if record == nil {
+				rowCache.PutMissing(key)
+			} else {
 				found = true
-				lru.UpdateCache(string(record.Key), record.Value)
+				rowCache.Put(key, record.Value)
 				fmt.Printf("Pronađena vrednost: [%s -> %s]\n", utils.MaybeQuote(string(record.Key)), utils.MaybeQuote(string(record.Value)))
 			}
 			if found {
@@ -335,6 +389,32 @@ func main() {
 				fmt.Printf("Nije pronadjena vrednost za kljuc: [%s]\n", utils.MaybeQuote(key))
 			}
 
+		// --------------------------------------------------------------------------------------------------------------------------
+		// MGET komanda
+		// --------------------------------------------------------------------------------------------------------------------------
+
+		// MGET komanda ocekuje jedan ili više ključeva
+		case "MGET":
+			if len(parts) < 2 {
+				fmt.Println("Greška: MGET zahteva <ključ> [<ključ> ...]")
+				continue
+			}
+			keys := parts[1:]
+			if slices.ContainsFunc(keys, func(k string) bool { return strings.HasPrefix(k, "__sys__") }) {
+				fmt.Println("Zabranjena operacija nad internim ključevima.")
+				continue
+			}
+			for _, res := range utils.MultiGet(keys, memtableInstances, rowCache, lsm, cfg, bm, dict, tableCache) {
+				switch res.Status {
+				case utils.KeyFound:
+					fmt.Printf("Pronađena vrednost: [%s -> %s]\n", utils.MaybeQuote(res.Key), utils.MaybeQuote(string(res.Value)))
+				case utils.KeyDeleted:
+					fmt.Printf("Ključ [%s] je obrisan\n", utils.MaybeQuote(res.Key))
+				default:
+					fmt.Printf("Nije pronadjena vrednost za kljuc: [%s]\n", utils.MaybeQuote(res.Key))
+				}
+			}
+
 		// --------------------------------------------------------------------------------------------------------------------------
 		// DELETE komanda
 		// --------------------------------------------------------------------------------------------------------------------------
@@ -351,7 +431,7 @@ func main() {
 				continue
 			}
 			// Brisanje iz keša
-			lru.DeleteFromCache(parts[1])
+			rowCache.Invalidate(parts[1])
 
 			// Konverzija kljuca u []byte, tombstone je true
 			key := []byte(parts[1])
@@ -360,7 +440,7 @@ func main() {
 			var deleted bool
 			var found bool
 			delIndex := 0
-			for delIndex < cfg.MemtableNum {
+			for delIndex < len(memtableInstances) {
 				value, deleted, found = memtableInstances[delIndex].Get(parts[1])
 				if found {
 					break
@@ -375,26 +455,55 @@ func main() {
 			if err != nil {
 				fmt.Printf("Greška prilikom brisanja iz WAL-a: [%s -> %s]\n", utils.MaybeQuote(string(key)), utils.MaybeQuote(string(value)))
 			}
+			// Tombstone ide u aktivni Memtable i zaklanja starije verzije iz nepromenljivih Memtable-a i sa diska
+			utils.DeleteFromMemory(ts, parts[1], memtables, walInstance, rowCache)
 			if found && !deleted {
-				deleted := memtableInstances[delIndex].Delete(parts[1])
-				if deleted {
-					fmt.Printf("Uspešno izbrisano iz Memtable-a: [%s -> %s]\n", utils.MaybeQuote(string(key)), utils.MaybeQuote(string(value)))
-				}
+				fmt.Printf("Uspešno izbrisano iz Memtable-a: [%s -> %s]\n", utils.MaybeQuote(string(key)), utils.MaybeQuote(string(value)))
 			} else if found && deleted {
 				fmt.Printf("Ključ [%s] je obrisan u Memtable\n", utils.MaybeQuote(string(key)))
 			} else {
-				// Zapis je potencijalno u SSTable - zapisujemo njegovo brisanje
-				sstrecords := utils.WriteToMemory(ts, tombstone, parts[1], []byte{}, bm, &memtableInstances, &mtIndex, walInstance, cfg.MemtableNum)
-				if sstrecords != nil {
-					err := utils.WriteToDisk(sstrecords, sstableDir, bm, &lsm, cfg, dict, dictPath)
-					if err != nil {
-						fmt.Printf("Greška pri kreiranju SSTable: %v\n", err)
-					}
-				}
 				fmt.Printf("Ključ nije pronađen: [%s]\n", utils.MaybeQuote(string(key)))
 				fmt.Printf("Brisanje evidentirano u sistemu: [%s -> %s]\n", utils.MaybeQuote(string(key)), utils.MaybeQuote(string(value)))
 			}
 
+		// --------------------------------------------------------------------------------------------------------------------------
+		// DELETE_RANGE i DELETE_PREFIX komande
+		// --------------------------------------------------------------------------------------------------------------------------
+
+		// DELETE_RANGE briše sve ključeve iz [početni, krajnji), a DELETE_PREFIX sve ključeve sa prefiksom.
+		// Brisanje se upisuje jednim zapisom u WAL, bez čitanja ključeva sa diska.
+		case "DELETE_RANGE", "DELETE_PREFIX":
+			var start, end string
+			if command == "DELETE_RANGE" {
+				if len(parts) != 3 || parts[1] >= parts[2] {
+					fmt.Println("Greška: DELETE_RANGE zahteva <početni_ključ> <krajnji_ključ> (krajnji ključ je veći i ne briše se)")
+					continue
+				}
+				start, end = parts[1], parts[2]
+			} else {
+				if len(parts) != 2 || utils.PrefixEnd(parts[1]) == "" {
+					fmt.Println("Greška: DELETE_PREFIX zahteva <prefiks>")
+					continue
+				}
+				start, end = parts[1], utils.PrefixEnd(parts[1])
+			}
+			if start < utils.PrefixEnd("__sys__") && end > "__sys__" {
+				fmt.Println("Zabranjena operacija nad internim ključevima.")
+				continue
+			}
+
+			ts, err := walInstance.AppendRangeTombstone([]byte(start), []byte(end))
+			if err != nil {
+				fmt.Println("Greška prilikom upisa u WAL:", err)
+				continue
+			}
+			rowCache.InvalidateRange(start, end)
+			utils.ApplyRangeTombstone(ts, start, end, memtableInstances)
+
+			// Tombstone početnog ključa nosi brisanje opsega do flush-a Memtable-a
+			utils.DeleteFromMemory(ts, start, memtables, walInstance, rowCache)
+			fmt.Printf("Obrisani su ključevi iz opsega [%s, %s)\n", utils.MaybeQuote(start), utils.MaybeQuote(end))
+
 		// --------------------------------------------------------------------------------------------------------------------------
 		// VALIDATE komanda
 		// --------------------------------------------------------------------------------------------------------------------------
@@ -476,13 +585,7 @@ func main() {
 			}
 
 			// Memtable
-			sstRecords := utils.WriteToMemory(ts, false, key, value, bm, &memtableInstances, &mtIndex, walInstance, cfg.MemtableNum)
-			if sstRecords != nil {
-				err := utils.WriteToDisk(sstRecords, sstableDir, bm, &lsm, cfg, dict, dictPath)
-				if err != nil {
-					fmt.Printf("Greska pri kreiranju SSTable: %v\n", err)
-				}
-			}
+			utils.WriteToMemory(ts, false, key, value, memtables, walInstance, rowCache)
 
 			fmt.Println("Bloom filter kreiran:", name)
 
@@ -500,7 +603,7 @@ func main() {
 			var data []byte
 			var found bool
 			var deleted bool
-			for i := 0; i < cfg.MemtableNum; i++ {
+			for i := range memtableInstances {
 				data, deleted, found = memtableInstances[i].Get(key)
 				if found {
 					break
@@ -509,7 +612,7 @@ func main() {
 
 			// 2. ako nije u memtable, idi u cache
 			if !found {
-				data, found = lru.CheckCache(key)
+				data, _, found = rowCache.Get(key)
 			}
 
 			// 3. ako nije u cache, idi u SSTable
@@ -521,10 +624,12 @@ func main() {
 					}
 				}
 
-				record := utils.ReadFromDisk(key, maxLevel, lsm, cfg, bm, dict)
+				record := utils.ReadFromDisk(key, maxLevel, lsm, cfg, bm, dict, tableCache)
 				if record != nil {
 					data = record.Value
-					lru.UpdateCache(key, data)
+					rowCache.Put(key, data)
+				} else {
+					rowCache.PutMissing(key)
 				}
 			}
 
@@ -546,19 +651,7 @@ func main() {
 			}
 
 			// Memtable
-			sstRecords := utils.WriteToMemory(ts, false, key, value, bm, &memtableInstances, &mtIndex, walInstance, cfg.MemtableNum)
-			if sstRecords != nil {
-				err := utils.WriteToDisk(sstRecords, sstableDir, bm, &lsm, cfg, dict, dictPath)
-				if err != nil {
-					fmt.Printf("Greska pri kreiranju SSTable: %v\n", err)
-				}
-			}
-
-			// Cache
-			_, inCache := lru.CheckCache(key)
-			if inCache {
-				lru.UpdateCache(key, value)
-			}
+			utils.WriteToMemory(ts, false, key, value, memtables, walInstance, rowCache)
 
 			fmt.Println("Element dodat u Bloom filter.")
 
@@ -575,14 +668,14 @@ func main() {
 			var data []byte
 			var found bool
 			var deleted bool
-			for i := 0; i < cfg.MemtableNum; i++ {
+			for i := range memtableInstances {
 				data, deleted, found = memtableInstances[i].Get(key)
 				if found {
 					break
 				}
 			}
 			if !found {
-				data, found = lru.CheckCache(key)
+				data, _, found = rowCache.Get(key)
 			}
 
 			if (!found || deleted) && !deleted {
@@ -593,10 +686,12 @@ func main() {
 					}
 				}
 
-				record := utils.ReadFromDisk(key, maxLevel, lsm, cfg, bm, dict)
+				record := utils.ReadFromDisk(key, maxLevel, lsm, cfg, bm, dict, tableCache)
 				if record != nil {
 					data = record.Value
-					lru.UpdateCache(key, data)
+					rowCache.Put(key, data)
+				} else {
+					rowCache.PutMissing(key)
 				}
 			}
 
@@ -628,18 +723,7 @@ func main() {
 				continue
 			}
 
-			sstRecords := utils.WriteToMemory(ts, true, key, nil, bm, &memtableInstances, &mtIndex, walInstance, cfg.MemtableNum)
-			if sstRecords != nil {
-				err := utils.WriteToDisk(sstRecords, sstableDir, bm, &lsm, cfg, dict, dictPath)
-				if err != nil {
-					fmt.Println("Greska pri pisanju SSTable:", err)
-				}
-			}
-
-			_, inCache := lru.CheckCache(key)
-			if inCache {
-				lru.DeleteFromCache(key)
-			}
+			utils.DeleteFromMemory(ts, key, memtables, walInstance, rowCache)
 
 			fmt.Println("Bloom filter obrisan:", name)
 
@@ -671,13 +755,7 @@ func main() {
 				continue
 			}
 
-			sstRecords := utils.WriteToMemory(ts, false, key, value, bm, &memtableInstances, &mtIndex, walInstance, cfg.MemtableNum)
-			if sstRecords != nil {
-				err := utils.WriteToDisk(sstRecords, sstableDir, bm, &lsm, cfg, dict, dictPath)
-				if err != nil {
-					fmt.Println("Greska pri pisanju SSTable:", err)
-				}
-			}
+			utils.WriteToMemory(ts, false, key, value, memtables, walInstance, rowCache)
 
 			fmt.Println("Count-Min Sketch kreiran:", name)
 
@@ -694,7 +772,7 @@ func main() {
 			var data []byte
 			var found bool
 			var deleted bool
-			for i := 0; i < cfg.MemtableNum; i++ {
+			for i := range memtableInstances {
 				data, deleted, found = memtableInstances[i].Get(key)
 				if found {
 					break
@@ -702,7 +780,7 @@ func main() {
 			}
 
 			if !found {
-				data, found = lru.CheckCache(key)
+				data, _, found = rowCache.Get(key)
 			}
 
 			if !found || deleted {
@@ -713,10 +791,12 @@ func main() {
 					}
 				}
 
-				record := utils.ReadFromDisk(key, maxLevel, lsm, cfg, bm, dict)
+				record := utils.ReadFromDisk(key, maxLevel, lsm, cfg, bm, dict, tableCache)
 				if record != nil {
 					data = record.Value
-					lru.UpdateCache(key, data)
+					rowCache.Put(key, data)
+				} else {
+					rowCache.PutMissing(key)
 				}
 			}
 
@@ -740,18 +820,7 @@ func main() {
 				continue
 			}
 
-			sstRecords := utils.WriteToMemory(ts, false, key, value, bm, &memtableInstances, &mtIndex, walInstance, cfg.MemtableNum)
-			if sstRecords != nil {
-				err := utils.WriteToDisk(sstRecords, sstableDir, bm, &lsm, cfg, dict, dictPath)
-				if err != nil {
-					fmt.Println("Greska pri pisnju SSTable:", err)
-				}
-			}
-
-			_, inCache := lru.CheckCache(key)
-			if inCache {
-				lru.UpdateCache(key, value)
-			}
+			utils.WriteToMemory(ts, false, key, value, memtables, walInstance, rowCache)
 
 			fmt.Println("Element dodat u CMS:", elem)
 
@@ -768,7 +837,7 @@ func main() {
 			var data []byte
 			var found bool
 			var deleted bool
-			for i := 0; i < cfg.MemtableNum; i++ {
+			for i := range memtableInstances {
 				data, deleted, found = memtableInstances[i].Get(key)
 				if found {
 					break
@@ -776,7 +845,7 @@ func main() {
 			}
 
 			if !found {
-				data, found = lru.CheckCache(key)
+				data, _, found = rowCache.Get(key)
 			}
 
 			if !found || deleted {
@@ -787,10 +856,12 @@ func main() {
 					}
 				}
 
-				record := utils.ReadFromDisk(key, maxLevel, lsm, cfg, bm, dict)
+				record := utils.ReadFromDisk(key, maxLevel, lsm, cfg, bm, dict, tableCache)
 				if record != nil {
 					data = record.Value
-					lru.UpdateCache(key, data)
+					rowCache.Put(key, data)
+				} else {
+					rowCache.PutMissing(key)
 				}
 			}
 
@@ -823,17 +894,7 @@ func main() {
 				fmt.Println("Greska pri pisanju u WAL:", err)
 				continue
 			}
-			sstRecords := utils.WriteToMemory(ts, true, key, nil, bm, &memtableInstances, &mtIndex, walInstance, cfg.MemtableNum)
-			if sstRecords != nil {
-				err := utils.WriteToDisk(sstRecords, sstableDir, bm, &lsm, cfg, dict, dictPath)
-				if err != nil {
-					fmt.Println("Greska pri pisanju SSTable:", err)
-				}
-			}
-			_, inCache := lru.CheckCache(key)
-			if inCache {
-				lru.DeleteFromCache(key)
-			}
+			utils.DeleteFromMemory(ts, key, memtables, walInstance, rowCache)
 			fmt.Println("Count-Min Sketch obrisan:", name)
 
 		// -----------------------------------
@@ -863,13 +924,7 @@ func main() {
 				continue
 			}
 
-			sstRecords := utils.WriteToMemory(ts, false, key, value, bm, &memtableInstances, &mtIndex, walInstance, cfg.MemtableNum)
-			if sstRecords != nil {
-				err := utils.WriteToDisk(sstRecords, sstableDir, bm, &lsm, cfg, dict, dictPath)
-				if err != nil {
-					fmt.Println("Greska pri pisanju SSTable:", err)
-				}
-			}
+			utils.WriteToMemory(ts, false, key, value, memtables, walInstance, rowCache)
 
 			fmt.Println("HLL instanca kreirana:", name)
 
@@ -886,7 +941,7 @@ func main() {
 			var data []byte
 			var found, deleted bool
 
-			for i := 0; i < cfg.MemtableNum; i++ {
+			for i := range memtableInstances {
 				data, deleted, found = memtableInstances[i].Get(key)
 				if found {
 					break
@@ -894,7 +949,7 @@ func main() {
 			}
 
 			if !found {
-				data, found = lru.CheckCache(key)
+				data, _, found = rowCache.Get(key)
 			}
 
 			if !found || deleted {
@@ -905,10 +960,12 @@ func main() {
 					}
 				}
 
-				record := utils.ReadFromDisk(key, maxLevel, lsm, cfg, bm, dict)
+				record := utils.ReadFromDisk(key, maxLevel, lsm, cfg, bm, dict, tableCache)
 				if record != nil {
 					data = record.Value
-					lru.UpdateCache(key, data)
+					rowCache.Put(key, data)
+				} else {
+					rowCache.PutMissing(key)
 				}
 			}
 
@@ -934,18 +991,7 @@ func main() {
 				continue
 			}
 
-			sstRecords := utils.WriteToMemory(ts, false, key, value, bm, &memtableInstances, &mtIndex, walInstance, cfg.MemtableNum)
-			if sstRecords != nil {
-				err := utils.WriteToDisk(sstRecords, sstableDir, bm, &lsm, cfg, dict, dictPath)
-				if err != nil {
-					fmt.Println("Greska pri pisanju SSTable:", err)
-				}
-			}
-
-			_, inCache := lru.CheckCache(key)
-			if inCache {
-				lru.UpdateCache(key, value)
-			}
+			utils.WriteToMemory(ts, false, key, value, memtables, walInstance, rowCache)
 
 			fmt.Println("Element dodat u HLL:", elem)
 
@@ -961,7 +1007,7 @@ func main() {
 			var data []byte
 			var found, deleted bool
 
-			for i := 0; i < cfg.MemtableNum; i++ {
+			for i := range memtableInstances {
 				data, deleted, found = memtableInstances[i].Get(key)
 				if found {
 					break
@@ -969,7 +1015,7 @@ func main() {
 			}
 
 			if !found {
-				data, found = lru.CheckCache(key)
+				data, _, found = rowCache.Get(key)
 			}
 
 			if !found || deleted {
@@ -980,10 +1026,12 @@ func main() {
 					}
 				}
 
-				record := utils.ReadFromDisk(key, maxLevel, lsm, cfg, bm, dict)
+				record := utils.ReadFromDisk(key, maxLevel, lsm, cfg, bm, dict, tableCache)
 				if record != nil {
 					data = record.Value
-					lru.UpdateCache(key, data)
+					rowCache.Put(key, data)
+				} else {
+					rowCache.PutMissing(key)
 				}
 			}
 
@@ -1016,17 +1064,7 @@ func main() {
 				continue
 			}
 
-			sstRecords := utils.WriteToMemory(ts, true, key, nil, bm, &memtableInstances, &mtIndex, walInstance, cfg.MemtableNum)
-			if sstRecords != nil {
-				err := utils.WriteToDisk(sstRecords, sstableDir, bm, &lsm, cfg, dict, dictPath)
-				if err != nil {
-					fmt.Println("Greska pri pisanju SSTable:", err)
-				}
-			}
-			_, inCache := lru.CheckCache(key)
-			if inCache {
-				lru.DeleteFromCache(key)
-			}
+			utils.DeleteFromMemory(ts, key, memtables, walInstance, rowCache)
 			fmt.Println("HLL obrisan:", name)
 
 		// -----------------------------------
@@ -1061,13 +1099,7 @@ func main() {
 				continue
 			}
 
-			sstRecords := utils.WriteToMemory(ts, false, key, value, bm, &memtableInstances, &mtIndex, walInstance, cfg.MemtableNum)
-			if sstRecords != nil {
-				err := utils.WriteToDisk(sstRecords, sstableDir, bm, &lsm, cfg, dict, dictPath)
-				if err != nil {
-					fmt.Println("Greska pri pisanju SSTable:", err)
-				}
-			}
+			utils.WriteToMemory(ts, false, key, value, memtables, walInstance, rowCache)
 
 			fmt.Println("SimHash fingerprint sačuvan pod imenom:", name)
 
@@ -1088,25 +1120,25 @@ func main() {
 			var deleted1, deleted2 bool
 
 			// Prvo memtable pretraga
-			for i := 0; i < cfg.MemtableNum; i++ {
+			for i := range memtableInstances {
 				data1, deleted1, found1 = memtableInstances[i].Get(key1)
-				if found1 && !deleted1 {
+				if found1 {
 					break
 				}
 			}
-			for i := 0; i < cfg.MemtableNum; i++ {
+			for i := range memtableInstances {
 				data2, deleted2, found2 = memtableInstances[i].Get(key2)
-				if found2 && !deleted2 {
+				if found2 {
 					break
 				}
 			}
 
 			// Ako nije nadjeno, idi u Cache
 			if !found1 {
-				data1, found1 = lru.CheckCache(key1)
+				data1, _, found1 = rowCache.Get(key1)
 			}
 			if !found2 {
-				data2, found2 = lru.CheckCache(key2)
+				data2, _, found2 = rowCache.Get(key2)
 			}
 
 			// Ako nije nadjeno, idi u SSTable
@@ -1119,19 +1151,23 @@ func main() {
 				}
 
 				if !found1 || deleted1 {
-					record1 := utils.ReadFromDisk(key1, maxLevel, lsm, cfg, bm, dict)
+					record1 := utils.ReadFromDisk(key1, maxLevel, lsm, cfg, bm, dict, tableCache)
 					if record1 != nil {
 						data1 = record1.Value
-						lru.UpdateCache(key1, data1)
+						rowCache.Put(key1, data1)
+					} else {
+						rowCache.PutMissing(key1)
 					}
 
 				}
 
 				if !found2 || deleted2 {
-					record2 := utils.ReadFromDisk(key1, maxLevel, lsm, cfg, bm, dict)
+					record2 := utils.ReadFromDisk(key1, maxLevel, lsm, cfg, bm, dict, tableCache)
 					if record2 != nil {
 						data2 = record2.Value
-						lru.UpdateCache(key2, data2)
+						rowCache.Put(key2, data2)
+					} else {
+						rowCache.PutMissing(key2)
 					}
 				}
 			}
@@ -1152,235 +1188,130 @@ func main() {
 		// --------------------------------------------------------------------------------------------------------------------------
 
 		case "PREFIX_SCAN":
-			if len(parts) != 4 {
-				fmt.Println("Greška: PREFIX_SCAN zahteva <prefiks> <broj_strane> <veličina_strane>")
+			if len(parts) < 4 {
+				fmt.Println("Greška: PREFIX_SCAN zahteva <prefiks> <broj_strane|token> <veličina_strane> [ASC|DESC] [KEYS_ONLY] [filteri]")
+				continue
+			}
+			opts, err := utils.ParseScanOptions(parts[4:], false)
+			if err != nil {
+				fmt.Println("Greška:", err)
 				continue
 			}
 
 			prefix := parts[1]
 			minKey := prefix
 			maxKey := prefix + "\xff"
-			pageNum, err1 := strconv.Atoi(parts[2])
+			direction := "ASC"
+			if opts.Descending {
+				direction = "DESC"
+			}
+			// Umesto broja strane može se proslediti token za nastavak dobijen uz prethodnu stranu
+			scope := cursor.ScanScope(append([]string{"PREFIX_SCAN", prefix, direction}, opts.Spec...)...)
+			pageNum, token, err1 := utils.ParsePageArg(parts[2], scope)
 			pageSize, err2 := strconv.Atoi(parts[3])
 
-			if err1 != nil || err2 != nil || pageNum < 1 || pageSize < 1 {
-				fmt.Println("Nevalidan broj ili veličina stranica.")
+			if err1 != nil || err2 != nil || pageSize < 1 {
+				fmt.Println("Nevalidan broj strane, token ili veličina stranica.")
 				continue
 			}
 
-			// Napravi cursore za sve memtabele
-			cursors := make([]cursor.Cursor, 0, len(memtableInstances))
-			for _, mt := range memtableInstances {
-				cursors = append(cursors, mt.NewCursor())
-			}
-
-			// Napravi kursore za sve SSTabele
-			sstableCursors := make([]cursor.Cursor, 0)
-			for _, level := range lsm {
-				for _, path := range level {
-					newCursor, err := sstable.NewCursor(bm, path, minKey, maxKey, cfg.BlockSize, cfg.SSTableCompression, dict)
-					if err != nil {
-						fmt.Printf("Greška prilikom formiranja kursora.")
-					}
-					sstableCursors = append(sstableCursors, &newCursor)
-				}
-			}
-
-			cursors = append(cursors, sstableCursors...)
-
-			// Napravi jedan multi cursor kao wrapper svih cursora
-			mc := cursor.NewMultiCursor(minKey, maxKey, cursors...)
-			defer mc.Close()
-
-			// Prikupi sve zapise
-			records := make(map[string]struct {
-				value []byte
-				ts    [16]byte
-			})
-
-			for mc.Next() {
-				key := mc.Key()
-				if key == "" {
-					continue
-				}
-
-				// Preskoci ako je izbrisano
-				if mc.Tombstone() {
-					delete(records, key)
-					continue
-				}
+			// Multi cursor spaja sve Memtable-e i SSTabele koje mogu sadržati ključ sa prefiksom
+			mc := utils.NewScanCursor(minKey, maxKey, prefix, memtableInstances, lsm, cfg, bm, dict, tableCache, valueLog)
 
-				// Sacuvaj samo najnoviju verziju
-				currTS := mc.Timestamp()
-				if existing, ok := records[key]; ok {
-					if bytes.Compare(currTS[:], existing.ts[:]) > 0 {
-						// Novi zapis ima veci timestamp, azuriraj
-						records[key] = struct {
-							value []byte
-							ts    [16]byte
-						}{
-							value: mc.Value(),
-							ts:    currTS,
-						}
-					}
-				} else {
-					// Ne postoji u records, samo dodaj
-					records[key] = struct {
-						value []byte
-						ts    [16]byte
-					}{
-						value: mc.Value(),
-						ts:    currTS,
-					}
-				}
-			}
+			// Filter se primenjuje pri spajanju, pa ključevi koji ga ne prođu ne zauzimaju mesto na strani
+			mc.SetKeysOnly(opts.KeysOnly)
+			mc.SetFilter(opts.Filter)
 
-			// Sortiraj kljuceve
-			sortedKeys := make([]string, 0, len(records))
-			for k := range records {
-				if k >= minKey && k <= maxKey {
-					sortedKeys = append(sortedKeys, k)
-				}
-			}
-			sort.Strings(sortedKeys)
-
-			// Paginacija
-			total := len(sortedKeys)
+			// Strana se čita od broja strane ili od pozicije iz tokena
 			start := (pageNum - 1) * pageSize
-			if start >= total {
-				fmt.Printf("Nema zapisa (stranica %d od %d)\n", pageNum, (total+pageSize-1)/pageSize)
-				continue
-			}
-			end := start + pageSize
-			if end > total {
-				end = total
-			}
-
-			// Prikazi rezultate
-			fmt.Printf("Strana %d (rezultati %d-%d od %d):\n", pageNum, start+1, end, total)
-			for _, key := range sortedKeys[start:end] {
-				fmt.Printf("- [%s -> %s]\n", utils.MaybeQuote(key), utils.MaybeQuote(string(records[key].value)))
-			}
-
-			// Zatvori multicursor
+			page, next := cursor.ScanPage(mc, opts.Descending, start, pageSize, token)
 			mc.Close()
+			printPage(page, pageNum, start, token, next, scope, walInstance.LastWrite(), opts.KeysOnly)
 
 		// --------------------------------------------------------------------------------------------------------------------------
 		// RANGE_SCAN komanda
 		// --------------------------------------------------------------------------------------------------------------------------
 
 		case "RANGE_SCAN":
-			if len(parts) != 5 {
-				fmt.Println("Greška: RANGE_SCAN zahteva <početni_ključ> <krajnji_ključ> <broj_strane> <veličina_strane>")
+			if len(parts) < 5 {
+				fmt.Println("Greška: RANGE_SCAN zahteva <početni_ključ> <krajnji_ključ> <broj_strane|token> <veličina_strane> [ASC|DESC] [KEYS_ONLY] [filteri]")
+				continue
+			}
+			// DESC vraća ključeve od najvećeg ka najmanjem (npr. poslednjih N vremenski uređenih ključeva)
+			opts, err := utils.ParseScanOptions(parts[5:], false)
+			if err != nil {
+				fmt.Println("Greška:", err)
 				continue
 			}
 
 			minKey := parts[1]
 			maxKey := parts[2]
-			pageNum, err1 := strconv.Atoi(parts[3])
+			direction := "ASC"
+			if opts.Descending {
+				direction = "DESC"
+			}
+			// Umesto broja strane može se proslediti token za nastavak dobijen uz prethodnu stranu
+			scope := cursor.ScanScope(append([]string{"RANGE_SCAN", minKey, maxKey, direction}, opts.Spec...)...)
+			pageNum, token, err1 := utils.ParsePageArg(parts[3], scope)
 			pageSize, err2 := strconv.Atoi(parts[4])
 
-			if err1 != nil || err2 != nil || pageNum < 1 || pageSize < 1 {
-				fmt.Println("Nevalidan broj ili veličina stranica.")
+			if err1 != nil || err2 != nil || pageSize < 1 {
+				fmt.Println("Nevalidan broj strane, token ili veličina stranica.")
 				continue
 			}
 
-			// Napravi cursore za sve memtabele
-			cursors := make([]cursor.Cursor, 0, len(memtableInstances))
-			for _, mt := range memtableInstances {
-				cursors = append(cursors, mt.NewCursor())
-			}
-
-			// Napravi kursore za sve SSTabele
-			sstableCursors := make([]cursor.Cursor, 0)
-			for _, level := range lsm {
-				for _, path := range level {
-					newCursor, err := sstable.NewCursor(bm, path, minKey, maxKey, cfg.BlockSize, cfg.SSTableCompression, dict)
-					if err != nil {
-						fmt.Printf("Greška prilikom formiranja kursora.")
-					}
-					sstableCursors = append(sstableCursors, &newCursor)
-				}
-			}
+			// Multi cursor spaja sve Memtable-e i SSTabele koje mogu sadržati ključ iz opsega
+			mc := utils.NewScanCursor(minKey, maxKey, "", memtableInstances, lsm, cfg, bm, dict, tableCache, valueLog)
 
-			cursors = append(cursors, sstableCursors...)
+			// Filter se primenjuje pri spajanju, pa ključevi koji ga ne prođu ne zauzimaju mesto na strani
+			mc.SetKeysOnly(opts.KeysOnly)
+			mc.SetFilter(opts.Filter)
 
-			// Napravi jedan multi cursor kao wrapper svih cursora
-			mc := cursor.NewMultiCursor(minKey, maxKey, cursors...)
-			defer mc.Close()
+			// Strana se čita od broja strane ili od pozicije iz tokena
+			start := (pageNum - 1) * pageSize
+			page, next := cursor.ScanPage(mc, opts.Descending, start, pageSize, token)
+			mc.Close()
+			printPage(page, pageNum, start, token, next, scope, walInstance.LastWrite(), opts.KeysOnly)
 
-			// Prikupi sve zapise
-			records := make(map[string]struct {
-				value []byte
-				ts    [16]byte
-			})
+		// --------------------------------------------------------------------------------------------------------------------------
+		// COUNT_PREFIX i COUNT_RANGE komande
+		// --------------------------------------------------------------------------------------------------------------------------
 
-			for mc.Next() {
-				key := mc.Key()
-				if key == "" {
+		// Brojanje prolazi kroz ključeve bez čitanja vrednosti, osim kada je zadat filter vrednosti
+		case "COUNT_PREFIX", "COUNT_RANGE":
+			var minKey, maxKey, prefix string
+			var optArgs []string
+			if command == "COUNT_PREFIX" {
+				if len(parts) < 2 {
+					fmt.Println("Greška: COUNT_PREFIX zahteva <prefiks> [filteri]")
 					continue
 				}
-
-				// Preskoci ako je izbrisano
-				if mc.Tombstone() {
-					delete(records, key)
+				prefix = parts[1]
+				minKey, maxKey = prefix, prefix+"\xff"
+				optArgs = parts[2:]
+			} else {
+				if len(parts) < 3 {
+					fmt.Println("Greška: COUNT_RANGE zahteva <početni_ključ> <krajnji_ključ> [filteri]")
 					continue
 				}
-
-				// Sacuvaj samo najnoviju verziju
-				currTS := mc.Timestamp()
-				if existing, ok := records[key]; ok {
-					if bytes.Compare(currTS[:], existing.ts[:]) > 0 {
-						// Novi zapis ima veci timestamp, azuriraj
-						records[key] = struct {
-							value []byte
-							ts    [16]byte
-						}{
-							value: mc.Value(),
-							ts:    currTS,
-						}
-					}
-				} else {
-					// Ne postoji u records, samo dodaj
-					records[key] = struct {
-						value []byte
-						ts    [16]byte
-					}{
-						value: mc.Value(),
-						ts:    currTS,
-					}
-				}
-			}
-
-			// Sortiraj kljuceve
-			sortedKeys := make([]string, 0, len(records))
-			for k := range records {
-				if k >= minKey && k <= maxKey {
-					sortedKeys = append(sortedKeys, k)
-				}
+				minKey, maxKey = parts[1], parts[2]
+				optArgs = parts[3:]
 			}
-			sort.Strings(sortedKeys)
-
-			// Paginacija
-			total := len(sortedKeys)
-			start := (pageNum - 1) * pageSize
-			if start >= total {
-				fmt.Printf("Nema zapisa (stranica %d od %d)\n", pageNum, (total+pageSize-1)/pageSize)
+			opts, err := utils.ParseScanOptions(optArgs, true)
+			if err != nil {
+				fmt.Println("Greška:", err)
 				continue
 			}
-			end := start + pageSize
-			if end > total {
-				end = total
-			}
 
-			// Prikazi rezultate
-			fmt.Printf("Strana %d (rezultati %d-%d od %d):\n", pageNum, start+1, end, total)
-			for _, key := range sortedKeys[start:end] {
-				fmt.Printf("- [%s -> %s]\n", utils.MaybeQuote(key), utils.MaybeQuote(string(records[key].value)))
+			mc := utils.NewScanCursor(minKey, maxKey, prefix, memtableInstances, lsm, cfg, bm, dict, tableCache, valueLog)
+			mc.SetKeysOnly(true)
+			mc.SetFilter(opts.Filter)
+			count := 0
+			for mc.Next() {
+				count++
 			}
-
-			// Zatvori multicursor
 			mc.Close()
+			fmt.Printf("Broj ključeva: %d\n", count)
 
 		// --------------------------------------------------------------------------------------------------------------------------
 		// PREFIX_ITERATE komanda
@@ -1396,93 +1327,19 @@ func main() {
 			minKey := prefix
 			maxKey := prefix + "\xff"
 
-			// Napravi cursore za sve memtabele
-			cursors := make([]cursor.Cursor, 0, len(memtableInstances))
-			for _, mt := range memtableInstances {
-				cursors = append(cursors, mt.NewCursor())
-			}
-
-			// Napravi kursore za sve SSTabele
-			sstableCursors := make([]cursor.Cursor, 0)
-			for _, level := range lsm {
-				for _, path := range level {
-					newCursor, err := sstable.NewCursor(bm, path, minKey, maxKey, cfg.BlockSize, cfg.SSTableCompression, dict)
-					if err != nil {
-						fmt.Printf("Greška prilikom formiranja kursora.")
-					}
-					sstableCursors = append(sstableCursors, &newCursor)
-				}
-			}
-
-			cursors = append(cursors, sstableCursors...)
-
-			// Napravi jedan multi cursor kao wrapper svih cursora
-			mc := cursor.NewMultiCursor(minKey, maxKey, cursors...)
-
-			// Prikupi sve zapise
-			records := make(map[string]struct {
-				value []byte
-				ts    [16]byte
-			})
+			// Multi cursor spaja sve Memtable-e i SSTabele koje mogu sadržati ključ sa prefiksom
+			mc := utils.NewScanCursor(minKey, maxKey, prefix, memtableInstances, lsm, cfg, bm, dict, tableCache, valueLog)
 
-			for mc.Next() {
-				key := mc.Key()
-				if key == "" {
-					continue
-				}
-
-				// Preskoci ako je izbrisano
-				if mc.Tombstone() {
-					delete(records, key)
-					continue
-				}
-
-				// Sacuvaj samo najnoviju verziju
-				currTS := mc.Timestamp()
-				if existing, ok := records[key]; ok {
-					if bytes.Compare(currTS[:], existing.ts[:]) > 0 {
-						// Novi zapis ima veci timestamp, azuriraj
-						records[key] = struct {
-							value []byte
-							ts    [16]byte
-						}{
-							value: mc.Value(),
-							ts:    currTS,
-						}
-					}
-				} else {
-					// Ne postoji u records, samo dodaj
-					records[key] = struct {
-						value []byte
-						ts    [16]byte
-					}{
-						value: mc.Value(),
-						ts:    currTS,
-					}
-				}
-			}
-			mc.Close() // Zatvori multicursor jer smo vec procitali sve podatke
-
-			// Sortiraj kljuceve
-			sortedKeys := make([]string, 0, len(records))
-			for k := range records {
-				if k >= minKey && k <= maxKey {
-					sortedKeys = append(sortedKeys, k)
-				}
-			}
-			sort.Strings(sortedKeys)
-
-			// Iterate petlja koja koristi vec ucitane zapise
-			currentIndex := 0
+			// Iterate petlja čita sledeći zapis tek na NEXT
+			hasRecord := mc.Next()
 		outer_prefix:
-			for currentIndex < len(sortedKeys) {
-				key := sortedKeys[currentIndex]
-				record := records[key]
-
-				fmt.Printf("- [%s -> %s]\n", utils.MaybeQuote(key), utils.MaybeQuote(string(record.value)))
-				fmt.Print("Naredba (NEXT/STOP): ")
+			for hasRecord {
+				fmt.Printf("- [%s -> %s]\n", utils.MaybeQuote(mc.Key()), utils.MaybeQuote(string(mc.Value())))
+				fmt.Print("Naredba (NEXT/PREV/STOP): ")
 
-				// Citanje linije iz inputa
+				// Citanje linije iz inputa. Zaključavanje ostaje uzeto jer cursor čita Memtable-e i tabele
+				// koje bi objava flush-a promenila; flusher u međuvremenu upisuje tabelu, a objavljuje je
+				// po izlasku iz petlje.
 				if !scanner.Scan() {
 					break
 				}
@@ -1499,9 +1356,16 @@ func main() {
 					break outer_prefix
 				case "NEXT":
 					// Predji na sledeci element
-					currentIndex++
+					hasRecord = mc.Next()
+				case "PREV":
+					// Vrati se na prethodni element; na prvom elementu cursor ostaje na mestu
+					key := mc.Key()
+					if !mc.Prev() {
+						fmt.Println("Nema prethodnog elementa")
+						mc.Seek(key)
+					}
 				default:
-					fmt.Println("Nepoznata komanda. Upotrebite NEXT ili STOP")
+					fmt.Println("Nepoznata komanda. Upotrebite NEXT, PREV ili STOP")
 				}
 			}
 			fmt.Printf("Izlaz iz iterate petlje.\n")
@@ -1514,101 +1378,32 @@ func main() {
 			// --------------------------------------------------------------------------------------------------------------------------
 
 		case "RANGE_ITERATE":
-			if len(parts) != 3 {
-				fmt.Println("Greška: RANGE_ITERATE zahteva <početni_ključ> <krajnji_ključ>")
+			if len(parts) != 3 && (len(parts) != 4 || (strings.ToUpper(parts[3]) != "ASC" && strings.ToUpper(parts[3]) != "DESC")) {
+				fmt.Println("Greška: RANGE_ITERATE zahteva <početni_ključ> <krajnji_ključ> [ASC|DESC]")
 				continue
 			}
+			descending := len(parts) == 4 && strings.ToUpper(parts[3]) == "DESC"
 
 			minKey := parts[1]
 			maxKey := parts[2]
 
-			// Napravi cursore za sve memtabele
-			cursors := make([]cursor.Cursor, 0, len(memtableInstances))
-			for _, mt := range memtableInstances {
-				cursors = append(cursors, mt.NewCursor())
-			}
-
-			// Napravi kursore za sve SSTabele
-			sstableCursors := make([]cursor.Cursor, 0)
-			for _, level := range lsm {
-				for _, path := range level {
-					newCursor, err := sstable.NewCursor(bm, path, minKey, maxKey, cfg.BlockSize, cfg.SSTableCompression, dict)
-					if err != nil {
-						fmt.Printf("Greška prilikom formiranja kursora.")
-					}
-					sstableCursors = append(sstableCursors, &newCursor)
-				}
-			}
-
-			cursors = append(cursors, sstableCursors...)
-
-			// Napravi jedan multi cursor kao wrapper svih cursora
-			mc := cursor.NewMultiCursor(minKey, maxKey, cursors...)
-
-			// Prikupi sve zapise
-			records := make(map[string]struct {
-				value []byte
-				ts    [16]byte
-			})
-
-			for mc.Next() {
-				key := mc.Key()
-				if key == "" {
-					continue
-				}
-
-				// Preskoci ako je izbrisano
-				if mc.Tombstone() {
-					delete(records, key)
-					continue
-				}
-
-				// Sacuvaj samo najnoviju verziju
-				currTS := mc.Timestamp()
-				if existing, ok := records[key]; ok {
-					if bytes.Compare(currTS[:], existing.ts[:]) > 0 {
-						// Novi zapis ima veci timestamp, azuriraj
-						records[key] = struct {
-							value []byte
-							ts    [16]byte
-						}{
-							value: mc.Value(),
-							ts:    currTS,
-						}
-					}
-				} else {
-					// Ne postoji u records, samo dodaj
-					records[key] = struct {
-						value []byte
-						ts    [16]byte
-					}{
-						value: mc.Value(),
-						ts:    currTS,
-					}
-				}
+			// Multi cursor spaja sve Memtable-e i SSTabele koje mogu sadržati ključ iz opsega
+			mc := utils.NewScanCursor(minKey, maxKey, "", memtableInstances, lsm, cfg, bm, dict, tableCache, valueLog)
+			step := mc.Next
+			if descending {
+				step = mc.Prev
 			}
-			mc.Close() // Zatvori multicursor jer smo vec procitali sve podatke
 
-			// Sortiraj kljuceve
-			sortedKeys := make([]string, 0, len(records))
-			for k := range records {
-				if k >= minKey && k <= maxKey {
-					sortedKeys = append(sortedKeys, k)
-				}
-			}
-			sort.Strings(sortedKeys)
-
-			// Iterate petlja koja koristi vec ucitane zapise
-			currentIndex := 0
+			// Iterate petlja čita sledeći zapis tek na NEXT
+			hasRecord := step()
 		outer_range:
-			for currentIndex < len(sortedKeys) {
-				key := sortedKeys[currentIndex]
-				record := records[key]
-
-				fmt.Printf("- [%s -> %s]\n", utils.MaybeQuote(key), utils.MaybeQuote(string(record.value)))
-				fmt.Print("Naredba (NEXT/STOP): ")
+			for hasRecord {
+				fmt.Printf("- [%s -> %s]\n", utils.MaybeQuote(mc.Key()), utils.MaybeQuote(string(mc.Value())))
+				fmt.Print("Naredba (NEXT/PREV/STOP): ")
 
-				// Citanje linije iz inputa
+				// Citanje linije iz inputa. Zaključavanje ostaje uzeto jer cursor čita Memtable-e i tabele
+				// koje bi objava flush-a promenila; flusher u međuvremenu upisuje tabelu, a objavljuje je
+				// po izlasku iz petlje.
 				if !scanner.Scan() {
 					break
 				}
@@ -1625,9 +1420,21 @@ func main() {
 					break outer_range
 				case "NEXT":
 					// Predji na sledeci element
-					currentIndex++
+					hasRecord = step()
+				case "PREV":
+					// Vrati se na prethodni element (suprotno od smera iteracije); na prvom elementu
+					// cursor ostaje na mestu
+					key := mc.Key()
+					back, restore := mc.Prev, mc.Seek
+					if descending {
+						back, restore = mc.Next, mc.SeekForPrev
+					}
+					if !back() {
+						fmt.Println("Nema prethodnog elementa")
+						restore(key)
+					}
 				default:
-					fmt.Println("Nepoznata komanda. Upotrebite NEXT ili STOP")
+					fmt.Println("Nepoznata komanda. Upotrebite NEXT, PREV ili STOP")
 				}
 			}
 			fmt.Printf("Izlaz iz iterate petlje.\n")
@@ -1639,16 +1446,179 @@ func main() {
 		// HELP i EXIT komanda
 		// --------------------------------------------------------------------------------------------------------------------------
 
+		// --------------------------------------------------------------------------------------------------------------------------
+		// COMPACT i LEVELS komande
+		// --------------------------------------------------------------------------------------------------------------------------
+
+		// COMPACT bez argumenata spušta sve podatke na najniži nivo,
+		// COMPACT <nivo> kompaktuje jedan nivo, a COMPACT RANGE <od> <do> samo tabele koje sadrže opseg
+		case "COMPACT":
+			var err error
+			switch {
+			case len(parts) == 1:
+				err = strategy.CompactAll(&lsm)
+			case len(parts) == 2:
+				level, convErr := strconv.Atoi(parts[1])
+				if convErr != nil || level < 0 || level > math.MaxUint8 {
+					fmt.Println("Greška: nivo mora biti broj između 0 i 255")
+					continue
+				}
+				err = strategy.CompactLevel(&lsm, byte(level))
+			case len(parts) == 4 && strings.ToUpper(parts[1]) == "RANGE":
+				err = strategy.CompactRange(&lsm, parts[2], parts[3])
+			default:
+				fmt.Println("Greška: COMPACT [<nivo> | RANGE <od> <do>]")
+				continue
+			}
+			if err != nil {
+				fmt.Printf("Greška pri kompakciji: %v\n", err)
+				continue
+			}
+			fmt.Println("Kompakcija uspešno završena!")
+
+		case "LEVELS":
+			if len(parts) != 1 {
+				fmt.Println("Greška: LEVELS ne zahteva argumente")
+				continue
+			}
+			levels := make([]int, 0, len(lsm))
+			for level := range lsm {
+				levels = append(levels, int(level))
+			}
+			sort.Ints(levels)
+			for _, level := range levels {
+				stats, err := sstable.DescribeLevel(lsm[byte(level)], bm, cfg.BlockSize)
+				if err != nil {
+					fmt.Printf("Greška pri čitanju nivoa %d: %v\n", level, err)
+					continue
+				}
+				totalSize := int64(0)
+				totalRecords := 0
+				overlapping := false
+				for _, st := range stats {
+					totalSize += st.Size
+					totalRecords += st.Records
+					overlapping = overlapping || st.Overlaps
+				}
+				fmt.Printf("Nivo %d: %d tabela, %d zapisa, %d B, preklapanje: %t\n", level, len(stats), totalRecords, totalSize, overlapping)
+				for _, st := range stats {
+					fmt.Printf("  %s [%s - %s] zapisa: %d, veličina: %d B, preklapa se: %t\n", filepath.Base(st.Dir),
+						utils.MaybeQuote(string(st.MinKey)), utils.MaybeQuote(string(st.MaxKey)), st.Records, st.Size, st.Overlaps)
+				}
+			}
+
+		// Statistika Bloom filtera za svaku SSTabelu
+		case "FILTER_STATS":
+			if len(parts) != 1 {
+				fmt.Println("Greška: FILTER_STATS ne zahteva argumente")
+				continue
+			}
+			levels := make([]int, 0, len(lsm))
+			for level := range lsm {
+				levels = append(levels, int(level))
+			}
+			sort.Ints(levels)
+			for _, level := range levels {
+				for _, dir := range lsm[byte(level)] {
+					reader, err := tableCache.Get(dir)
+					if err != nil {
+						fmt.Printf("Greška pri čitanju tabele %s: %v\n", filepath.Base(dir), err)
+						continue
+					}
+					st := reader.Stats
+					fmt.Printf("Nivo %d %s: %d bitova, %d hash funkcija, provera: %d, negativnih: %d, tačno pozitivnih: %d, lažno pozitivnih: %d (%.4f)\n",
+						level, filepath.Base(dir), reader.Filter.Size(), reader.Filter.HashCount(), st.Probes, st.Negatives,
+						st.TruePositives, st.FalsePositives, st.FalsePositiveRate())
+				}
+			}
+
+		// Statistika keša redova
+		case "CACHE_STATS":
+			if len(parts) != 1 {
+				fmt.Println("Greška: CACHE_STATS ne zahteva argumente")
+				continue
+			}
+			st := rowCache.Stats()
+			fmt.Printf("Keš: %d unosa, %d/%d B, pogodaka: %d, promašaja: %d, stopa pogodaka: %.4f\n",
+				st.Entries, st.Bytes, st.Capacity, st.Hits, st.Misses, st.HitRatio())
+
+		// Čišćenje najstarijeg segmenta value log-a: žive vrednosti se ponovo upisuju, a segment se briše
+		case "VLOG_GC":
+			if len(parts) != 1 {
+				fmt.Println("Greška: VLOG_GC ne zahteva argumente")
+				continue
+			}
+			segment, ok := valueLog.OldestSegment()
+			if !ok {
+				fmt.Println("Value log nema neaktivnih segmenata za čišćenje")
+				continue
+			}
+			entries, err := valueLog.ReadSegment(segment)
+			if err != nil {
+				fmt.Printf("Greška pri čitanju segmenta value log-a: %v\n", err)
+				continue
+			}
+			live, reclaimed := 0, uint64(0)
+			failed := false
+			for _, entry := range entries {
+				// Upis može sačekati flush, pa se Memtable-i čitaju iznova za svaki unos
+				if !utils.IsLiveValue(entry, memtables.All(), lsm, cfg, bm, dict, tableCache) {
+					reclaimed += entry.Pointer.Length
+					continue
+				}
+				live++
+				ts, err := walInstance.AppendRecord(false, entry.Key, entry.Value)
+				if err != nil {
+					fmt.Printf("Greška pri pisanju u WAL: %v\n", err)
+					failed = true
+					break
+				}
+				utils.WriteToMemory(ts, false, string(entry.Key), entry.Value, memtables, walInstance, rowCache)
+			}
+			// Segment se briše samo ako su sve žive vrednosti bezbedno ponovo upisane
+			if failed {
+				continue
+			}
+			if err := valueLog.RemoveSegment(segment); err != nil {
+				fmt.Printf("Greška pri brisanju segmenta value log-a: %v\n", err)
+				continue
+			}
+			fmt.Printf("Segment %d value log-a očišćen: %d unosa, %d živih ponovo upisano, oslobođeno %d B mrtvih vrednosti\n",
+				segment, len(entries), live, reclaimed)
+
+		// Zauzeće value log-a
+		case "VLOG_STATS":
+			if len(parts) != 1 {
+				fmt.Println("Greška: VLOG_STATS ne zahteva argumente")
+				continue
+			}
+			st := valueLog.Stats()
+			fmt.Printf("Value log: %d segmenata, %d B, prag odvajanja: %d B\n", st.Segments, st.Bytes, st.Threshold)
+
 		case "HELP":
 			fmt.Println("Dostupne komande:")
 			fmt.Println("  PUT <ključ> <vrednost>        - Dodaje ili ažurira par")
 			fmt.Println("  GET <ključ>                   - Prikazuje vrednost za ključ")
+			fmt.Println("  MGET <ključ> [<ključ> ...]    - Prikazuje vrednosti za više ključeva")
 			fmt.Println("  DELETE <ključ>                - Briše vrednost za ključ")
-			fmt.Println("  PREFIX_SCAN <prefiks> <str> <vel> - Pretraga po prefiksu (strana, veličina)")
-			fmt.Println("  RANGE_SCAN <start> <kraj> <str> <vel> - Pretraga po opsegu (strana, veličina)")
+			fmt.Println("  DELETE_RANGE <start> <kraj>   - Briše sve ključeve iz [start, kraj)")
+			fmt.Println("  DELETE_PREFIX <prefiks>       - Briše sve ključeve sa prefiksom")
+			fmt.Println("  PREFIX_SCAN <prefiks> <str|token> <vel> [opcije] - Pretraga po prefiksu (strana ili token za nastavak, veličina)")
+			fmt.Println("  RANGE_SCAN <start> <kraj> <str|token> <vel> [opcije] - Pretraga po opsegu (strana ili token, veličina)")
+			fmt.Println("  COUNT_PREFIX <prefiks> [filteri] - Broj ključeva sa prefiksom")
+			fmt.Println("  COUNT_RANGE <start> <kraj> [filteri] - Broj ključeva u opsegu")
+			fmt.Println("    opcije: DESC (opadajuće), KEYS_ONLY (samo ključevi) i filteri vrednosti")
+			fmt.Println("    token: nastavlja iza poslednjeg ključa prethodne strane i čita trenutno stanje, ne snapshot")
+			fmt.Println("    filteri: CONTAINS <tekst>, MATCHES <regex>, LEN <op> <broj> (op: < <= > >= = !=)")
 			fmt.Println("  PREFIX_ITERATE <prefiks>      - Iterativna pretraga po prefiksu")
-			fmt.Println("  RANGE_ITERATE <start> <kraj>  - Iterativna pretraga po opsegu")
+			fmt.Println("  RANGE_ITERATE <start> <kraj> [DESC] - Iterativna pretraga po opsegu")
 			fmt.Println("  VALIDATE                      - Provera validnosti SSTabele")
+			fmt.Println("  COMPACT [<nivo> | RANGE <od> <do>] - Ručna kompakcija svih nivoa, jednog nivoa ili opsega")
+			fmt.Println("  LEVELS                        - Prikaz nivoa LSM stabla i njihovih tabela")
+			fmt.Println("  FILTER_STATS                  - Statistika Bloom filtera po SSTabeli")
+			fmt.Println("  CACHE_STATS                   - Statistika keša redova")
+			fmt.Println("  VLOG_GC                       - Čišćenje najstarijeg segmenta value log-a")
+			fmt.Println("  VLOG_STATS                    - Zauzeće value log-a")
 			fmt.Println("")
 			fmt.Println("Probabilističke strukture:")
 			fmt.Println("  BLOOM_CREATE <naziv> <očekivani> <greška>  - Kreira Bloom filter")
@@ -1676,6 +1646,7 @@ func main() {
 			fmt.Println("  <preciznost> - Preciznost za HLL (4-16)")
 
 		case "EXIT":
+			memtables.Close()
 			walInstance.WriteOnExit()
 
 			err := dict.ForceSaveToFile(dictPath, bm, cfg.BlockSize)
@@ -1691,7 +1662,50 @@ func main() {
 		}
 	}
 
+	// Kraj unosa: sačekaj upis tabele koji je u toku
+	memtables.Close()
 	if err := scanner.Err(); err != nil {
 		fmt.Println("Greška pri čitanju unosa:", err)
 	}
 }
+
+// printPage ispisuje stranu rezultata PREFIX_SCAN ili RANGE_SCAN komande. Strana je zadata brojem
+// (prvi rezultat je start+1) ili tokenom za nastavak. Tokenu za sledeću stranu (next) se dodeljuju
+// opseg skeniranja i snapshot: vreme poslednjeg upisa pri prvoj strani skeniranja. Snapshot se
+// koristi samo za napomenu o izmenama - nastavak uvek čita trenutno stanje.
+func printPage(records []cursor.ScanResult, page int, start int, token *cursor.ContinuationToken,
+	next *cursor.ContinuationToken, scope uint32, lastWrite uint64, keysOnly bool) {
+	snapshot := lastWrite
+	if token != nil {
+		snapshot = token.Snapshot
+		if lastWrite > token.Snapshot {
+			fmt.Println("Napomena: podaci su izmenjeni posle prve strane ovog skeniranja; nastavak prikazuje trenutne vrednosti")
+		}
+	}
+	if len(records) == 0 {
+		if token != nil {
+			fmt.Println("Nema više zapisa")
+		} else {
+			fmt.Printf("Nema zapisa na stranici %d\n", page)
+		}
+		return
+	}
+
+	// Prikazi rezultate
+	if token != nil {
+		fmt.Printf("Nastavak (%d rezultata):\n", len(records))
+	} else {
+		fmt.Printf("Strana %d (rezultati %d-%d):\n", page, start+1, start+len(records))
+	}
+	for _, res := range records {
+		if keysOnly {
+			fmt.Printf("- [%s]\n", utils.MaybeQuote(res.Key))
+		} else {
+			fmt.Printf("- [%s -> %s]\n", utils.MaybeQuote(res.Key), utils.MaybeQuote(string(res.Value)))
+		}
+	}
+	if next != nil {
+		next.Scope, next.Snapshot = scope, snapshot
+		fmt.Printf("Token za sledeću stranu: %s\n", next.Encode())
+	}
+}
@@ -1,67 +1,40 @@
 package blockmanager
 
+import (
+	"strconv"
+
+	"projekat/structs/cachepolicy"
+)
+
 type Signature struct {
 	path   string
 	number int
 }
 
-type BlockNode struct {
-	data      []byte
-	blocksign Signature
-	prev      *BlockNode
-	next      *BlockNode
-}
-
+// BlockCache čuva do capacity blokova; koje blokove zadržava bira politika keša
 type BlockCache struct {
-	first    *BlockNode
-	last     *BlockNode
-	hash     map[Signature]*BlockNode
-	length   int
-	capacity int
+	policy cachepolicy.Policy[Signature, []byte]
 }
 
 func NewBlockCache(cap int) *BlockCache {
-	bc := BlockCache{nil, nil, make(map[Signature]*BlockNode), 0, cap}
-	return &bc
+	return NewBlockCacheWithPolicy(cap, cachepolicy.PolicyLRU)
+}
+
+// NewBlockCacheWithPolicy pravi keš blokova sa politikom zadatom po nazivu (LRU ili TinyLFU)
+func NewBlockCacheWithPolicy(cap int, policy string) *BlockCache {
+	hash := func(sign Signature) string { return sign.path + "#" + strconv.Itoa(sign.number) }
+	return &BlockCache{policy: cachepolicy.New[Signature, []byte](policy, int64(cap), hash)}
 }
 
 func (bc *BlockCache) AddToCache(path string, number int, data []byte) {
-	sign := Signature{path, number}
-	node := BlockNode{data, sign, bc.first, nil}
-	if bc.length == 0 {
-		bc.last = &node
-	} else {
-		bc.first.next = &node
-	}
-	bc.first = &node
-	bc.hash[sign] = &node
-	if bc.length == bc.capacity {
-		bc.last = bc.last.next
-		delete(bc.hash, bc.last.prev.blocksign)
-		bc.last.prev = nil
-	} else {
-		bc.length++
-	}
+	bc.policy.Put(Signature{path, number}, data, 1)
 }
 
 func (bc *BlockCache) FindInCache(path string, number int) ([]byte, bool) {
-	sign := Signature{path, number}
-	block, ok := bc.hash[sign]
-	if !ok {
-		return []byte{}, ok
-	} else {
-		if block.next != nil {
-			block.next.prev = block.prev
-		}
-		if block.prev != nil {
-			block.prev.next = block.next
-		}
-		block.next = nil
-		if bc.first != block {
-			block.prev = bc.first
-			bc.first.next = block
-			bc.first = block
-		}
-		return block.data, ok
-	}
+	return bc.policy.Get(Signature{path, number})
+}
+
+// UpdateInCache menja sadržaj bloka ako je u kešu
+func (bc *BlockCache) UpdateInCache(path string, number int, data []byte) {
+	bc.policy.Update(Signature{path, number}, data)
 }
@@ -4,13 +4,21 @@ import (
 	"errors"
 	"io"
 	"os"
+	"sync"
 )
 
-// BlockManager struktura
+// BlockManager struktura. Čitanja i upisi preko WriteBlockAt su bezbedni za istovremeni
+// pristup (flusher upisuje tabelu dok komande čitaju): izvršavaju se pod zaključavanjem, pa
+// blok pročitan sa diska ne može u kešu pregaziti noviji upis istog bloka. WriteBlock piše na
+// poziciju Block_idx, pa ga koristi samo jedan korisnik (WAL).
 type BlockManager struct {
+	mu         sync.Mutex
 	blockCache *BlockCache
 	blockSize  int
 	Block_idx  int
+	// Broj blokova pročitanih sa diska (promašaji keša) i upisanih na disk
+	BlocksRead    int
+	BlocksWritten int
 }
 
 // Funckija koja vraća novi Block Manager
@@ -21,13 +29,22 @@ func NewBlockManager(blockSize int, capacity int) *BlockManager {
 	}
 }
 
+// NewBlockManagerWithPolicy vraća Block Manager čiji keš koristi zadatu politiku (LRU ili TinyLFU)
+func NewBlockManagerWithPolicy(blockSize int, capacity int, policy string) *BlockManager {
+	return &BlockManager{
+		blockCache: NewBlockCacheWithPolicy(capacity, policy), blockSize: blockSize,
+		Block_idx: 0,
+	}
+}
+
 // Funkcija za citanje blokova
 func (bm *BlockManager) ReadBlock(filePath string, blockIndex int) ([]byte, error) {
-	sign := Signature{filePath, blockIndex}
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
 
 	// Ako postoji u kesu
-	if blockptr, ok := bm.blockCache.hash[sign]; ok {
-		return blockptr.data, nil
+	if data, ok := bm.blockCache.FindInCache(filePath, blockIndex); ok {
+		return data, nil
 	}
 
 	// Otvori fajl
@@ -46,6 +63,7 @@ func (bm *BlockManager) ReadBlock(filePath string, blockIndex int) ([]byte, erro
 	if err != nil && err != io.EOF {
 		return nil, err
 	}
+	bm.BlocksRead++
 
 	// Dodaj u kes
 	bm.blockCache.AddToCache(filePath, blockIndex, data)
@@ -53,8 +71,17 @@ func (bm *BlockManager) ReadBlock(filePath string, blockIndex int) ([]byte, erro
 	return data, nil
 }
 
-// Funkcija za pisanje blokova
+// Funkcija za pisanje blokova na poziciju Block_idx, koja se zatim pomera na sledeći blok
 func (bm *BlockManager) WriteBlock(filePath string, data []byte) error {
+	if err := bm.WriteBlockAt(filePath, bm.Block_idx, data); err != nil {
+		return err
+	}
+	bm.Block_idx++
+	return nil
+}
+
+// WriteBlockAt zapisuje blok na zadatu poziciju u fajlu
+func (bm *BlockManager) WriteBlockAt(filePath string, blockIndex int, data []byte) error {
 	// Greška: podaci su veći od veličine bloka
 	if len(data) > bm.blockSize {
 		return errors.New("data does not fit into a block")
@@ -64,6 +91,9 @@ func (bm *BlockManager) WriteBlock(filePath string, data []byte) error {
 	padded := make([]byte, bm.blockSize)
 	copy(padded, data)
 
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
 	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE, 0644)
 	if err != nil {
 		return err
@@ -71,20 +101,16 @@ func (bm *BlockManager) WriteBlock(filePath string, data []byte) error {
 	defer file.Close()
 
 	// Zapisi blok u fajl
-	offset := int64(bm.Block_idx * bm.blockSize)
+	offset := int64(blockIndex * bm.blockSize)
 	_, err = file.WriteAt(padded, offset)
 	if err != nil {
 		return err
 	}
 
-	// Azuriraj block_idx
-	bm.Block_idx++
+	bm.BlocksWritten++
 
 	// Ažuriraj cache ako postoji
-	sign := Signature{filePath, bm.Block_idx - 1}
-	if block, ok := bm.blockCache.hash[sign]; ok {
-		block.data = padded
-	}
+	bm.blockCache.UpdateInCache(filePath, blockIndex, padded)
 
 	return nil
 }
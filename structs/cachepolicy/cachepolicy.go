@@ -0,0 +1,114 @@
+package cachepolicy
+
+import "container/list"
+
+// Policy je keš ograničen zbirom cena unosa (npr. bajtovi ili broj blokova) koji sam
+// odlučuje koje unose čuva i koje izbacuje
+type Policy[K comparable, V any] interface {
+	// Get vraća vrednost i beleži pristup ključu
+	Get(key K) (V, bool)
+	// Put dodaje ili menja unos; politika može odbiti da ga primi
+	Put(key K, value V, cost int64)
+	// Update menja vrednost postojećeg unosa bez beleženja pristupa
+	Update(key K, value V) bool
+	Remove(key K)
+	// Keys vraća sve ključeve u kešu, bez beleženja pristupa
+	Keys() []K
+	Len() int
+	Size() int64
+}
+
+// Nazivi politika u konfiguraciji
+const (
+	PolicyLRU     = "LRU"
+	PolicyTinyLFU = "TinyLFU"
+)
+
+// New pravi politiku po nazivu iz konfiguracije; nepoznat naziv daje LRU.
+// hash pretvara ključ u string za frekvencijski sketch TinyLFU politike.
+func New[K comparable, V any](name string, capacity int64, hash func(K) string) Policy[K, V] {
+	if name == PolicyTinyLFU {
+		return NewTinyLFU[K, V](capacity, hash)
+	}
+	return NewLRU[K, V](capacity)
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	cost    int64
+	segment int
+}
+
+// LRU izbacuje najdavnije korišćene unose
+type LRU[K comparable, V any] struct {
+	capacity int64
+	size     int64
+	entries  map[K]*list.Element
+	order    *list.List
+}
+
+func NewLRU[K comparable, V any](capacity int64) *LRU[K, V] {
+	return &LRU[K, V]{capacity: capacity, entries: make(map[K]*list.Element), order: list.New()}
+}
+
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	elem, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*entry[K, V]).value, true
+}
+
+func (c *LRU[K, V]) Put(key K, value V, cost int64) {
+	c.Remove(key)
+	// Unos veći od celog keša se ne čuva
+	if cost > c.capacity {
+		return
+	}
+	c.entries[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, cost: cost})
+	c.size += cost
+	for c.size > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		evicted := oldest.Value.(*entry[K, V])
+		delete(c.entries, evicted.key)
+		c.size -= evicted.cost
+	}
+}
+
+func (c *LRU[K, V]) Update(key K, value V) bool {
+	elem, ok := c.entries[key]
+	if ok {
+		elem.Value.(*entry[K, V]).value = value
+	}
+	return ok
+}
+
+func (c *LRU[K, V]) Remove(key K) {
+	elem, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	c.order.Remove(elem)
+	delete(c.entries, key)
+	c.size -= elem.Value.(*entry[K, V]).cost
+}
+
+func (c *LRU[K, V]) Keys() []K {
+	keys := make([]K, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (c *LRU[K, V]) Len() int {
+	return len(c.entries)
+}
+
+func (c *LRU[K, V]) Size() int64 {
+	return c.size
+}
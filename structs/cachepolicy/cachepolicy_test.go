@@ -0,0 +1,182 @@
+package cachepolicy
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+const (
+	benchCapacity = 500
+	benchTrace    = 1 << 16
+)
+
+// skewedTrace vraća pristupe sa Zipf raspodelom nad 10000 ključeva: mali broj ključeva
+// čini većinu pristupa
+func skewedTrace() []string {
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.1, 1, 9999)
+	trace := make([]string, benchTrace)
+	for i := range trace {
+		trace[i] = fmt.Sprint("k", zipf.Uint64())
+	}
+	return trace
+}
+
+// scanTrace smenjuje pristupe skupu od 300 često korišćenih ključeva sa skeniranjem 2000
+// ključeva koji se ne ponavljaju, kao kada RANGE_SCAN pročita veliki opseg
+func scanTrace() []string {
+	r := rand.New(rand.NewSource(2))
+	trace := make([]string, 0, benchTrace)
+	scanned := 0
+	for len(trace) < benchTrace {
+		for i := 0; i < 2000; i++ {
+			trace = append(trace, fmt.Sprint("hot", r.Intn(300)))
+		}
+		for i := 0; i < 2000; i++ {
+			trace = append(trace, fmt.Sprint("scan", scanned))
+			scanned++
+		}
+	}
+	return trace[:benchTrace]
+}
+
+// BenchmarkPolicy poredi politike na istom nizu pristupa: promašaj učitava ključ u keš.
+// Pored vremena po pristupu prijavljuje se udeo pogodaka.
+func BenchmarkPolicy(b *testing.B) {
+	workloads := []struct {
+		name  string
+		trace []string
+	}{
+		{"skewed", skewedTrace()},
+		{"scan", scanTrace()},
+	}
+	for _, workload := range workloads {
+		for _, policy := range []string{PolicyLRU, PolicyTinyLFU} {
+			b.Run(workload.name+"/"+policy, func(b *testing.B) {
+				c := New[string, int](policy, benchCapacity, func(key string) string { return key })
+				hits := 0
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					key := workload.trace[i%len(workload.trace)]
+					if _, ok := c.Get(key); ok {
+						hits++
+					} else {
+						c.Put(key, i, 1)
+					}
+				}
+				b.ReportMetric(100*float64(hits)/float64(b.N), "hit%")
+			})
+		}
+	}
+}
+
+func TestLRUEviction(t *testing.T) {
+	c := NewLRU[string, int](3)
+	c.Put("a", 1, 1)
+	c.Put("b", 2, 1)
+	c.Put("c", 3, 1)
+	c.Get("a")
+	c.Put("d", 4, 1)
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("najdavnije korišćen unos nije izbačen")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("unos %s je izbačen", key)
+		}
+	}
+	c.Put("big", 5, 4)
+	if _, ok := c.Get("big"); ok || c.Size() != 3 {
+		t.Fatalf("unos veći od keša: veličina %d", c.Size())
+	}
+}
+
+// Često korišćeni unosi ostaju u kešu dok se skeniraju ključevi koji se ne ponavljaju, a
+// ukupna cena unosa nikada ne prelazi kapacitet
+func TestTinyLFUAdmission(t *testing.T) {
+	c := NewTinyLFU[string, int](100, func(key string) string { return key })
+	access := func(key string) {
+		if _, ok := c.Get(key); !ok {
+			c.Put(key, 0, 1)
+		}
+		if c.Size() > 100 {
+			t.Fatalf("veličina keša %d prelazi kapacitet", c.Size())
+		}
+	}
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 50; i++ {
+			access(fmt.Sprint("hot", i))
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		access(fmt.Sprint("scan", i))
+	}
+	hot := 0
+	for i := 0; i < 50; i++ {
+		if _, ok := c.entries[fmt.Sprint("hot", i)]; ok {
+			hot++
+		}
+	}
+	if hot < 45 {
+		t.Fatalf("posle skeniranja u kešu je ostalo %d od 50 čestih unosa", hot)
+	}
+
+	// Sa LRU politikom isto skeniranje izbacuje sve česte unose
+	lru := NewLRU[string, int](100)
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 50; i++ {
+			lru.Put(fmt.Sprint("hot", i), 0, 1)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		lru.Put(fmt.Sprint("scan", i), 0, 1)
+	}
+	if _, ok := lru.Get("hot0"); ok {
+		t.Fatal("LRU je zadržao čest unos posle skeniranja")
+	}
+}
+
+// Kandidat iz prozora ulazi u glavni deo samo ako je češći od unosa koji bi izbacio, a drugi
+// pristup unosu iz glavnog dela ga premešta u zaštićeni segment
+func TestTinyLFUEviction(t *testing.T) {
+	c := NewTinyLFU[string, int](10, func(key string) string { return key })
+	for i := 0; i < 9; i++ {
+		key := fmt.Sprint("k", i)
+		c.Put(key, i, 1)
+		c.Get(key)
+		c.Get(key)
+	}
+	// Prozor ima mesta za jedan unos, pa novi unos gura prethodni u glavni deo
+	c.Put("w", 0, 1)
+	if c.Len() != 10 || c.Size() != 10 {
+		t.Fatalf("keš ima %d unosa veličine %d", c.Len(), c.Size())
+	}
+	// Retko korišćen kandidat ne izbacuje češće unose iz glavnog dela
+	c.Put("cold", 0, 1)
+	if _, ok := c.entries["w"]; ok {
+		t.Fatal("redak kandidat je primljen u pun glavni deo")
+	}
+	for i := 0; i < 9; i++ {
+		if _, ok := c.entries[fmt.Sprint("k", i)]; !ok {
+			t.Fatalf("čest unos k%d je izbačen", i)
+		}
+	}
+	if seg := c.entries["k0"].Value.(*entry[string, int]).segment; seg != segProbation {
+		t.Fatalf("unos primljen iz prozora je u segmentu %d", seg)
+	}
+	c.Get("k0")
+	if seg := c.entries["k0"].Value.(*entry[string, int]).segment; seg != segProtected {
+		t.Fatalf("unos korišćen više puta je u segmentu %d", seg)
+	}
+	if !c.Update("k1", 100) {
+		t.Fatal("Update postojećeg unosa")
+	}
+	if v, ok := c.Get("k1"); !ok || v != 100 {
+		t.Fatalf("k1 = %d (%v)", v, ok)
+	}
+	c.Remove("k1")
+	if _, ok := c.Get("k1"); ok || c.Len() != 9 {
+		t.Fatalf("Remove: %d unosa", c.Len())
+	}
+}
@@ -0,0 +1,185 @@
+package cachepolicy
+
+import (
+	"container/list"
+	"hash/maphash"
+
+	"projekat/structs/probabilistic"
+)
+
+// Segmenti W-TinyLFU keša
+const (
+	segWindow = iota
+	segProbation
+	segProtected
+	segCount
+)
+
+const (
+	// Udeo prozora u kapacitetu i zaštićenog segmenta u glavnom delu keša (u procentima)
+	windowPercent    = 1
+	protectedPercent = 80
+	// Tačnost frekvencijskog sketch-a
+	sketchEpsilon = 0.001
+	sketchDelta   = 0.01
+	// Brojači se prepolove nakon resetFactor pristupa po unosu keša
+	resetFactor = 10
+	minSamples  = 64
+)
+
+// TinyLFU je W-TinyLFU keš: novi unosi ulaze u mali LRU prozor, a pri izlasku iz prozora
+// ulaze u glavni (segmentirani LRU) deo samo ako im je procenjena učestalost pristupa veća od
+// učestalosti unosa koji bi bio izbačen. Učestalost se procenjuje Count-Min Sketch-om čiji se
+// brojači periodično polove, pa jedno veliko skeniranje ne može da izbaci često korišćene unose.
+// Ključ se za sketch hešira jednom (maphash), a redovi sketch-a koriste izvedene indekse.
+type TinyLFU[K comparable, V any] struct {
+	capacity     int64
+	windowCap    int64
+	mainCap      int64
+	protectedCap int64
+	segments     [segCount]*list.List
+	sizes        [segCount]int64
+	entries      map[K]*list.Element
+	sketch       probabilistic.CountMinSketch
+	hash         func(K) string
+	seed         maphash.Seed
+	samples      int
+}
+
+func NewTinyLFU[K comparable, V any](capacity int64, hash func(K) string) *TinyLFU[K, V] {
+	windowCap := max(capacity*windowPercent/100, 1)
+	mainCap := max(capacity-windowCap, 0)
+	c := &TinyLFU[K, V]{
+		capacity:     capacity,
+		windowCap:    windowCap,
+		mainCap:      mainCap,
+		protectedCap: mainCap * protectedPercent / 100,
+		entries:      make(map[K]*list.Element),
+		sketch:       probabilistic.CreateCountMinSketch(sketchEpsilon, sketchDelta),
+		hash:         hash,
+		seed:         maphash.MakeSeed(),
+	}
+	for i := range c.segments {
+		c.segments[i] = list.New()
+	}
+	return c
+}
+
+// record beleži pristup ključu u sketch-u
+func (c *TinyLFU[K, V]) record(key K) {
+	c.sketch.AddHash(c.keyHash(key))
+	c.samples++
+	if c.samples >= resetFactor*max(len(c.entries), minSamples) {
+		c.sketch.Halve()
+		c.samples = 0
+	}
+}
+
+func (c *TinyLFU[K, V]) frequency(key K) uint32 {
+	return c.sketch.FindCountHash(c.keyHash(key))
+}
+
+func (c *TinyLFU[K, V]) keyHash(key K) uint64 {
+	return maphash.String(c.seed, c.hash(key))
+}
+
+func (c *TinyLFU[K, V]) Get(key K) (V, bool) {
+	c.record(key)
+	elem, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	e := elem.Value.(*entry[K, V])
+	switch e.segment {
+	case segProbation:
+		// Drugi pristup unosu iz glavnog dela ga premešta u zaštićeni segment
+		c.move(elem, segProtected)
+		for c.sizes[segProtected] > c.protectedCap {
+			c.move(c.segments[segProtected].Back(), segProbation)
+		}
+	default:
+		c.segments[e.segment].MoveToFront(elem)
+	}
+	return e.value, true
+}
+
+// move premešta unos na početak drugog segmenta
+func (c *TinyLFU[K, V]) move(elem *list.Element, segment int) {
+	e := elem.Value.(*entry[K, V])
+	c.segments[e.segment].Remove(elem)
+	c.sizes[e.segment] -= e.cost
+	e.segment = segment
+	c.entries[e.key] = c.segments[segment].PushFront(e)
+	c.sizes[segment] += e.cost
+}
+
+func (c *TinyLFU[K, V]) Put(key K, value V, cost int64) {
+	c.Remove(key)
+	if cost > c.capacity {
+		return
+	}
+	e := &entry[K, V]{key: key, value: value, cost: cost, segment: segWindow}
+	c.entries[key] = c.segments[segWindow].PushFront(e)
+	c.sizes[segWindow] += cost
+	for c.sizes[segWindow] > c.windowCap {
+		candidate := c.segments[segWindow].Back()
+		c.remove(candidate)
+		c.admit(candidate.Value.(*entry[K, V]))
+	}
+}
+
+// admit odlučuje da li kandidat iz prozora ulazi u glavni deo keša. Dok nema mesta, kandidat
+// se poredi sa unosom koji bi bio izbačen i ulazi samo ako je češće korišćen.
+func (c *TinyLFU[K, V]) admit(e *entry[K, V]) {
+	for c.sizes[segProbation]+c.sizes[segProtected]+e.cost > c.mainCap {
+		victim := c.segments[segProbation].Back()
+		if victim == nil {
+			victim = c.segments[segProtected].Back()
+		}
+		if victim == nil || c.frequency(e.key) <= c.frequency(victim.Value.(*entry[K, V]).key) {
+			return
+		}
+		c.remove(victim)
+	}
+	e.segment = segProbation
+	c.entries[e.key] = c.segments[segProbation].PushFront(e)
+	c.sizes[segProbation] += e.cost
+}
+
+func (c *TinyLFU[K, V]) Update(key K, value V) bool {
+	elem, ok := c.entries[key]
+	if ok {
+		elem.Value.(*entry[K, V]).value = value
+	}
+	return ok
+}
+
+func (c *TinyLFU[K, V]) Remove(key K) {
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+}
+
+func (c *TinyLFU[K, V]) remove(elem *list.Element) {
+	e := elem.Value.(*entry[K, V])
+	c.segments[e.segment].Remove(elem)
+	c.sizes[e.segment] -= e.cost
+	delete(c.entries, e.key)
+}
+
+func (c *TinyLFU[K, V]) Keys() []K {
+	keys := make([]K, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (c *TinyLFU[K, V]) Len() int {
+	return len(c.entries)
+}
+
+func (c *TinyLFU[K, V]) Size() int64 {
+	return c.sizes[segWindow] + c.sizes[segProbation] + c.sizes[segProtected]
+}
@@ -0,0 +1,85 @@
+package containers
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// Arena je niz bajtova iz kog skip lista uzima memoriju za čvorove i vrednosti.
+// Memorija se nikad ne oslobađa pojedinačno - cela arena se odbacuje pri flush-u.
+// Upisuje samo jedan writer, a čitaoci pristupaju pokazivačima atomski.
+type Arena struct {
+	buf  atomic.Pointer[[]byte]
+	used uint32
+}
+
+// Pomeraj 0 se koristi kao nil pokazivač, pa arena počinje od 8
+const arenaStart = 8
+
+// newArenaBuffer pravi bafer poravnat na 8 bajtova (potrebno za atomske operacije)
+func newArenaBuffer(size int) []byte {
+	words := make([]uint64, (size+7)/8)
+	return unsafe.Slice((*byte)(unsafe.Pointer(&words[0])), len(words)*8)
+}
+
+// NewArena kreira arenu zadatog kapaciteta
+func NewArena(capacity int) *Arena {
+	a := &Arena{used: arenaStart}
+	buf := newArenaBuffer(max(capacity, 2*arenaStart))
+	a.buf.Store(&buf)
+	return a
+}
+
+// Size vraća broj zauzetih bajtova
+func (a *Arena) Size() int {
+	return int(atomic.LoadUint32(&a.used))
+}
+
+// Capacity vraća veličinu bafera
+func (a *Arena) Capacity() int {
+	return len(*a.buf.Load())
+}
+
+// Buffer vraća trenutni bafer; pomeraji dobijeni ranije važe i u kasnijim baferima
+func (a *Arena) Buffer() []byte {
+	return *a.buf.Load()
+}
+
+// alloc rezerviše size bajtova poravnatih na align i vraća pomeraj.
+// Ako bafer nije dovoljno veliki, kopira se u dvostruko veći; čitaoci koji drže stari bafer
+// i dalje vide dosledno stanje jer writer od tada piše samo u novi.
+func (a *Arena) alloc(size, align uint32) uint32 {
+	offset := (a.used + align - 1) &^ (align - 1)
+	end := offset + size
+	buf := *a.buf.Load()
+	if int(end) > len(buf) {
+		grown := newArenaBuffer(max(2*len(buf), int(end)))
+		copy(grown, buf)
+		a.buf.Store(&grown)
+	}
+	atomic.StoreUint32(&a.used, end)
+	return offset
+}
+
+// putBytes upisuje niz bajtova u arenu i vraća njegov pomeraj
+func (a *Arena) putBytes(data []byte) uint32 {
+	offset := a.alloc(uint32(len(data)), 1)
+	copy(a.Buffer()[offset:], data)
+	return offset
+}
+
+func loadUint32(buf []byte, offset uint32) uint32 {
+	return atomic.LoadUint32((*uint32)(unsafe.Pointer(&buf[offset])))
+}
+
+func storeUint32(buf []byte, offset, value uint32) {
+	atomic.StoreUint32((*uint32)(unsafe.Pointer(&buf[offset])), value)
+}
+
+func loadUint64(buf []byte, offset uint32) uint64 {
+	return atomic.LoadUint64((*uint64)(unsafe.Pointer(&buf[offset])))
+}
+
+func storeUint64(buf []byte, offset uint32, value uint64) {
+	atomic.StoreUint64((*uint64)(unsafe.Pointer(&buf[offset])), value)
+}
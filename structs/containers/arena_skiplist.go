@@ -0,0 +1,375 @@
+package containers
+
+import (
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+
+	"projekat/structs/cursor"
+	"projekat/structs/memtable"
+)
+
+// Raspored čvora u areni (poravnat na 8 bajtova):
+// [pokazivač na vrednost (8)][dužina ključa (4)][visina (4)][next pokazivači (4 * visina)][ključ]
+// Pokazivač na vrednost sadrži pomeraj (gornja 4 bajta) i dužinu (donja 4 bajta) bloka vrednosti:
+// [timestamp (16)][tombstone (1)][vrednost]
+const (
+	nodeValueOffset  = 0
+	nodeKeySizeOff   = 8
+	nodeHeightOffset = 12
+	nodeTowerOffset  = 16
+	valueHeaderSize  = 17
+)
+
+// ArenaSkipList je skip lista čiji su čvorovi i vrednosti smešteni u areni.
+// Upisuje jedan writer, a čitaoci prolaze kroz listu bez zaključavanja: novi čvor se
+// povezuje odozdo nagore atomskim upisom pokazivača, a izmena vrednosti postojećeg ključa
+// atomski zamenjuje pokazivač na novi blok vrednosti.
+type ArenaSkipList struct {
+	arena     *Arena
+	head      uint32
+	maxHeight int
+}
+
+func NewArenaSkipList(maxHeight, capacity int) *ArenaSkipList {
+	maxHeight = max(maxHeight, 1)
+	sl := &ArenaSkipList{arena: NewArena(capacity), maxHeight: maxHeight}
+	sl.head = sl.arena.alloc(uint32(nodeTowerOffset+4*maxHeight), 8)
+	storeUint32(sl.arena.Buffer(), sl.head+nodeHeightOffset, uint32(maxHeight))
+	return sl
+}
+
+// randomHeight bira visinu novog čvora; svaki sledeći nivo ima verovatnoću 1/4
+func (sl *ArenaSkipList) randomHeight() int {
+	height := 1
+	for height < sl.maxHeight && rand.Uint32()&3 == 0 {
+		height++
+	}
+	return height
+}
+
+func (sl *ArenaSkipList) next(buf []byte, node uint32, level int) uint32 {
+	return loadUint32(buf, node+nodeTowerOffset+4*uint32(level))
+}
+
+func (sl *ArenaSkipList) key(buf []byte, node uint32) []byte {
+	keySize := loadUint32(buf, node+nodeKeySizeOff)
+	height := loadUint32(buf, node+nodeHeightOffset)
+	start := node + nodeTowerOffset + 4*height
+	return buf[start : start+keySize]
+}
+
+// valueBlock vraća blok vrednosti na koji pokazuje vrednost pokazivača
+func valueBlock(buf []byte, ptr uint64) []byte {
+	offset, size := uint32(ptr>>32), uint32(ptr)
+	return buf[offset : offset+size : offset+size]
+}
+
+// findPrev popunjava prev poslednjim čvorom < key na svakom nivou i vraća čvor sa ključem key (0 ako ga nema)
+func (sl *ArenaSkipList) findPrev(buf []byte, key string, prev []uint32) uint32 {
+	node := sl.head
+	for level := sl.maxHeight - 1; level >= 0; level-- {
+		for {
+			nxt := sl.next(buf, node, level)
+			if nxt == 0 || string(sl.key(buf, nxt)) >= key {
+				break
+			}
+			node = nxt
+		}
+		if prev != nil {
+			prev[level] = node
+		}
+	}
+	candidate := sl.next(buf, node, 0)
+	if candidate != 0 && string(sl.key(buf, candidate)) == key {
+		return candidate
+	}
+	return 0
+}
+
+// seekLE vraća poslednji čvor čiji je ključ < key (ili <= key ako je inclusive); head ako takav ne postoji
+func (sl *ArenaSkipList) seekLE(buf []byte, key string, inclusive bool) uint32 {
+	node := sl.head
+	for level := sl.maxHeight - 1; level >= 0; level-- {
+		for {
+			nxt := sl.next(buf, node, level)
+			if nxt == 0 {
+				break
+			}
+			nxtKey := string(sl.key(buf, nxt))
+			if nxtKey > key || (nxtKey == key && !inclusive) {
+				break
+			}
+			node = nxt
+		}
+	}
+	return node
+}
+
+// putValue upisuje blok vrednosti i vraća pokazivač na njega
+func (sl *ArenaSkipList) putValue(ts [16]byte, tombstone bool, value []byte) uint64 {
+	size := uint32(valueHeaderSize + len(value))
+	offset := sl.arena.alloc(size, 1)
+	buf := sl.arena.Buffer()
+	copy(buf[offset:], ts[:])
+	buf[offset+16] = memtable.BoolToByte(tombstone)
+	copy(buf[offset+valueHeaderSize:], value)
+	return uint64(offset)<<32 | uint64(size)
+}
+
+// Put upisuje zapis i vraća true ako je ključ nov. Sme ga pozivati samo jedan writer istovremeno.
+func (sl *ArenaSkipList) Put(ts [16]byte, tombstone bool, key string, value []byte) bool {
+	prev := make([]uint32, sl.maxHeight)
+	existing := sl.findPrev(sl.arena.Buffer(), key, prev)
+	valuePtr := sl.putValue(ts, tombstone, value)
+	if existing != 0 {
+		storeUint64(sl.arena.Buffer(), existing+nodeValueOffset, valuePtr)
+		return false
+	}
+
+	height := sl.randomHeight()
+	node := sl.arena.alloc(uint32(nodeTowerOffset+4*height+len(key)), 8)
+	buf := sl.arena.Buffer()
+	storeUint64(buf, node+nodeValueOffset, valuePtr)
+	storeUint32(buf, node+nodeKeySizeOff, uint32(len(key)))
+	storeUint32(buf, node+nodeHeightOffset, uint32(height))
+	copy(buf[node+nodeTowerOffset+4*uint32(height):], key)
+	// Čvor se povezuje odozdo nagore, pa čitalac koji ga vidi na višem nivou vidi ga i na nižim
+	for level := 0; level < height; level++ {
+		tower := nodeTowerOffset + 4*uint32(level)
+		storeUint32(buf, node+tower, sl.next(buf, prev[level], level))
+		storeUint32(buf, prev[level]+tower, node)
+	}
+	return true
+}
+
+// Get vraća blok vrednosti za ključ
+func (sl *ArenaSkipList) Get(key string) ([]byte, bool) {
+	buf := sl.arena.Buffer()
+	node := sl.findPrev(buf, key, nil)
+	if node == 0 {
+		return nil, false
+	}
+	return valueBlock(buf, loadUint64(buf, node+nodeValueOffset)), true
+}
+
+// decodeValueBlock razdvaja blok vrednosti na timestamp, tombstone i vrednost
+func decodeValueBlock(block []byte) ([16]byte, bool, []byte) {
+	var ts [16]byte
+	copy(ts[:], block[:16])
+	return ts, block[16] == 1, block[valueHeaderSize:]
+}
+
+// --------------------------------------------------------------------------------------------------------------------------
+// Arena SkipList Memtable
+// --------------------------------------------------------------------------------------------------------------------------
+
+// ArenaSkipListMemtable je Memtable nad skip listom u areni. Upisi se serijalizuju mutex-om,
+// dok Get i cursori čitaju bez zaključavanja i mogu da rade uporedo sa upisima. Red Memtable-a
+// (memtable.Memtables) ipak pristupa Memtable-ima samo pod svojim zaključavanjem, pa to u
+// komandama CLI-ja još nije iskorišćeno.
+type ArenaSkipListMemtable struct {
+	mu        sync.Mutex
+	data      atomic.Pointer[ArenaSkipList]
+	size      atomic.Int64
+	maxHeight int
+	maxSize   int
+	// Memorijski budžet arene u bajtovima; Memtable je pun kada ga arena dostigne (0 - bez ograničenja)
+	arenaBytes int
+	// Ograničenje zauzeća iz MaxMemtableBytes; kada nije zadato odlučuje broj zapisa
+	maxBytes  int
+	watermark uint32
+	memtable.RangeTombstoneList
+}
+
+// Početni kapacitet arene kada budžet nije zadat
+const defaultArenaCapacity = 4096
+
+func NewArenaSkipListMemtable(maxHeight, maxSize, maxBytes, arenaBytes int) *ArenaSkipListMemtable {
+	m := &ArenaSkipListMemtable{maxHeight: maxHeight, maxSize: maxSize, maxBytes: maxBytes, arenaBytes: arenaBytes}
+	m.data.Store(m.newList())
+	return m
+}
+
+func (m *ArenaSkipListMemtable) newList() *ArenaSkipList {
+	capacity := m.arenaBytes
+	if capacity <= 0 {
+		capacity = defaultArenaCapacity
+	}
+	return NewArenaSkipList(m.maxHeight, capacity)
+}
+
+func (m *ArenaSkipListMemtable) Add(ts [16]byte, tombstone bool, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.data.Load().Put(ts, tombstone, key, value) {
+		m.size.Add(1)
+	}
+	return nil
+}
+
+// Delete upisuje novi blok vrednosti sa tombstone-om; ključ koji ne postoji dobija novi čvor
+func (m *ArenaSkipListMemtable) Delete(ts [16]byte, key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sl := m.data.Load()
+	live := false
+	if block, exists := sl.Get(key); exists {
+		_, tombstone, _ := decodeValueBlock(block)
+		live = !tombstone
+	}
+	if sl.Put(ts, true, key, []byte{}) {
+		m.size.Add(1)
+	}
+	return live
+}
+
+func (m *ArenaSkipListMemtable) Get(key string) ([]byte, bool, bool) {
+	block, exists := m.data.Load().Get(key)
+	if !exists {
+		return []byte{}, false, false
+	}
+	_, tombstone, value := decodeValueBlock(block)
+	return value, tombstone, true
+}
+
+// Flush vraća zapise sortirane po ključu i zamenjuje arenu novom. Vrednosti se ne kopiraju -
+// stara arena ostaje živa dok se zapisi ne upišu.
+func (m *ArenaSkipListMemtable) Flush() *[]memtable.Record {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sl := m.data.Load()
+	records := make([]memtable.Record, 0, m.size.Load())
+	buf := sl.arena.Buffer()
+	for node := sl.next(buf, sl.head, 0); node != 0; node = sl.next(buf, node, 0) {
+		ts, tombstone, value := decodeValueBlock(valueBlock(buf, loadUint64(buf, node+nodeValueOffset)))
+		records = append(records, memtable.Record{Timestamp: ts, Tombstone: tombstone, Key: string(sl.key(buf, node)), Value: value})
+	}
+	m.data.Store(m.newList())
+	m.size.Store(0)
+	return &records
+}
+
+// IsFull vraća true kada je dostignut memorijski budžet arene, MaxMemtableBytes ili broj zapisa
+func (m *ArenaSkipListMemtable) IsFull() bool {
+	size := m.SizeBytes()
+	if m.arenaBytes > 0 && size >= m.arenaBytes {
+		return true
+	}
+	if m.maxBytes > 0 {
+		return size >= m.maxBytes
+	}
+	return int(m.size.Load()) >= m.maxSize
+}
+
+// SizeBytes vraća zauzeće arene; uključuje i stare verzije vrednosti jer se memorija ne oslobađa do flush-a
+func (m *ArenaSkipListMemtable) SizeBytes() int {
+	return m.data.Load().arena.Size()
+}
+
+func (m *ArenaSkipListMemtable) SetWatermark(index uint32) {
+	m.watermark = max(m.watermark, index)
+}
+
+func (m *ArenaSkipListMemtable) GetWatermark() uint32 {
+	return m.watermark
+}
+
+// --------------------------------------------------------------------------------------------------------------------------
+// Arena SkipList cursor
+// --------------------------------------------------------------------------------------------------------------------------
+
+// ArenaSkipListCursor čita listu bez zaključavanja; pokazivač na vrednost se čita jednom
+// po poziciji, pa ključ, vrednost, timestamp i tombstone uvek pripadaju istoj verziji
+type ArenaSkipListCursor struct {
+	list    *ArenaSkipList
+	current uint32 // Trenutni čvor; head znači da cursor nije na zapisu
+	block   []byte // Blok vrednosti trenutnog čvora
+}
+
+// NewCursor pravi cursor nad trenutnom skip listom
+func (m *ArenaSkipListMemtable) NewCursor() cursor.Cursor {
+	sl := m.data.Load()
+	return &ArenaSkipListCursor{list: sl, current: sl.head}
+}
+
+// moveTo pozicionira cursor na čvor i učitava njegov blok vrednosti
+func (c *ArenaSkipListCursor) moveTo(node uint32) bool {
+	c.current, c.block = node, nil
+	if node == 0 || node == c.list.head {
+		return false
+	}
+	buf := c.list.arena.Buffer()
+	c.block = valueBlock(buf, loadUint64(buf, node+nodeValueOffset))
+	return true
+}
+
+// Seek pozicionira cursor na prvi element koji je >= minKey
+func (c *ArenaSkipListCursor) Seek(minKey string) bool {
+	if c.list == nil {
+		return false
+	}
+	buf := c.list.arena.Buffer()
+	return c.moveTo(c.list.next(buf, c.list.seekLE(buf, minKey, false), 0))
+}
+
+// Next pomera cursor na sledeći element
+func (c *ArenaSkipListCursor) Next() bool {
+	if c.list == nil || c.current == 0 {
+		return false
+	}
+	return c.moveTo(c.list.next(c.list.arena.Buffer(), c.current, 0))
+}
+
+// SeekForPrev pozicionira cursor na poslednji element koji je <= maxKey
+func (c *ArenaSkipListCursor) SeekForPrev(maxKey string) bool {
+	if c.list == nil {
+		return false
+	}
+	return c.moveTo(c.list.seekLE(c.list.arena.Buffer(), maxKey, true))
+}
+
+// Prev pomera cursor na prethodni element
+func (c *ArenaSkipListCursor) Prev() bool {
+	if c.list == nil || c.block == nil {
+		return false
+	}
+	return c.moveTo(c.list.seekLE(c.list.arena.Buffer(), c.Key(), false))
+}
+
+// Getter za key
+func (c *ArenaSkipListCursor) Key() string {
+	if c.block == nil {
+		return ""
+	}
+	return string(c.list.key(c.list.arena.Buffer(), c.current))
+}
+
+// Getter za value
+func (c *ArenaSkipListCursor) Value() []byte {
+	if c.block == nil {
+		return nil
+	}
+	return c.block[valueHeaderSize:]
+}
+
+// Getter za timestamp
+func (c *ArenaSkipListCursor) Timestamp() [16]byte {
+	if c.block == nil {
+		return [16]byte{}
+	}
+	ts, _, _ := decodeValueBlock(c.block)
+	return ts
+}
+
+// Getter za tombstone
+func (c *ArenaSkipListCursor) Tombstone() bool {
+	return c.block != nil && c.block[16] == 1
+}
+
+// Funckija za reset cursora
+func (c *ArenaSkipListCursor) Close() {
+	c.list = nil
+	c.current = 0
+	c.block = nil
+}
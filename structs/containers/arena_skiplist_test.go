@@ -0,0 +1,104 @@
+package containers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Get i cursori čitaju bez zaključavanja dok writer upisuje i arena raste kopiranjem u veći
+// bafer; svaki pročitan zapis mora biti cela verzija svog ključa, a obilazak rastući.
+// Test ima smisla pre svega sa -race.
+func TestArenaSkipListConcurrentReads(t *testing.T) {
+	const keys = 2000
+	m := NewArenaSkipListMemtable(8, 1<<30, 0, 64)
+	startCapacity := m.data.Load().arena.Capacity()
+	key := func(i int) string { return fmt.Sprintf("k%05d", i) }
+	// Vrednost nosi ključ i verziju, a verzija je i u timestamp-u
+	value := func(k string, version int) string { return k + "/" + strings.Repeat("v", version) }
+
+	done := make(chan struct{})
+	var writer sync.WaitGroup
+	writer.Add(1)
+	go func() {
+		defer writer.Done()
+		defer close(done)
+		for version := 1; version <= 2; version++ {
+			for i := 0; i < keys; i++ {
+				// Drugi prolaz ide unazad, pa nove verzije nastaju i ispred i iza čitalaca
+				j := i
+				if version == 2 {
+					j = keys - 1 - i
+				}
+				m.Add(testTimestamp(version), false, key(j), []byte(value(key(j), version)))
+			}
+		}
+	}()
+
+	check := func(k string, v []byte, ts [16]byte) error {
+		version := int(ts[0])
+		if version < 1 || version > 2 || string(v) != value(k, version) {
+			return fmt.Errorf("ključ %s ima vrednost %q sa verzijom %d", k, v, version)
+		}
+		return nil
+	}
+	errs := make(chan error, 8)
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func(r int) {
+			defer readers.Done()
+			for n := 0; ; n++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if r%2 == 0 {
+					k := key((n * 7919) % keys)
+					if v, tombstone, found := m.Get(k); found && (tombstone || !strings.HasPrefix(string(v), k+"/")) {
+						errs <- fmt.Errorf("Get(%s) vratio %q (tombstone %v)", k, v, tombstone)
+						return
+					}
+					continue
+				}
+				c := m.NewCursor()
+				prev := ""
+				for ok := c.Seek(key(n % keys)); ok; ok = c.Next() {
+					if c.Key() <= prev {
+						errs <- fmt.Errorf("obilazak nije rastući: %s posle %s", c.Key(), prev)
+						return
+					}
+					if err := check(c.Key(), c.Value(), c.Timestamp()); err != nil {
+						errs <- err
+						return
+					}
+					prev = c.Key()
+				}
+				c.Close()
+			}
+		}(r)
+	}
+	writer.Wait()
+	readers.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	if m.data.Load().arena.Capacity() <= startCapacity {
+		t.Fatal("arena nije rasla tokom upisa")
+	}
+	c := m.NewCursor()
+	count := 0
+	for ok := c.Seek(""); ok; ok = c.Next() {
+		if err := check(c.Key(), c.Value(), c.Timestamp()); err != nil || c.Timestamp()[0] != 2 {
+			t.Fatalf("posle upisa %s nema poslednju verziju (%v)", c.Key(), err)
+		}
+		count++
+	}
+	if count != keys {
+		t.Fatalf("obilazak vraća %d ključeva, očekivano %d", count, keys)
+	}
+}
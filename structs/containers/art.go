@@ -0,0 +1,385 @@
+package containers
+
+import (
+	"bytes"
+	"sort"
+
+	"projekat/structs/memtable"
+)
+
+// Adaptivno radix stablo (ART). Unutrašnji čvorovi menjaju veličinu prema broju dece
+// (4, 16, 48 ili 256), a zajednički delovi ključeva se čuvaju jednom, u prefiksu čvora.
+// Ključ koji se završava u čvoru čuva se u samom čvoru (leaf) i manji je od svih ključeva dece,
+// pa nije potreban poseban bajt za kraj ključa.
+
+type artKind uint8
+
+const (
+	artNode4 artKind = iota
+	artNode16
+	artNode48
+	artNode256
+)
+
+type artNode struct {
+	kind   artKind
+	prefix []byte           // Deo ključa između roditelja i ovog čvora
+	leaf   *memtable.Record // Zapis čiji se ključ završava u ovom čvoru
+	keys   []byte           // Node4/Node16: sortirani bajtovi dece
+	index  *[256]uint8      // Node48: bajt -> pozicija deteta + 1
+	count  int              // Broj dece
+	// Node4/Node16: deca u redosledu keys; Node48: deca na pozicijama iz index-a; Node256: po bajtu
+	children []*artNode
+}
+
+type ART struct {
+	root *artNode
+}
+
+func NewART() *ART {
+	return &ART{root: newArtNode4(nil)}
+}
+
+func newArtNode4(prefix []byte) *artNode {
+	return &artNode{kind: artNode4, prefix: prefix, keys: make([]byte, 0, 4), children: make([]*artNode, 0, 4)}
+}
+
+// newArtLeaf pravi čvor bez dece koji nosi zapis; ostatak ključa je prefiks čvora
+func newArtLeaf(rest []byte, record memtable.Record) *artNode {
+	n := newArtNode4(append([]byte{}, rest...))
+	n.leaf = &record
+	return n
+}
+
+// child vraća dete za bajt b
+func (n *artNode) child(b byte) *artNode {
+	switch n.kind {
+	case artNode4, artNode16:
+		i := sort.Search(len(n.keys), func(i int) bool { return n.keys[i] >= b })
+		if i < len(n.keys) && n.keys[i] == b {
+			return n.children[i]
+		}
+	case artNode48:
+		if pos := n.index[b]; pos != 0 {
+			return n.children[pos-1]
+		}
+	case artNode256:
+		return n.children[b]
+	}
+	return nil
+}
+
+// setChild zamenjuje postojeće dete za bajt b
+func (n *artNode) setChild(b byte, c *artNode) {
+	switch n.kind {
+	case artNode4, artNode16:
+		i := sort.Search(len(n.keys), func(i int) bool { return n.keys[i] >= b })
+		n.children[i] = c
+	case artNode48:
+		n.children[n.index[b]-1] = c
+	case artNode256:
+		n.children[b] = c
+	}
+}
+
+// addChild dodaje novo dete, a pun čvor prethodno prelazi u sledeću veličinu
+func (n *artNode) addChild(b byte, c *artNode) {
+	switch {
+	case n.kind == artNode4 && n.count == 4:
+		n.grow(artNode16)
+	case n.kind == artNode16 && n.count == 16:
+		n.grow(artNode48)
+	case n.kind == artNode48 && n.count == 48:
+		n.grow(artNode256)
+	}
+	switch n.kind {
+	case artNode4, artNode16:
+		i := sort.Search(len(n.keys), func(i int) bool { return n.keys[i] >= b })
+		n.keys = append(n.keys, 0)
+		n.children = append(n.children, nil)
+		copy(n.keys[i+1:], n.keys[i:])
+		copy(n.children[i+1:], n.children[i:])
+		n.keys[i], n.children[i] = b, c
+	case artNode48:
+		n.children = append(n.children, c)
+		n.index[b] = uint8(len(n.children))
+	case artNode256:
+		n.children[b] = c
+	}
+	n.count++
+}
+
+// grow prebacuje decu u veći tip čvora
+func (n *artNode) grow(kind artKind) {
+	type entry struct {
+		b byte
+		c *artNode
+	}
+	entries := make([]entry, 0, n.count)
+	n.eachChild(func(b byte, c *artNode) bool {
+		entries = append(entries, entry{b, c})
+		return true
+	})
+	n.kind, n.keys, n.index, n.count = kind, nil, nil, 0
+	switch kind {
+	case artNode16:
+		n.keys, n.children = make([]byte, 0, 16), make([]*artNode, 0, 16)
+	case artNode48:
+		n.index, n.children = new([256]uint8), make([]*artNode, 0, 48)
+	case artNode256:
+		n.children = make([]*artNode, 256)
+	}
+	for _, e := range entries {
+		n.addChild(e.b, e.c)
+	}
+}
+
+// eachChild obilazi decu rastuće po bajtu dok fn vraća true
+func (n *artNode) eachChild(fn func(byte, *artNode) bool) {
+	n.childrenFrom(0, fn)
+}
+
+// childrenFrom obilazi decu sa bajtom >= from rastuće po bajtu dok fn vraća true
+func (n *artNode) childrenFrom(from int, fn func(byte, *artNode) bool) {
+	switch n.kind {
+	case artNode4, artNode16:
+		for i := sort.Search(len(n.keys), func(i int) bool { return int(n.keys[i]) >= from }); i < len(n.keys); i++ {
+			if !fn(n.keys[i], n.children[i]) {
+				return
+			}
+		}
+	default:
+		for b := from; b < 256; b++ {
+			if c := n.child(byte(b)); c != nil && !fn(byte(b), c) {
+				return
+			}
+		}
+	}
+}
+
+// childrenDownFrom obilazi decu sa bajtom <= from opadajuće po bajtu dok fn vraća true
+func (n *artNode) childrenDownFrom(from int, fn func(byte, *artNode) bool) {
+	switch n.kind {
+	case artNode4, artNode16:
+		for i := sort.Search(len(n.keys), func(i int) bool { return int(n.keys[i]) > from }) - 1; i >= 0; i-- {
+			if !fn(n.keys[i], n.children[i]) {
+				return
+			}
+		}
+	default:
+		for b := from; b >= 0; b-- {
+			if c := n.child(byte(b)); c != nil && !fn(byte(b), c) {
+				return
+			}
+		}
+	}
+}
+
+// commonPrefix vraća dužinu zajedničkog početka
+func commonPrefix(a, b []byte) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// Search vraća zapis za ključ
+func (t *ART) Search(key string) *memtable.Record {
+	k := []byte(key)
+	n, depth := t.root, 0
+	for n != nil {
+		if !bytes.HasPrefix(k[depth:], n.prefix) {
+			return nil
+		}
+		depth += len(n.prefix)
+		if depth == len(k) {
+			return n.leaf
+		}
+		n = n.child(k[depth])
+		depth++
+	}
+	return nil
+}
+
+// Insert upisuje zapis i vraća true ako je ključ nov
+func (t *ART) Insert(record memtable.Record) bool {
+	k := []byte(record.Key)
+	n, depth := t.root, 0
+	var parent *artNode
+	var parentByte byte
+	for {
+		common := commonPrefix(n.prefix, k[depth:])
+		if common < len(n.prefix) {
+			// Ključ se odvaja usred prefiksa: novi čvor preuzima zajednički deo
+			split := newArtNode4(n.prefix[:common:common])
+			split.addChild(n.prefix[common], n)
+			n.prefix = n.prefix[common+1:]
+			if depth+common == len(k) {
+				split.leaf = &record
+			} else {
+				split.addChild(k[depth+common], newArtLeaf(k[depth+common+1:], record))
+			}
+			if parent == nil {
+				t.root = split
+			} else {
+				parent.setChild(parentByte, split)
+			}
+			return true
+		}
+		depth += common
+		if depth == len(k) {
+			isNew := n.leaf == nil
+			n.leaf = &record
+			return isNew
+		}
+		next := n.child(k[depth])
+		if next == nil {
+			n.addChild(k[depth], newArtLeaf(k[depth+1:], record))
+			return true
+		}
+		parent, parentByte = n, k[depth]
+		n = next
+		depth++
+	}
+}
+
+// minimum vraća najmanji zapis u podstablu
+func (n *artNode) minimum() *memtable.Record {
+	for n != nil {
+		if n.leaf != nil {
+			return n.leaf
+		}
+		var first *artNode
+		n.eachChild(func(_ byte, c *artNode) bool {
+			first = c
+			return false
+		})
+		n = first
+	}
+	return nil
+}
+
+// maximum vraća najveći zapis u podstablu
+func (n *artNode) maximum() *memtable.Record {
+	for n != nil {
+		var last *artNode
+		n.childrenDownFrom(255, func(_ byte, c *artNode) bool {
+			last = c
+			return false
+		})
+		if last == nil {
+			return n.leaf
+		}
+		n = last
+	}
+	return nil
+}
+
+// ceiling vraća najmanji zapis iz podstabla čiji je ključ >= key (> key ako je strict).
+// depth je broj bajtova ključa koji su pređeni do početka prefiksa čvora.
+func (n *artNode) ceiling(key []byte, depth int, strict bool) *memtable.Record {
+	rest := key[depth:]
+	common := commonPrefix(n.prefix, rest)
+	if common < len(n.prefix) {
+		// Svi ključevi podstabla su veći ako je ključ kraći ili ima manji bajt na mestu razlike
+		if common == len(rest) || rest[common] < n.prefix[common] {
+			return n.minimum()
+		}
+		return nil
+	}
+	depth += common
+	if depth == len(key) {
+		if !strict && n.leaf != nil {
+			return n.leaf
+		}
+		var result *memtable.Record
+		n.eachChild(func(_ byte, c *artNode) bool {
+			result = c.minimum()
+			return result == nil
+		})
+		return result
+	}
+	b := key[depth]
+	var result *memtable.Record
+	if c := n.child(b); c != nil {
+		result = c.ceiling(key, depth+1, strict)
+	}
+	if result == nil {
+		n.childrenFrom(int(b)+1, func(_ byte, c *artNode) bool {
+			result = c.minimum()
+			return result == nil
+		})
+	}
+	return result
+}
+
+// floor vraća najveći zapis iz podstabla čiji je ključ <= key (< key ako je strict)
+func (n *artNode) floor(key []byte, depth int, strict bool) *memtable.Record {
+	rest := key[depth:]
+	common := commonPrefix(n.prefix, rest)
+	if common < len(n.prefix) {
+		// Svi ključevi podstabla su manji samo ako ključ ima veći bajt na mestu razlike
+		if common < len(rest) && rest[common] > n.prefix[common] {
+			return n.maximum()
+		}
+		return nil
+	}
+	depth += common
+	if depth == len(key) {
+		if !strict {
+			return n.leaf
+		}
+		return nil
+	}
+	b := key[depth]
+	var result *memtable.Record
+	if c := n.child(b); c != nil {
+		result = c.floor(key, depth+1, strict)
+	}
+	if result == nil {
+		n.childrenDownFrom(int(b)-1, func(_ byte, c *artNode) bool {
+			result = c.maximum()
+			return result == nil
+		})
+	}
+	if result == nil {
+		result = n.leaf
+	}
+	return result
+}
+
+// findPrefix vraća koren podstabla sa svim ključevima koji počinju prefiksom i broj bajtova
+// pređenih do početka njegovog prefiksa; nil ako takvih ključeva nema. depth je dubina čvora n,
+// a prvih depth bajtova prefiksa mora odgovarati putanji do njega.
+func (n *artNode) findPrefix(prefix []byte, depth int) (*artNode, int) {
+	for n != nil {
+		rest := prefix[depth:]
+		common := commonPrefix(n.prefix, rest)
+		if common == len(rest) {
+			return n, depth
+		}
+		if common < len(n.prefix) {
+			return nil, 0
+		}
+		depth += common
+		n = n.child(prefix[depth])
+		depth++
+	}
+	return nil, 0
+}
+
+// InOrder obilazi zapise rastuće po ključu
+func (t *ART) InOrder(fn func(*memtable.Record)) {
+	var walk func(n *artNode)
+	walk = func(n *artNode) {
+		if n.leaf != nil {
+			fn(n.leaf)
+		}
+		n.eachChild(func(_ byte, c *artNode) bool {
+			walk(c)
+			return true
+		})
+	}
+	walk(t.root)
+}
@@ -0,0 +1,214 @@
+package containers
+
+import (
+	"strings"
+
+	"projekat/structs/cursor"
+	"projekat/structs/memtable"
+)
+
+// ARTMemtable implementira MemtableInterface nad adaptivnim radix stablom; pogodan je za
+// ključeve sa dugim zajedničkim prefiksima jer se svaki prefiks čuva samo jednom
+type ARTMemtable struct {
+	tree      *ART
+	size      int
+	maxSize   int
+	watermark uint32
+	memtable.RangeTombstoneList
+	memtable.ByteCounter
+}
+
+func NewARTMemtable(maxSize, maxBytes int) *ARTMemtable {
+	return &ARTMemtable{
+		tree:        NewART(),
+		maxSize:     maxSize,
+		ByteCounter: memtable.NewByteCounter(maxBytes),
+	}
+}
+
+func (m *ARTMemtable) Add(ts [16]byte, tombstone bool, key string, value []byte) error {
+	old := m.tree.Search(key)
+	if old != nil {
+		m.TrackWrite(key, old.Value, true, value)
+	} else {
+		m.TrackWrite(key, nil, false, value)
+	}
+	if m.tree.Insert(memtable.Record{Timestamp: ts, Tombstone: tombstone, Key: key, Value: value}) {
+		m.size++
+	}
+	return nil
+}
+
+// Delete upisuje tombstone za ključ, i kada ga nema u stablu
+func (m *ARTMemtable) Delete(ts [16]byte, key string) bool {
+	record := m.tree.Search(key)
+	live := record != nil && !record.Tombstone
+	m.Add(ts, true, key, []byte{})
+	return live
+}
+
+func (m *ARTMemtable) Get(key string) ([]byte, bool, bool) {
+	record := m.tree.Search(key)
+	if record == nil {
+		return []byte{}, false, false
+	}
+	return record.Value, record.Tombstone, true
+}
+
+func (m *ARTMemtable) Flush() *[]memtable.Record {
+	records := make([]memtable.Record, 0, m.size)
+	m.tree.InOrder(func(r *memtable.Record) {
+		records = append(records, *r)
+	})
+	m.tree = NewART()
+	m.size = 0
+	m.ResetBytes()
+	return &records
+}
+
+func (m *ARTMemtable) IsFull() bool {
+	return m.BytesFull(m.size, m.maxSize)
+}
+
+func (m *ARTMemtable) SetWatermark(index uint32) {
+	m.watermark = max(m.watermark, index)
+}
+
+func (m *ARTMemtable) GetWatermark() uint32 {
+	return m.watermark
+}
+
+// --------------------------------------------------------------------------------------------------------------------------
+// ART cursor
+// --------------------------------------------------------------------------------------------------------------------------
+
+// ARTCursor se pozicionira pretragom stabla (ceiling/floor), pa ne drži stek čvorova.
+// Posle SetPrefix sve pretrage kreću od korena podstabla sa prefiksom.
+type ARTCursor struct {
+	root    *artNode
+	depth   int    // Broj bajtova ključa pređenih do korena
+	path    string // Ti bajtovi (zajednički početak svih ključeva podstabla)
+	current *memtable.Record
+	state   int // -1 pre prvog zapisa, 0 na zapisu, 1 posle poslednjeg
+}
+
+// NewCursor pravi cursor nad celim stablom
+func (m *ARTMemtable) NewCursor() cursor.Cursor {
+	return &ARTCursor{root: m.tree.root, state: -1}
+}
+
+// SetPrefix ograničava cursor na podstablo ključeva sa prefiksom: stablo se spušta samo
+// jednom, a sve kasnije pretrage kreću od korena podstabla
+func (c *ARTCursor) SetPrefix(prefix string) {
+	if c.root == nil || !strings.HasPrefix(prefix, c.path) {
+		return
+	}
+	c.root, c.depth = c.root.findPrefix([]byte(prefix), c.depth)
+	c.path = prefix[:c.depth]
+	c.current, c.state = nil, -1
+}
+
+// ceiling i floor prvo porede ključ sa putanjom do korena: ključ koji ne počinje njome je
+// ili manji ili veći od svih ključeva podstabla
+func (c *ARTCursor) ceiling(key string, strict bool) *memtable.Record {
+	switch {
+	case c.root == nil:
+		return nil
+	case strings.HasPrefix(key, c.path):
+		return c.root.ceiling([]byte(key), c.depth, strict)
+	case key < c.path:
+		return c.root.minimum()
+	}
+	return nil
+}
+
+func (c *ARTCursor) floor(key string, strict bool) *memtable.Record {
+	switch {
+	case c.root == nil:
+		return nil
+	case strings.HasPrefix(key, c.path):
+		return c.root.floor([]byte(key), c.depth, strict)
+	case key > c.path:
+		return c.root.maximum()
+	}
+	return nil
+}
+
+// moveTo pozicionira cursor na zapis; state je stanje ako zapis ne postoji
+func (c *ARTCursor) moveTo(record *memtable.Record, state int) bool {
+	c.current = record
+	if record == nil {
+		c.state = state
+		return false
+	}
+	c.state = 0
+	return true
+}
+
+// Seek pozicionira cursor na prvi ključ koji je >= minKey
+func (c *ARTCursor) Seek(minKey string) bool {
+	return c.moveTo(c.ceiling(minKey, false), 1)
+}
+
+// SeekForPrev pozicionira cursor na poslednji ključ koji je <= maxKey
+func (c *ARTCursor) SeekForPrev(maxKey string) bool {
+	return c.moveTo(c.floor(maxKey, false), -1)
+}
+
+// Next prelazi na sledeći ključ
+func (c *ARTCursor) Next() bool {
+	switch c.state {
+	case -1:
+		if c.root == nil {
+			return false
+		}
+		return c.moveTo(c.root.minimum(), 1)
+	case 0:
+		return c.moveTo(c.ceiling(c.current.Key, true), 1)
+	}
+	return false
+}
+
+// Prev prelazi na prethodni ključ
+func (c *ARTCursor) Prev() bool {
+	if c.state != 0 {
+		return false
+	}
+	return c.moveTo(c.floor(c.current.Key, true), -1)
+}
+
+// Getter za key
+func (c *ARTCursor) Key() string {
+	if c.current == nil {
+		return ""
+	}
+	return c.current.Key
+}
+
+// Getter za value
+func (c *ARTCursor) Value() []byte {
+	if c.current == nil {
+		return nil
+	}
+	return c.current.Value
+}
+
+// Getter za timestamp
+func (c *ARTCursor) Timestamp() [16]byte {
+	if c.current == nil {
+		return [16]byte{}
+	}
+	return c.current.Timestamp
+}
+
+// Getter za tombstone
+func (c *ARTCursor) Tombstone() bool {
+	return c.current != nil && c.current.Tombstone
+}
+
+// Funckija za reset cursora
+func (c *ARTCursor) Close() {
+	c.root = nil
+	c.current = nil
+	c.state = 1
+}
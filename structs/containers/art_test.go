@@ -0,0 +1,216 @@
+package containers
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"projekat/structs/cursor"
+	"projekat/structs/memtable"
+)
+
+// artKeys vraća sve ključeve stabla redom kojim ih cursor obilazi od početka
+func artKeys(c cursor.Cursor) []string {
+	keys := make([]string, 0)
+	for ok := c.Seek(""); ok; ok = c.Next() {
+		keys = append(keys, c.Key())
+	}
+	return keys
+}
+
+// Čvor sa zajedničkim prefiksom raste 4 -> 16 -> 48 -> 256 kako mu se dodaju deca; posle svakog
+// prelaza sva deca moraju biti dostupna pretragom i obilaskom, rastuće po bajtu
+func TestARTNodeGrowth(t *testing.T) {
+	m := NewARTMemtable(1000, 0)
+	m.Add(testTimestamp(0), false, "p", []byte("koren"))
+	m.Add(testTimestamp(0), false, "q", []byte("sused"))
+	// Bajtovi se dodaju nasumičnim redosledom da bi se proverilo sortiranje pri prelazu
+	order := rand.New(rand.NewSource(1)).Perm(256)
+	growth := map[int]artKind{4: artNode4, 5: artNode16, 16: artNode16, 17: artNode48, 48: artNode48, 49: artNode256, 256: artNode256}
+	want := []string{"p", "q"}
+	for i, b := range order {
+		key := "p" + string([]byte{byte(b)})
+		m.Add(testTimestamp(i+1), false, key, []byte(key))
+		want = append(want, key)
+
+		node, _ := m.tree.root.findPrefix([]byte("p"), 0)
+		if node == nil || node.count != i+1 {
+			t.Fatalf("posle %d dece čvor prefiksa ima %+v", i+1, node)
+		}
+		if kind, ok := growth[i+1]; ok && node.kind != kind {
+			t.Fatalf("posle %d dece čvor je tipa %d, očekivan %d", i+1, node.kind, kind)
+		}
+		if _, ok := growth[i+1]; !ok {
+			continue
+		}
+		for _, key := range want {
+			value, _, found := m.Get(key)
+			if !found || (key != "p" && key != "q" && string(value) != key) {
+				t.Fatalf("posle %d dece ključ %q nije pronađen", i+1, key)
+			}
+		}
+		sorted := append([]string{}, want...)
+		sort.Strings(sorted)
+		if got := artKeys(m.NewCursor()); strings.Join(got, ",") != strings.Join(sorted, ",") {
+			t.Fatalf("posle %d dece obilazak vraća %q", i+1, got)
+		}
+	}
+	if _, _, found := m.Get("p\x00\x00"); found {
+		t.Fatal("pronađen ključ koji nije upisan")
+	}
+
+	// Unazad kroz čvor od 256 dece, pa nazad napred preko zapisa samog čvora
+	c := m.NewCursor()
+	if !c.SeekForPrev("p\xff") || c.Key() != "p\xff" {
+		t.Fatalf("SeekForPrev vratio %q", c.Key())
+	}
+	for b := 0xfe; b >= 0; b-- {
+		if !c.Prev() || c.Key() != "p"+string([]byte{byte(b)}) {
+			t.Fatalf("Prev posle bajta %d vratio %q", b+1, c.Key())
+		}
+	}
+	if !c.Prev() || c.Key() != "p" || c.Prev() {
+		t.Fatalf("Prev pre prvog deteta vratio %q", c.Key())
+	}
+}
+
+// SetPrefix ograničava cursor na podstablo, i kada se prefiks završava usred prefiksa čvora
+func TestARTCursorSetPrefix(t *testing.T) {
+	m := NewARTMemtable(1000, 0)
+	all := []string{"user", "user:", "user:001", "usex"}
+	for u := 0; u < 3; u++ {
+		for s := 0; s < 20; s++ {
+			all = append(all, fmt.Sprintf("user:%03d:session:%02d", u, s))
+		}
+	}
+	for i, key := range all {
+		m.Add(testTimestamp(i), false, key, []byte(key))
+	}
+	sort.Strings(all)
+
+	withPrefix := func(prefix string) []string {
+		keys := make([]string, 0)
+		for _, key := range all {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	}
+	for _, prefix := range []string{"", "u", "user", "user:", "user:001", "user:001:", "user:001:se", "user:002:session:1", "user:003", "usex", "v"} {
+		c := m.NewCursor()
+		c.(cursor.PrefixCursor).SetPrefix(prefix)
+		want := withPrefix(prefix)
+		if got := artKeys(c); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("prefiks %q: Next vraća %q, očekivano %q", prefix, got, want)
+		}
+		got := make([]string, 0)
+		for ok := c.SeekForPrev("\xff"); ok; ok = c.Prev() {
+			got = append([]string{c.Key()}, got...)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("prefiks %q: Prev vraća %q, očekivano %q", prefix, got, want)
+		}
+	}
+
+	// Seek unutar podstabla i sužavanje prefiksa na već ograničenom cursoru
+	c := m.NewCursor()
+	pc := c.(cursor.PrefixCursor)
+	pc.SetPrefix("user:00")
+	if !c.Seek("user:001:session:05") || c.Key() != "user:001:session:05" {
+		t.Fatalf("Seek u podstablu vratio %q", c.Key())
+	}
+	if !c.SeekForPrev("user:001:session:99") || c.Key() != "user:001:session:19" {
+		t.Fatalf("SeekForPrev u podstablu vratio %q", c.Key())
+	}
+	pc.SetPrefix("user:002:")
+	if got := artKeys(c); len(got) != 20 || got[0] != "user:002:session:00" {
+		t.Fatalf("suženi prefiks vraća %q", got)
+	}
+	if c.Seek("user:003") || c.SeekForPrev("user:001:session:19") {
+		t.Fatalf("cursor izašao iz podstabla na %q", c.Key())
+	}
+}
+
+const (
+	benchKeyCount = 20000
+	benchPrefixes = 80
+)
+
+// benchKeys vraća ključeve oblika "user:<c>:session:<n>" nasumičnim redosledom; ključevi
+// istog korisnika dele dugačak prefiks, kao kod podataka jednog tenant-a
+func benchKeys() []string {
+	keys := make([]string, benchKeyCount)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("user:%03d:session:%05d", i%benchPrefixes, i)
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+	return keys
+}
+
+func benchMemtable(new func(maxSize, maxBytes int) memtable.MemtableInterface, keys []string) memtable.MemtableInterface {
+	m := new(1<<30, 0)
+	for i, key := range keys {
+		m.Add(testTimestamp(i), false, key, []byte("vrednost"))
+	}
+	return m
+}
+
+// BenchmarkARTAdd meri upis svih ključeva u prazan Memtable
+func BenchmarkARTAdd(b *testing.B) {
+	keys := benchKeys()
+	for _, impl := range memtableImpls {
+		b.Run(impl.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				benchMemtable(impl.new, keys)
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(keys)), "ns/key")
+		})
+	}
+}
+
+// BenchmarkARTGet meri čitanje jednog ključa
+func BenchmarkARTGet(b *testing.B) {
+	keys := benchKeys()
+	for _, impl := range memtableImpls {
+		b.Run(impl.name, func(b *testing.B) {
+			m := benchMemtable(impl.new, keys)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, _, found := m.Get(keys[i%len(keys)]); !found {
+					b.Fatal("ključ nije pronađen")
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkARTPrefixScan meri skeniranje ključeva jednog korisnika (1/80 svih ključeva) kao
+// kod PREFIX_SCAN: cursor radix stabla se ograničava na podstablo, ostali traže prvi ključ
+// i idu redom dok prefiks važi
+func BenchmarkARTPrefixScan(b *testing.B) {
+	keys := benchKeys()
+	for _, impl := range memtableImpls {
+		b.Run(impl.name, func(b *testing.B) {
+			m := benchMemtable(impl.new, keys)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				prefix := fmt.Sprintf("user:%03d:", i%benchPrefixes)
+				c := m.NewCursor()
+				if pc, ok := c.(cursor.PrefixCursor); ok {
+					pc.SetPrefix(prefix)
+				}
+				count := 0
+				for ok := c.Seek(prefix); ok && strings.HasPrefix(c.Key(), prefix); ok = c.Next() {
+					count++
+				}
+				c.Close()
+				if count != benchKeyCount/benchPrefixes {
+					b.Fatalf("prefiks %s: %d ključeva", prefix, count)
+				}
+			}
+		})
+	}
+}
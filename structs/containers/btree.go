@@ -46,7 +46,8 @@ func (n *BTreeNode) search(key string) ([]byte, bool, bool) {
 	for i < len(n.Keys) && key > n.Keys[i] {
 		i++
 	}
-	if i < len(n.Keys) && key == n.Keys[i] && !n.Deleted[i] {
+	// Obrisan ključ se takođe vraća, da bi tombstone zaklonio starije verzije
+	if i < len(n.Keys) && key == n.Keys[i] {
 		return n.Values[i], n.Deleted[i], true
 	}
 	if n.IsLeaf {
@@ -55,6 +56,21 @@ func (n *BTreeNode) search(key string) ([]byte, bool, bool) {
 	return n.Children[i].search(key)
 }
 
+// lookup pronalazi ključ zajedno sa obrisanim; vraća vrednost, da li je obrisan i da li postoji
+func (n *BTreeNode) lookup(key string) ([]byte, bool, bool) {
+	i := 0
+	for i < len(n.Keys) && key > n.Keys[i] {
+		i++
+	}
+	if i < len(n.Keys) && key == n.Keys[i] {
+		return n.Values[i], n.Deleted[i], true
+	}
+	if n.IsLeaf || i >= len(n.Children) {
+		return nil, false, false
+	}
+	return n.Children[i].lookup(key)
+}
+
 func (t *BTree) ReadElement(key string) ([]byte, bool, error) {
 	val, del, ok := t.Root.search(key)
 	if !ok {
@@ -80,24 +96,41 @@ func (t *BTree) WriteElement(key string, value []byte, ts [16]byte, tombstone bo
 	return nil
 }
 
-func (t *BTree) insertNonFull(node *BTreeNode, key string, value []byte, ts [16]byte, tombstone bool) {
-	i := len(node.Keys) - 1
-	//da li kljuc vec postoji u listu
-	for j := 0; j < len(node.Keys); j++ {
-		if key == node.Keys[j] {
-			if node.Deleted[j] && !tombstone { //ako je obrisan, ozivimo ga
-				node.Values[j] = value
-				node.Deleted[j] = false
-				node.Timestamps[j] = ts
-			} else if string(node.Values[j]) != string(value) {
-				node.Values[j] = value //zamenimo vrednost ako nije ista (URADILA I AZURIRANJE, ne skodi)
-				node.Timestamps[j] = ts
-			}
-			return
+// indexOf vraca poziciju kljuca u cvoru ili -1
+func (n *BTreeNode) indexOf(key string) int {
+	for j := 0; j < len(n.Keys); j++ {
+		if key == n.Keys[j] {
+			return j
 		}
 	}
+	return -1
+}
+
+// childIndex vraca indeks deteta u koje ide kljuc koji nije u cvoru
+func (n *BTreeNode) childIndex(key string) int {
+	i := 0
+	for i < len(n.Keys) && key > n.Keys[i] {
+		i++
+	}
+	return i
+}
+
+// setElement menja postojeci kljuc; noviji upis uvek zamenjuje vrednost, vreme i tombstone
+func (n *BTreeNode) setElement(j int, value []byte, ts [16]byte, tombstone bool) {
+	n.Values[j] = value
+	n.Deleted[j] = tombstone
+	n.Timestamps[j] = ts
+}
+
+func (t *BTree) insertNonFull(node *BTreeNode, key string, value []byte, ts [16]byte, tombstone bool) {
+	//da li kljuc vec postoji u cvoru
+	if j := node.indexOf(key); j >= 0 {
+		node.setElement(j, value, ts, tombstone)
+		return
+	}
 	if node.IsLeaf {
 		//ubacujemo novi kljuc u list
+		i := len(node.Keys) - 1
 		node.Keys = append(node.Keys, "")
 		node.Values = append(node.Values, nil)
 		node.Deleted = append(node.Deleted, false)
@@ -115,18 +148,26 @@ func (t *BTree) insertNonFull(node *BTreeNode, key string, value []byte, ts [16]
 		node.Deleted[i+1] = tombstone
 		node.Timestamps[i+1] = ts
 	} else { //ako nije list, gledamo u koje dete ulazimo
-		for i >= 0 && key < node.Keys[i] {
-			i--
-		}
-		i++
+		i := node.childIndex(key)
 
 		//ako je dete puno -> ROTACIJA ili SPLIT
+		if len(node.Children[i].Keys) == 2*t.degree-1 && t.tryRotate(node, i) {
+			//rotacija pomera kljuceve kroz roditelja, pa se mesto kljuca trazi ponovo
+			if j := node.indexOf(key); j >= 0 {
+				node.setElement(j, value, ts, tombstone)
+				return
+			}
+			i = node.childIndex(key)
+		}
+		//posle rotacije kljuc moze ici u brata koji je sada pun - tada se on deli
 		if len(node.Children[i].Keys) == 2*t.degree-1 {
-			if !t.tryRotate(node, i) {
-				t.splitChild(node, i)
-				if key > node.Keys[i] {
-					i++
-				}
+			t.splitChild(node, i)
+			if key == node.Keys[i] { //kljuc je bas srednji koji je otisao gore
+				node.setElement(i, value, ts, tombstone)
+				return
+			}
+			if key > node.Keys[i] {
+				i++
 			}
 		}
 		t.insertNonFull(node.Children[i], key, value, ts, tombstone)
@@ -152,6 +193,11 @@ func leftRotate(left, parent, child *BTreeNode, i int) {
 	child.Values = child.Values[1:]
 	child.Deleted = child.Deleted[1:]
 	child.Timestamps = child.Timestamps[1:]
+	// kod unutrasnjeg cvora prvo dete deteta prelazi levom bratu
+	if !child.IsLeaf {
+		left.Children = append(left.Children, child.Children[0])
+		child.Children = child.Children[1:]
+	}
 }
 
 // desna rotacija
@@ -171,7 +217,11 @@ func rightRotate(right, parent, child *BTreeNode, i int) {
 	child.Values = child.Values[:len(child.Values)-1]
 	child.Deleted = child.Deleted[:len(child.Deleted)-1]
 	child.Timestamps = child.Timestamps[:len(child.Timestamps)-1]
-
+	// kod unutrasnjeg cvora poslednje dete deteta prelazi desnom bratu
+	if !child.IsLeaf {
+		right.Children = append([]*BTreeNode{child.Children[len(child.Children)-1]}, right.Children...)
+		child.Children = child.Children[:len(child.Children)-1]
+	}
 }
 
 // prvo pokusavamo
@@ -250,26 +300,6 @@ func (t *BTree) splitChild(parent *BTreeNode, i int) {
 	full.Timestamps = full.Timestamps[:mid]
 }
 
-// ---------- DELETE - logicko ----------
-
-func (t *BTree) MarkAsDeleted(node *BTreeNode, key string) bool {
-	i := 0
-	for i < len(node.Keys) && key > node.Keys[i] {
-		i++
-	}
-	if i < len(node.Keys) && key == node.Keys[i] {
-		if node.Deleted[i] {
-			return false
-		}
-		node.Deleted[i] = true
-		return true
-	}
-	if node.IsLeaf {
-		return false
-	}
-	return t.MarkAsDeleted(node.Children[i], key)
-}
-
 // InOrderTraversal vraca sve zapise sortirano
 func (t *BTree) InOrderTraversal() []memtable.Record {
 	records := make([]memtable.Record, 0)
@@ -11,33 +11,41 @@ type BTreeMemtable struct {
 	size      int
 	maxSize   int
 	watermark uint32
+	memtable.RangeTombstoneList
+	memtable.ByteCounter
 }
 
-func NewBTreeMemtable(maxSize int, degree int) *BTreeMemtable {
+func NewBTreeMemtable(maxSize, maxBytes int, degree int) *BTreeMemtable {
 	return &BTreeMemtable{
-		tree:      NewBTree(degree),
-		size:      0,
-		maxSize:   maxSize,
-		watermark: 0,
+		tree:        NewBTree(degree),
+		size:        0,
+		maxSize:     maxSize,
+		watermark:   0,
+		ByteCounter: memtable.NewByteCounter(maxBytes),
 	}
 }
 
 func (m *BTreeMemtable) Add(ts [16]byte, tombstone bool, key string, value []byte) error {
-	_, del, err := m.tree.ReadElement(key)
-	isNew := err != nil && !del
+	// Obrisani ključ je i dalje u stablu, pa se traži zajedno sa obrisanim
+	old, _, exists := m.tree.Root.lookup(key)
+	isNew := !exists
 
-	err = m.tree.WriteElement(key, value, ts, tombstone)
+	err := m.tree.WriteElement(key, value, ts, tombstone)
 	if err != nil {
 		return err
 	}
+	m.TrackWrite(key, old, exists, value)
 	if isNew {
 		m.size++
 	}
 	return nil
 }
 
-func (m *BTreeMemtable) Delete(key string) bool {
-	return m.tree.MarkAsDeleted(m.tree.Root, key)
+// Delete upisuje tombstone kao novi zapis stabla; postojeći ključ dobija vreme brisanja
+func (m *BTreeMemtable) Delete(ts [16]byte, key string) bool {
+	_, deleted, exists := m.tree.Root.lookup(key)
+	m.Add(ts, true, key, []byte{})
+	return exists && !deleted
 }
 
 func (m *BTreeMemtable) Get(key string) ([]byte, bool, bool) {
@@ -51,6 +59,7 @@ func (m *BTreeMemtable) Flush() *[]memtable.Record {
 	m.collectRecords(m.tree.Root, &records)
 	m.tree = NewBTree(m.tree.degree)
 	m.size = 0
+	m.ResetBytes()
 	return &records
 }
 
@@ -85,7 +94,7 @@ func (m *BTreeMemtable) GetWatermark() uint32 {
 }
 
 func (m *BTreeMemtable) IsFull() bool {
-	return m.size >= m.maxSize
+	return m.BytesFull(m.size, m.maxSize)
 }
 
 // --------------------------------------------------------------------------------------------------------------------------
@@ -143,6 +152,31 @@ func (c *BTreeCursor) Next() bool {
 	return c.current < len(c.records)
 }
 
+// SeekForPrev pozicionira cursor na poslednji kljuc koji je <= maxKey
+func (c *BTreeCursor) SeekForPrev(maxKey string) bool {
+	// Binarna pretraga za prvi kljuc koji je > maxKey
+	left, right := 0, len(c.records)
+	for left < right {
+		mid := left + (right-left)/2
+		if c.records[mid].Key <= maxKey {
+			left = mid + 1
+		} else {
+			right = mid
+		}
+	}
+	c.current = left - 1
+	return c.current >= 0
+}
+
+// Prev pomjera cursor na prethodni element
+func (c *BTreeCursor) Prev() bool {
+	if c.current < 0 || c.current >= len(c.records) {
+		return false
+	}
+	c.current--
+	return c.current >= 0
+}
+
 // Getter za key
 func (c *BTreeCursor) Key() string {
 	if c.current < 0 || c.current >= len(c.records) {
@@ -12,37 +12,38 @@ type HashMapMemtable struct {
 	data      map[string]memtable.Record
 	watermark uint32
 	maxSize   int
+	memtable.RangeTombstoneList
+	memtable.ByteCounter
 }
 
 // NewHashMapMemtable kreira novu instancu HashMapMemtable-a
-func NewHashMapMemtable(maxSize int) *HashMapMemtable {
+func NewHashMapMemtable(maxSize, maxBytes int) *HashMapMemtable {
 	return &HashMapMemtable{
-		data:      make(map[string]memtable.Record),
-		watermark: 0,
-		maxSize:   maxSize,
+		data:        make(map[string]memtable.Record),
+		watermark:   0,
+		maxSize:     maxSize,
+		ByteCounter: memtable.NewByteCounter(maxBytes),
 	}
 }
 
 // Add dodaje par kljuc-vrednost u HashMapMemtable
 func (m *HashMapMemtable) Add(ts [16]byte, tombstone bool, key string, value []byte) error {
+	old, exists := m.data[key]
+	m.TrackWrite(key, old.Value, exists, value)
 	m.data[key] = memtable.Record{Timestamp: ts, Tombstone: tombstone, Key: key, Value: value}
 	return nil
 }
 
 // Vraca true ako je memtable pun, a u suprotnom false
 func (m *HashMapMemtable) IsFull() bool {
-	return len(m.data) == m.maxSize
+	return m.BytesFull(len(m.data), m.maxSize)
 }
 
-// Delete uklanja par kljuc-vrednost iz HashMapMemtable-a
-func (m *HashMapMemtable) Delete(key string) bool {
-	if record, exists := m.data[key]; !exists {
-		return false
-	} else {
-		record.Tombstone = true
-		m.data[key] = record
-		return true
-	}
+// Delete upisuje tombstone za kljuc u HashMapMemtable
+func (m *HashMapMemtable) Delete(ts [16]byte, key string) bool {
+	record, exists := m.data[key]
+	m.Add(ts, true, key, []byte{})
+	return exists && !record.Tombstone
 }
 
 // Get dohvata vrednost prema kljucu iz HashMapMemtable-a
@@ -69,6 +70,7 @@ func (m *HashMapMemtable) Flush() *[]memtable.Record {
 	}
 	// Resetujemo Memtable
 	m.data = make(map[string]memtable.Record)
+	m.ResetBytes()
 	return &records
 }
 
@@ -134,6 +136,21 @@ func (c *HashMapCursor) Next() bool {
 	return true
 }
 
+// SeekForPrev pronalazi poslednji zapis unutar memtabele koji je <= maxKey
+func (c *HashMapCursor) SeekForPrev(maxKey string) bool {
+	c.current = sort.Search(len(c.keys), func(i int) bool { return c.keys[i] > maxKey }) - 1
+	return c.current >= 0
+}
+
+// Funkcija za prelazak na prethodni zapis
+func (c *HashMapCursor) Prev() bool {
+	if c.current < 0 || c.current >= len(c.keys) {
+		return false
+	}
+	c.current--
+	return c.current >= 0
+}
+
 // Getter za kljuc
 func (c *HashMapCursor) Key() string {
 	if c.current < 0 || c.current >= len(c.keys) {
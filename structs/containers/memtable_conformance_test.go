@@ -0,0 +1,246 @@
+package containers
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"projekat/structs/memtable"
+)
+
+// Sve implementacije Memtable-a moraju da zadovolje isti ugovor; testovi se izvršavaju
+// nad svakom od njih. BTree se proverava i sa malim stepenom, gde se rotacije i deljenja
+// čvorova dešavaju često.
+var memtableImpls = []struct {
+	name string
+	new  func(maxSize, maxBytes int) memtable.MemtableInterface
+}{
+	{"hashMap", func(maxSize, maxBytes int) memtable.MemtableInterface {
+		return NewHashMapMemtable(maxSize, maxBytes)
+	}},
+	{"skipList", func(maxSize, maxBytes int) memtable.MemtableInterface {
+		return NewSkipListMemtable(8, maxSize, maxBytes)
+	}},
+	{"BTree", func(maxSize, maxBytes int) memtable.MemtableInterface {
+		return NewBTreeMemtable(maxSize, maxBytes, 16)
+	}},
+	{"BTree2", func(maxSize, maxBytes int) memtable.MemtableInterface {
+		return NewBTreeMemtable(maxSize, maxBytes, 2)
+	}},
+	{"arenaSkipList", func(maxSize, maxBytes int) memtable.MemtableInterface {
+		return NewArenaSkipListMemtable(8, maxSize, maxBytes, 0)
+	}},
+	{"ART", func(maxSize, maxBytes int) memtable.MemtableInterface {
+		return NewARTMemtable(maxSize, maxBytes)
+	}},
+}
+
+func testTimestamp(i int) [16]byte {
+	return [16]byte{byte(i), byte(i >> 8), byte(i >> 16)}
+}
+
+func TestMemtableDeleteAbsentKey(t *testing.T) {
+	for _, impl := range memtableImpls {
+		t.Run(impl.name, func(t *testing.T) {
+			m := impl.new(100, 0)
+			if m.Delete(testTimestamp(1), "a") {
+				t.Fatal("brisanje ključa kog nema vratilo je true")
+			}
+			value, tombstone, found := m.Get("a")
+			if !found || !tombstone || len(value) != 0 {
+				t.Fatalf("očekivan tombstone, dobijeno vrednost=%q tombstone=%v pronađen=%v", value, tombstone, found)
+			}
+			records := *m.Flush()
+			if len(records) != 1 || !records[0].Tombstone || records[0].Timestamp != testTimestamp(1) {
+				t.Fatalf("flush treba da vrati tombstone sa vremenom brisanja, dobijeno %+v", records)
+			}
+		})
+	}
+}
+
+func TestMemtableDeleteLiveKey(t *testing.T) {
+	for _, impl := range memtableImpls {
+		t.Run(impl.name, func(t *testing.T) {
+			m := impl.new(100, 0)
+			m.Add(testTimestamp(1), false, "a", []byte("vrednost"))
+			if !m.Delete(testTimestamp(2), "a") {
+				t.Fatal("brisanje živog ključa vratilo je false")
+			}
+			if m.Delete(testTimestamp(3), "a") {
+				t.Fatal("ponovno brisanje vratilo je true")
+			}
+			value, tombstone, found := m.Get("a")
+			if !found || !tombstone || len(value) != 0 {
+				t.Fatalf("očekivan tombstone, dobijeno vrednost=%q tombstone=%v pronađen=%v", value, tombstone, found)
+			}
+			records := *m.Flush()
+			if len(records) != 1 || records[0].Timestamp != testTimestamp(3) {
+				t.Fatalf("tombstone treba da nosi vreme poslednjeg brisanja, dobijeno %+v", records)
+			}
+
+			// Upis posle brisanja ponovo oživljava ključ
+			m.Add(testTimestamp(4), false, "b", []byte("1"))
+			m.Delete(testTimestamp(5), "b")
+			m.Add(testTimestamp(6), false, "b", []byte("2"))
+			value, tombstone, found = m.Get("b")
+			if !found || tombstone || string(value) != "2" {
+				t.Fatalf("očekivana vrednost 2, dobijeno vrednost=%q tombstone=%v pronađen=%v", value, tombstone, found)
+			}
+		})
+	}
+}
+
+func TestMemtableTombstonesCountTowardsFull(t *testing.T) {
+	for _, impl := range memtableImpls {
+		t.Run(impl.name, func(t *testing.T) {
+			m := impl.new(3, 0)
+			m.Add(testTimestamp(1), false, "a", []byte("1"))
+			m.Delete(testTimestamp(2), "b")
+			if m.IsFull() {
+				t.Fatal("Memtable je pun pre dostizanja maksimalnog broja zapisa")
+			}
+			m.Delete(testTimestamp(3), "c")
+			if !m.IsFull() {
+				t.Fatal("tombstone-i se ne računaju u broj zapisa")
+			}
+
+			m = impl.new(100, 1<<20)
+			before := m.SizeBytes()
+			m.Delete(testTimestamp(1), "kljuc")
+			if m.SizeBytes() <= before {
+				t.Fatal("tombstone se ne računa u zauzeće u bajtovima")
+			}
+		})
+	}
+}
+
+// Nasumični upisi i brisanja porede se sa mapom; proveravaju se čitanje, flush i cursor
+func TestMemtableMatchesReference(t *testing.T) {
+	type entry struct {
+		value     string
+		tombstone bool
+		timestamp [16]byte
+	}
+	for _, impl := range memtableImpls {
+		t.Run(impl.name, func(t *testing.T) {
+			for seed := int64(0); seed < 10; seed++ {
+				r := rand.New(rand.NewSource(seed))
+				m := impl.new(1<<30, 0)
+				reference := make(map[string]entry)
+				for i := 0; i < 2000; i++ {
+					key := fmt.Sprintf("k%03d", r.Intn(300))
+					if r.Intn(3) == 0 {
+						old, exists := reference[key]
+						if m.Delete(testTimestamp(i), key) != (exists && !old.tombstone) {
+							t.Fatalf("seed %d: pogrešna povratna vrednost brisanja za %s", seed, key)
+						}
+						reference[key] = entry{"", true, testTimestamp(i)}
+					} else {
+						value := fmt.Sprint(r.Intn(5))
+						m.Add(testTimestamp(i), false, key, []byte(value))
+						reference[key] = entry{value, false, testTimestamp(i)}
+					}
+				}
+
+				keys := make([]string, 0, len(reference))
+				for key, e := range reference {
+					keys = append(keys, key)
+					value, tombstone, found := m.Get(key)
+					if !found || tombstone != e.tombstone || string(value) != e.value {
+						t.Fatalf("seed %d: Get(%s) = %q %v %v, očekivano %+v", seed, key, value, tombstone, found, e)
+					}
+				}
+				sort.Strings(keys)
+
+				// Cursor obilazi sve zapise, uključujući tombstone-e, rastuće po ključu
+				c := m.NewCursor()
+				i := 0
+				for ok := c.Seek(""); ok; ok = c.Next() {
+					e := reference[keys[i]]
+					if c.Key() != keys[i] || c.Tombstone() != e.tombstone || c.Timestamp() != e.timestamp {
+						t.Fatalf("seed %d: cursor na poziciji %d je %s, očekivano %s", seed, i, c.Key(), keys[i])
+					}
+					i++
+				}
+				if i != len(keys) {
+					t.Fatalf("seed %d: cursor je obišao %d zapisa, očekivano %d", seed, i, len(keys))
+				}
+				c.Close()
+
+				records := *m.Flush()
+				if len(records) != len(keys) {
+					t.Fatalf("seed %d: flush je vratio %d zapisa, očekivano %d", seed, len(records), len(keys))
+				}
+				for i, record := range records {
+					e := reference[keys[i]]
+					if record.Key != keys[i] || record.Tombstone != e.tombstone || string(record.Value) != e.value || record.Timestamp != e.timestamp {
+						t.Fatalf("seed %d: flush zapis %+v, očekivano %s %+v", seed, record, keys[i], e)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMemtableCursorSeek(t *testing.T) {
+	keys := []string{"b", "d", "f", "h"}
+	for _, impl := range memtableImpls {
+		t.Run(impl.name, func(t *testing.T) {
+			m := impl.new(100, 0)
+			for i, key := range keys {
+				m.Add(testTimestamp(i), false, key, []byte(key))
+			}
+			m.Delete(testTimestamp(10), "f")
+
+			c := m.NewCursor()
+			defer c.Close()
+			if !c.Seek("c") || c.Key() != "d" {
+				t.Fatalf("Seek(c) = %s, očekivano d", c.Key())
+			}
+			if !c.Next() || c.Key() != "f" || !c.Tombstone() {
+				t.Fatalf("Next = %s (tombstone %v), očekivano obrisan f", c.Key(), c.Tombstone())
+			}
+			if !c.Prev() || c.Key() != "d" {
+				t.Fatalf("Prev = %s, očekivano d", c.Key())
+			}
+			if !c.SeekForPrev("g") || c.Key() != "f" {
+				t.Fatalf("SeekForPrev(g) = %s, očekivano f", c.Key())
+			}
+			if c.Seek("i") {
+				t.Fatalf("Seek(i) je pronašao %s", c.Key())
+			}
+			if c.SeekForPrev("a") {
+				t.Fatalf("SeekForPrev(a) je pronašao %s", c.Key())
+			}
+		})
+	}
+}
+
+// Veliki broj upisa u BTree sa malim stepenom: rotacije unutrašnjih čvorova moraju da
+// prenesu i decu, a ključ koji se podigne pri deljenju ne sme da se upiše dvaput
+func TestBTreeManyInserts(t *testing.T) {
+	for _, degree := range []int{2, 3, 16} {
+		r := rand.New(rand.NewSource(int64(degree)))
+		m := NewBTreeMemtable(1<<30, 0, degree)
+		keys := make(map[string]bool)
+		for i := 0; i < 20000; i++ {
+			key := fmt.Sprint(r.Intn(10000))
+			if r.Intn(4) == 0 {
+				m.Delete(testTimestamp(i), key)
+			} else {
+				m.Add(testTimestamp(i), false, key, []byte("v"))
+			}
+			keys[key] = true
+		}
+		records := *m.Flush()
+		if len(records) != len(keys) {
+			t.Fatalf("stepen %d: %d zapisa, očekivano %d", degree, len(records), len(keys))
+		}
+		for i := 1; i < len(records); i++ {
+			if records[i-1].Key >= records[i].Key {
+				t.Fatalf("stepen %d: zapisi nisu strogo rastući: %s, %s", degree, records[i-1].Key, records[i].Key)
+			}
+		}
+	}
+}
@@ -24,6 +24,8 @@ type SkipListMemtable struct {
 	watermark uint32
 	size      int
 	maxSize   int
+	memtable.RangeTombstoneList
+	memtable.ByteCounter
 }
 
 func (s *SkipList) roll() int {
@@ -141,53 +143,22 @@ func (sl *SkipList) WriteElement(ts [16]byte, tombstone bool, str string, value
 	return true
 }
 
-func (sl *SkipList) DeleteElement(str string) bool {
-	current := &sl.levels[sl.maxHeight-1]
-	for {
-		if current.Record.Key == str {
-			break
-		}
-		if current.Next == nil {
-			if current.Down == nil {
-				break
-			}
-			current = current.Down
-		} else if current.Next.Record.Key > str {
-			if current.Down == nil {
-				break
-			}
-			current = current.Down
-		} else {
-			current = current.Next
-		}
-	}
-	if current.Record.Key == str {
-		current.Record.Tombstone = true
-		current.Record.Value = []byte{}
-		for current.Down != nil {
-			current = current.Down
-			current.Record.Tombstone = true
-			current.Record.Value = []byte{}
-		}
-		return true
-	} else {
-		return false
-	}
-}
-
-func NewSkipListMemtable(maxHeight, maxSize int) *SkipListMemtable {
+func NewSkipListMemtable(maxHeight, maxSize, maxBytes int) *SkipListMemtable {
 	return &SkipListMemtable{
-		data:      CreateSL(maxHeight),
-		watermark: 0,
-		maxSize:   maxSize,
-		size:      0,
+		data:        CreateSL(maxHeight),
+		watermark:   0,
+		maxSize:     maxSize,
+		size:        0,
+		ByteCounter: memtable.NewByteCounter(maxBytes),
 	}
 }
 
 func (m *SkipListMemtable) Add(ts [16]byte, tombstone bool, key string, value []byte) error {
-	if m.size >= m.maxSize {
+	if m.IsFull() {
 		return memtable.ErrMemtableFull
 	}
+	old, err := m.data.ReadElement(key)
+	m.TrackWrite(key, old.Value, err == nil, value)
 	newelem := m.data.WriteElement(ts, tombstone, key, value)
 	if newelem {
 		m.size++
@@ -195,8 +166,14 @@ func (m *SkipListMemtable) Add(ts [16]byte, tombstone bool, key string, value []
 	return nil
 }
 
-func (m *SkipListMemtable) Delete(key string) bool {
-	return m.data.DeleteElement(key)
+func (m *SkipListMemtable) Delete(ts [16]byte, key string) bool {
+	// Brisanje prazni vrednost; ključ koji ne postoji dobija novi čvor sa tombstone-om
+	old, err := m.data.ReadElement(key)
+	m.TrackWrite(key, old.Value, err == nil, []byte{})
+	if m.data.WriteElement(ts, true, key, []byte{}) {
+		m.size++
+	}
+	return err == nil && !old.Tombstone
 }
 
 func (m *SkipListMemtable) Get(key string) ([]byte, bool, bool) {
@@ -216,12 +193,13 @@ func (m *SkipListMemtable) Flush() *[]memtable.Record {
 	// Resetovanje Memtabele na početno stanje
 	m.data = CreateSL(m.data.maxHeight)
 	m.size = 0
+	m.ResetBytes()
 	return &records
 }
 
-// Isto kao prethodna - treba se izmjeniti
+// IsFull vraća true kada je dostignuto zauzeće u bajtovima (ili broj zapisa ako ono nije zadato)
 func (m *SkipListMemtable) IsFull() bool {
-	return m.size == m.maxSize
+	return m.BytesFull(m.size, m.maxSize)
 }
 
 func (m *SkipListMemtable) SetWatermark(index uint32) {
@@ -239,6 +217,7 @@ func (m *SkipListMemtable) GetWatermark() uint32 {
 // SkipList cursor struktura
 type SkipListCursor struct {
 	head    *Node // pocetni node
+	top     *Node // pocetni node najviseg nivoa (za pretragu unazad)
 	current *Node // Trenutni node
 }
 
@@ -247,10 +226,41 @@ func (m *SkipListMemtable) NewCursor() cursor.Cursor {
 	bottomHead := &m.data.levels[0]
 	return &SkipListCursor{
 		head:    bottomHead,
+		top:     &m.data.levels[m.data.maxHeight-1],
 		current: bottomHead,
 	}
 }
 
+// before spusta se kroz nivoe i vraca poslednji node donjeg nivoa ciji je kljuc < key
+// (ili <= key ako je inclusive); ako takav ne postoji vraca pocetni node
+func (c *SkipListCursor) before(key string, inclusive bool) *Node {
+	current := c.top
+	for {
+		for current.Next != nil && (current.Next.Record.Key < key || (inclusive && current.Next.Record.Key == key)) {
+			current = current.Next
+		}
+		if current.Down == nil {
+			return current
+		}
+		current = current.Down
+	}
+}
+
+// SeekForPrev pozicionira cursor na poslednji element koji je <= maxKey
+func (c *SkipListCursor) SeekForPrev(maxKey string) bool {
+	c.current = c.before(maxKey, true)
+	return c.current != c.head
+}
+
+// Prev pomjera cursor na prethodni element u donjem nivou
+func (c *SkipListCursor) Prev() bool {
+	if c.current == nil || c.current == c.head {
+		return false
+	}
+	c.current = c.before(c.current.Record.Key, false)
+	return c.current != c.head
+}
+
 // Seek pozicionira cursor na prvi element koji je >= minKey
 func (c *SkipListCursor) Seek(minKey string) bool {
 	c.current = c.head
@@ -304,5 +314,6 @@ func (c *SkipListCursor) Tombstone() bool {
 // Funckija za reset cursora
 func (c *SkipListCursor) Close() {
 	c.head = nil
+	c.top = nil
 	c.current = nil
 }
@@ -3,7 +3,11 @@ package cursor
 // Struktura cursora
 type Cursor interface {
 	Seek(seekKey string) bool
+	// SeekForPrev pozicionira cursor na poslednji zapis čiji je ključ <= seekKey
+	SeekForPrev(seekKey string) bool
 	Next() bool
+	// Prev pomera cursor na prethodni zapis; za cursor koji nije na zapisu vraća false
+	Prev() bool
 	Key() string
 	Value() []byte
 	Timestamp() [16]byte
@@ -11,4 +15,11 @@ type Cursor interface {
 	Close()
 }
 
-// Implementacije se nalaze u hashmap.go/skiplist.go/btree_memtable.go
+// PrefixCursor je cursor koji se može ograničiti na ključeve sa prefiksom (npr. podstablo
+// radix stabla), pa skeniranje po prefiksu ne prolazi kroz ostale ključeve
+type PrefixCursor interface {
+	Cursor
+	SetPrefix(prefix string)
+}
+
+// Implementacije se nalaze u hashmap.go/skiplist.go/btree_memtable.go/arena_skiplist.go/art_memtable.go
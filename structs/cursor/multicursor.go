@@ -1,90 +1,261 @@
 package cursor
 
-// MultiCursor struktura
+import (
+	"container/heap"
+	"encoding/binary"
+)
+
+// MultiCursor spaja cursore Memtable-a i SSTabela u jedan sortiran tok. Za svaki ključ
+// bira najnoviju verziju (po timestamp-u), preskače obrisane ključeve i vrednost čita
+// tek kada dođe na red, pa cena skeniranja zavisi samo od broja pročitanih rezultata.
+// Kroz opseg se može ići unapred (Seek/Next) i unazad (SeekForPrev/Prev), a promena smera
+// ponovo pozicionira sve cursore oko trenutnog ključa.
 type MultiCursor struct {
 	cursors []Cursor
-	current Cursor
+	heap    cursorHeap
 	minKey  string
 	maxKey  string
+	started bool
+	// U keysOnly režimu vrednosti se ne čitaju, osim kada ih filter zahteva
+	keysOnly bool
+	filter   ValueFilter
+	// Vreme najnovijeg brisanja opsega koje obuhvata ključ (0 ako ga nema)
+	coveredAt func(key string) uint64
+
+	// Trenutni (razrešeni) zapis
+	key   string
+	value []byte
+	ts    [16]byte
+	valid bool
+}
+
+// ValueFilter odlučuje da li ključ sa datom vrednošću ulazi u rezultat skeniranja
+type ValueFilter func(value []byte) bool
+
+// keysOnlyCursor je cursor koji može da preskoči čitanje vrednosti (cursor SSTabele)
+type keysOnlyCursor interface {
+	SetKeysOnly(keysOnly bool)
+}
+
+// cursorHeap je heap cursora po trenutnom ključu: rastuće pri kretanju unapred, a opadajuće
+// unazad. Kod istog ključa prednost ima cursor koji je ranije prosleđen.
+type cursorHeap struct {
+	items   []heapItem
+	reverse bool
+}
+
+type heapItem struct {
+	cursor Cursor
+	order  int
+}
+
+func (h cursorHeap) Len() int { return len(h.items) }
+func (h cursorHeap) Less(i, j int) bool {
+	ki, kj := h.items[i].cursor.Key(), h.items[j].cursor.Key()
+	if ki != kj {
+		return (ki < kj) != h.reverse
+	}
+	return h.items[i].order < h.items[j].order
+}
+func (h cursorHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *cursorHeap) Push(x any)   { h.items = append(h.items, x.(heapItem)) }
+func (h *cursorHeap) Pop() any {
+	old := h.items
+	item := old[len(old)-1]
+	h.items = old[:len(old)-1]
+	return item
 }
 
-// NewMultiCursor vraca instancu multicursora koja sluzi kao wrapper za sve cursore
+// NewMultiCursor vraca instancu multicursora koja sluzi kao wrapper za sve cursore.
+// Prvi Next počinje od minKey, a prvi Prev od maxKey (iteracija unazad zahteva maxKey).
 func NewMultiCursor(minKey, maxKey string, cursors ...Cursor) *MultiCursor {
-	mc := &MultiCursor{
+	return &MultiCursor{
 		cursors: cursors,
 		minKey:  minKey,
 		maxKey:  maxKey,
 	}
+}
 
-	// Za svaku cursor pronadji prvi kljuc koji je >= minKey
+// SetKeysOnly uključuje skeniranje samo ključeva: Value vraća nil, a cursori SSTabela
+// ne čitaju vrednosti iz data segmenta
+func (mc *MultiCursor) SetKeysOnly(keysOnly bool) {
+	mc.keysOnly = keysOnly
 	for _, c := range mc.cursors {
-		c.Seek(minKey)
+		if kc, ok := c.(keysOnlyCursor); ok {
+			kc.SetKeysOnly(keysOnly)
+		}
 	}
+}
 
-	return mc
+// SetFilter zadaje filter vrednosti koji se primenjuje pri spajanju; ključevi čija
+// najnovija vrednost ne prođe filter se preskaču kao da su obrisani
+func (mc *MultiCursor) SetFilter(filter ValueFilter) {
+	mc.filter = filter
 }
 
-// Predji na sledeci zapis
-func (mc *MultiCursor) Next() bool {
-	var nextCursor Cursor
-	var nextKey string
+// SetRangeTombstones zadaje funkciju koja za ključ vraća vreme najnovijeg brisanja opsega
+// koje ga obuhvata; verzije ključa upisane do tog trenutka se preskaču kao obrisane
+func (mc *MultiCursor) SetRangeTombstones(coveredAt func(key string) uint64) {
+	mc.coveredAt = coveredAt
+}
+
+// inRange proverava da li je cursor pozicioniran na ključ unutar opsega
+func (mc *MultiCursor) inRange(c Cursor) bool {
+	key := c.Key()
+	return key != "" && key >= mc.minKey && (mc.maxKey == "" || key <= mc.maxKey)
+}
 
-	// Trenutni cursor prebaci na sledeci zapis
-	if mc.current != nil {
-		mc.current.Next()
+// position puni heap cursorima koje je funkcija pozicionirala unutar opsega
+func (mc *MultiCursor) position(reverse bool, seek func(c Cursor) bool) {
+	mc.started = true
+	mc.heap = cursorHeap{reverse: reverse}
+	for i, c := range mc.cursors {
+		if seek(c) && mc.inRange(c) {
+			mc.heap.items = append(mc.heap.items, heapItem{cursor: c, order: i})
+		}
 	}
+	heap.Init(&mc.heap)
+}
 
-	// Pronadji cursor sa najmanjim kljucem u opsegu
-	for _, c := range mc.cursors {
-		currentKey := c.Key()
+// Seek pozicionira multicursor na prvi ključ >= seekKey koji nije obrisan
+func (mc *MultiCursor) Seek(seekKey string) bool {
+	seekKey = max(seekKey, mc.minKey)
+	mc.position(false, func(c Cursor) bool { return c.Seek(seekKey) })
+	return mc.advance()
+}
 
-		// Preskoci prazne kljuceve i kljuceve izvan opsega
-		if currentKey == "" || (mc.maxKey != "" && currentKey > mc.maxKey) {
-			continue
-		}
+// SeekForPrev pozicionira multicursor na poslednji ključ <= seekKey koji nije obrisan
+func (mc *MultiCursor) SeekForPrev(seekKey string) bool {
+	if mc.maxKey != "" {
+		seekKey = min(seekKey, mc.maxKey)
+	}
+	mc.position(true, func(c Cursor) bool { return c.SeekForPrev(seekKey) })
+	return mc.advance()
+}
 
-		// Izaberi cursor sa najmanjim kljucem
-		if nextCursor == nil || currentKey < nextKey {
-			nextCursor = c
-			nextKey = currentKey
-		}
+// Next prelazi na sledeći ključ koji nije obrisan
+func (mc *MultiCursor) Next() bool {
+	switch {
+	case !mc.started:
+		return mc.Seek(mc.minKey)
+	case mc.heap.reverse && mc.valid:
+		// Promena smera: svi cursori prelaze na prvi ključ veći od trenutnog
+		key := mc.key
+		mc.position(false, func(c Cursor) bool {
+			if !c.Seek(key) {
+				return false
+			}
+			return c.Key() != key || c.Next()
+		})
+	case mc.heap.reverse:
+		return mc.Seek(mc.minKey)
+	}
+	return mc.advance()
+}
+
+// Prev prelazi na prethodni ključ koji nije obrisan
+func (mc *MultiCursor) Prev() bool {
+	switch {
+	case !mc.started:
+		return mc.SeekForPrev(mc.maxKey)
+	case !mc.heap.reverse && mc.valid:
+		// Promena smera: svi cursori prelaze na poslednji ključ manji od trenutnog
+		key := mc.key
+		mc.position(true, func(c Cursor) bool {
+			if !c.SeekForPrev(key) {
+				return false
+			}
+			return c.Key() != key || c.Prev()
+		})
+	case !mc.heap.reverse:
+		return mc.SeekForPrev(mc.maxKey)
 	}
+	return mc.advance()
+}
 
-	mc.current = nextCursor
+// advance skida sa heap-a sledeći ključ u trenutnom smeru, sve dok ne naiđe na ključ koji
+// nije obrisan i čija vrednost prolazi filter
+func (mc *MultiCursor) advance() bool {
+	for mc.heap.Len() > 0 {
+		// Skidamo sve verzije sledećeg ključa i zadržavamo najnoviju
+		key := mc.heap.items[0].cursor.Key()
+		var newest Cursor
+		var newestTS uint64
+		group := make([]heapItem, 0, 1)
+		for mc.heap.Len() > 0 && mc.heap.items[0].cursor.Key() == key {
+			item := heap.Pop(&mc.heap).(heapItem)
+			ts := mc.timestamp(item.cursor)
+			if newest == nil || ts > newestTS {
+				newest, newestTS = item.cursor, ts
+			}
+			group = append(group, item)
+		}
+		deleted := newest.Tombstone() || (mc.coveredAt != nil && newestTS <= mc.coveredAt(key))
+		if !deleted {
+			mc.key, mc.value, mc.ts = key, nil, newest.Timestamp()
+			if !mc.keysOnly || mc.filter != nil {
+				mc.value = newest.Value()
+			}
+			if mc.filter != nil {
+				deleted = !mc.filter(mc.value)
+			}
+			if mc.keysOnly {
+				mc.value = nil
+			}
+		}
+		for _, item := range group {
+			var moved bool
+			if mc.heap.reverse {
+				moved = item.cursor.Prev()
+			} else {
+				moved = item.cursor.Next()
+			}
+			if moved && mc.inRange(item.cursor) {
+				heap.Push(&mc.heap, item)
+			}
+		}
+		if !deleted {
+			mc.valid = true
+			return true
+		}
+	}
+	mc.valid = false
+	return false
+}
 
-	return mc.current != nil
+// timestamp vraća vreme upisa trenutnog zapisa cursora (prvih 8 bajtova timestamp-a)
+func (mc *MultiCursor) timestamp(c Cursor) uint64 {
+	ts := c.Timestamp()
+	return binary.LittleEndian.Uint64(ts[:8])
 }
 
 // Getter za kljuc
 func (mc *MultiCursor) Key() string {
-	if mc.current == nil {
+	if !mc.valid {
 		return ""
 	}
-	return mc.current.Key()
+	return mc.key
 }
 
 // Getter za vrijednost
 func (mc *MultiCursor) Value() []byte {
-	if mc.current == nil {
+	if !mc.valid {
 		return nil
 	}
-	return mc.current.Value()
+	return mc.value
 }
 
 // Getter za timestamp
 func (mc *MultiCursor) Timestamp() [16]byte {
-	if mc.current == nil {
+	if !mc.valid {
 		return [16]byte{}
 	}
-	return mc.current.Timestamp()
+	return mc.ts
 }
 
-// Getter za tombstone
+// Tombstone je uvek false jer multicursor preskače obrisane ključeve
 func (mc *MultiCursor) Tombstone() bool {
-	if mc.current == nil {
-		return false
-	}
-	return mc.current.Tombstone()
+	return false
 }
 
 // Funckija za reset cursora
@@ -93,4 +264,6 @@ func (mc *MultiCursor) Close() {
 	for _, c := range mc.cursors {
 		c.Close()
 	}
+	mc.heap = cursorHeap{}
+	mc.valid = false
 }
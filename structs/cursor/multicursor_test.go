@@ -0,0 +1,108 @@
+package cursor_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"projekat/structs/blockmanager"
+	"projekat/structs/containers"
+	"projekat/structs/cursor"
+	"projekat/structs/sstable"
+)
+
+const testBlockSize = 512
+
+func ts(i uint64) [16]byte {
+	var t [16]byte
+	binary.LittleEndian.PutUint64(t[:8], i)
+	return t
+}
+
+// Promena smera (Next -> Prev -> Next) preko cursora Memtable-a i SSTabele: multicursor
+// ponovo pozicionira sve cursore oko trenutnog ključa i ne preskače ni ponavlja ključeve
+func TestMultiCursorDirectionSwitch(t *testing.T) {
+	dir := t.TempDir()
+	bm := blockmanager.NewBlockManager(testBlockSize, 64)
+	dict := sstable.NewDictionary()
+	want := make(map[string]string)
+
+	// Na disku parni ključevi; Memtable dodaje neparne, menja svaki šesti i briše svaki deseti
+	records := make([]sstable.Record, 0)
+	for i := 0; i < 200; i += 2 {
+		key, value := fmt.Sprintf("k%03d", i), fmt.Sprintf("disk-%d", i)
+		records = append(records, sstable.Record{Key: []byte(key), Value: []byte(value), ValueSize: uint64(len(value)), Timestamp: ts(1)})
+		want[key] = value
+	}
+	_, sstDir, err := sstable.CreateSSTable(records, nil, dir, 4, bm, testBlockSize, 0, false, false, dict,
+		filepath.Join(dir, "dict.db"), sstable.FilterPolicy{LevelFPRates: []float64{0.01}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem := containers.NewSkipListMemtable(8, 1000, 0)
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("k%03d", i)
+		switch {
+		case i%10 == 0:
+			mem.Delete(ts(2), key)
+			delete(want, key)
+		case i%2 == 1 || i%6 == 0:
+			value := fmt.Sprintf("mem-%d", i)
+			mem.Add(ts(2), false, key, []byte(value))
+			want[key] = value
+		}
+	}
+	keys := make([]string, 0, len(want))
+	for key := range want {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sc, err := sstable.NewCursor(bm, sstDir, "", "\xff", testBlockSize, false, dict, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := cursor.NewMultiCursor("", "\xff", mem.NewCursor(), &sc)
+	defer mc.Close()
+
+	pos := sort.SearchStrings(keys, "k050")
+	if !mc.Seek("k050") || mc.Key() != keys[pos] {
+		t.Fatalf("Seek vratio %s, očekivano %s", mc.Key(), keys[pos])
+	}
+	expect := func(step string, ok bool) {
+		t.Helper()
+		if !ok || mc.Key() != keys[pos] || string(mc.Value()) != want[keys[pos]] {
+			t.Fatalf("%s: %s = %q, očekivano %s = %q", step, mc.Key(), mc.Value(), keys[pos], want[keys[pos]])
+		}
+	}
+	for _, run := range []struct {
+		forward bool
+		steps   int
+	}{{true, 7}, {false, 12}, {true, 3}, {false, 1}, {true, 1}, {true, 20}, {false, 25}} {
+		for i := 0; i < run.steps; i++ {
+			if run.forward {
+				pos++
+				expect(fmt.Sprintf("Next na poziciji %d", pos), mc.Next())
+			} else {
+				pos--
+				expect(fmt.Sprintf("Prev na poziciji %d", pos), mc.Prev())
+			}
+		}
+	}
+
+	// Do početka unazad pa ponovo napred
+	for pos > 0 {
+		pos--
+		expect("Prev do početka", mc.Prev())
+	}
+	if mc.Prev() {
+		t.Fatalf("Prev pre prvog ključa vratio %s", mc.Key())
+	}
+	pos = 0
+	if !mc.Next() {
+		t.Fatal("Next posle početka nije vratio prvi ključ")
+	}
+	expect("Next posle početka", true)
+}
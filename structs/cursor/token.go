@@ -0,0 +1,110 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"strings"
+)
+
+// Verzija formata tokena; prvi bajt 0x01 daje token koji uvek počinje slovom "A",
+// pa se token ne može zameniti sa brojem strane
+const tokenVersion = 1
+
+// Zaglavlje tokena: [verzija (1)][smer (1)][opseg (4)][snapshot (8)], pa poslednji ključ
+const tokenHeaderSize = 14
+
+// ContinuationToken pamti gde je skeniranje stalo: poslednji vraćeni ključ, smer i vreme
+// poslednjeg upisa u bazu u trenutku kada je strana pročitana (snapshot). Opseg je checksum
+// komande i granica skeniranja, tako da se token ne može iskoristiti za drugo skeniranje.
+// Token čuva samo poziciju: nastavak čita trenutno stanje baze iza LastKey, a snapshot služi
+// samo da se prijavi da su podaci izmenjeni (Memtable čuva samo najnoviju verziju ključa, pa
+// se stanje iz trenutka snapshot-a ne može rekonstruisati).
+type ContinuationToken struct {
+	LastKey    string
+	Descending bool
+	Scope      uint32
+	Snapshot   uint64
+}
+
+// ScanScope računa opseg tokena iz naziva komande i granica skeniranja
+func ScanScope(parts ...string) uint32 {
+	return crc32.ChecksumIEEE([]byte(strings.Join(parts, "\x00")))
+}
+
+// Encode vraća neproziran (base64) zapis tokena
+func (t ContinuationToken) Encode() string {
+	buf := make([]byte, tokenHeaderSize, tokenHeaderSize+len(t.LastKey))
+	buf[0] = tokenVersion
+	if t.Descending {
+		buf[1] = 1
+	}
+	binary.LittleEndian.PutUint32(buf[2:6], t.Scope)
+	binary.LittleEndian.PutUint64(buf[6:14], t.Snapshot)
+	buf = append(buf, t.LastKey...)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// DecodeContinuationToken čita token i proverava da pripada zadatom opsegu
+func DecodeContinuationToken(token string, scope uint32) (ContinuationToken, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(buf) <= tokenHeaderSize || buf[0] != tokenVersion {
+		return ContinuationToken{}, errors.New("neispravan token")
+	}
+	t := ContinuationToken{
+		Descending: buf[1] == 1,
+		Scope:      binary.LittleEndian.Uint32(buf[2:6]),
+		Snapshot:   binary.LittleEndian.Uint64(buf[6:14]),
+		LastKey:    string(buf[tokenHeaderSize:]),
+	}
+	if t.Scope != scope {
+		return ContinuationToken{}, errors.New("token ne pripada ovom skeniranju")
+	}
+	return t, nil
+}
+
+// ScanResult je jedan par ključ-vrednost sa strane skeniranja
+type ScanResult struct {
+	Key   string
+	Value []byte
+}
+
+// ScanPage čita najviše pageSize ključeva iz multicursora. Bez tokena počinje od početka
+// opsega (odnosno od kraja za opadajuće skeniranje) i preskače skip ključeva, a sa tokenom
+// nastavlja odmah iza poslednjeg vraćenog ključa. Vraća token za sledeću stranu ako još
+// ima ključeva, inače nil; snapshot i opseg novog tokena zadaje pozivalac.
+func ScanPage(mc *MultiCursor, descending bool, skip int, pageSize int, token *ContinuationToken) ([]ScanResult, *ContinuationToken) {
+	step := mc.Next
+	if descending {
+		step = mc.Prev
+	}
+	var ok bool
+	switch {
+	case token == nil:
+		ok = step()
+		for ; ok && skip > 0; skip-- {
+			ok = step()
+		}
+	case descending:
+		ok = mc.SeekForPrev(token.LastKey)
+		if ok && mc.Key() == token.LastKey {
+			ok = mc.Prev()
+		}
+	default:
+		ok = mc.Seek(token.LastKey)
+		if ok && mc.Key() == token.LastKey {
+			ok = mc.Next()
+		}
+	}
+	page := make([]ScanResult, 0, pageSize)
+	for ok && len(page) < pageSize {
+		page = append(page, ScanResult{Key: mc.Key(), Value: mc.Value()})
+		ok = step()
+	}
+	// Cursor je već na prvom ključu sledeće strane
+	if !ok || len(page) == 0 {
+		return page, nil
+	}
+	return page, &ContinuationToken{LastKey: page[len(page)-1].Key, Descending: descending}
+}
@@ -0,0 +1,87 @@
+package cursor_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"projekat/structs/containers"
+	"projekat/structs/cursor"
+)
+
+func TestContinuationTokenRoundTrip(t *testing.T) {
+	scope := cursor.ScanScope("RANGE_SCAN", "a", "z", "CONTAINS", "x")
+	for _, token := range []cursor.ContinuationToken{
+		{LastKey: "k", Scope: scope},
+		{LastKey: "user:001/\x00\xff ključ", Descending: true, Scope: scope, Snapshot: 1<<63 + 5},
+	} {
+		encoded := token.Encode()
+		// Token počinje slovom i ne može se zameniti sa brojem strane
+		if encoded[0] != 'A' || strings.ContainsAny(encoded, "+/=") {
+			t.Fatalf("token %q nije URL-bezbedan ili ne počinje slovom A", encoded)
+		}
+		decoded, err := cursor.DecodeContinuationToken(encoded, scope)
+		if err != nil || decoded != token {
+			t.Fatalf("dekodiran token %+v (%v), očekivano %+v", decoded, err, token)
+		}
+	}
+}
+
+// Token druge komande, drugih granica ili drugog filtera se odbija, kao i oštećen token
+func TestContinuationTokenRejected(t *testing.T) {
+	scope := cursor.ScanScope("PREFIX_SCAN", "user:")
+	encoded := cursor.ContinuationToken{LastKey: "user:7", Scope: scope, Snapshot: 9}.Encode()
+	for _, other := range []uint32{
+		cursor.ScanScope("RANGE_SCAN", "user:"),
+		cursor.ScanScope("PREFIX_SCAN", "user"),
+		cursor.ScanScope("PREFIX_SCAN", "user:", "LEN", ">", "3"),
+	} {
+		if _, err := cursor.DecodeContinuationToken(encoded, other); err == nil {
+			t.Fatalf("token prihvaćen za drugo skeniranje (opseg %x)", other)
+		}
+	}
+
+	raw, _ := base64.RawURLEncoding.DecodeString(encoded)
+	wrongVersion := append([]byte{2}, raw[1:]...)
+	for _, bad := range []string{
+		"", "3", "nije!base64", encoded[:len(encoded)-8],
+		base64.RawURLEncoding.EncodeToString(wrongVersion),
+		cursor.ContinuationToken{Scope: scope}.Encode(),
+	} {
+		if _, err := cursor.DecodeContinuationToken(bad, scope); err == nil {
+			t.Fatalf("neispravan token %q je prihvaćen", bad)
+		}
+	}
+}
+
+// Token pamti samo poziciju: nastavak počinje iza poslednjeg vraćenog ključa i čita trenutno
+// stanje, pa vidi ključeve i vrednosti upisane posle prve strane, i kada je poslednji ključ obrisan
+func TestScanPageResumesFromPosition(t *testing.T) {
+	mem := containers.NewSkipListMemtable(8, 1000, 0)
+	for i := 0; i < 10; i++ {
+		mem.Add(ts(1), false, fmt.Sprintf("k%d", i), []byte("staro"))
+	}
+	scan := func(token *cursor.ContinuationToken) ([]cursor.ScanResult, *cursor.ContinuationToken) {
+		mc := cursor.NewMultiCursor("k", "k\xff", mem.NewCursor())
+		defer mc.Close()
+		return cursor.ScanPage(mc, false, 0, 4, token)
+	}
+	page, next := scan(nil)
+	if len(page) != 4 || page[3].Key != "k3" || next == nil || next.LastKey != "k3" {
+		t.Fatalf("prva strana %v, token %+v", page, next)
+	}
+
+	mem.Delete(ts(2), "k3")
+	mem.Add(ts(2), false, "k35", []byte("novo"))
+	mem.Add(ts(2), false, "k4", []byte("novo"))
+	page, next = scan(next)
+	if len(page) != 4 || page[0].Key != "k35" || string(page[0].Value) != "novo" || page[1].Key != "k4" ||
+		string(page[1].Value) != "novo" || page[3].Key != "k6" || next == nil {
+		t.Fatalf("nastavak %v, token %+v", page, next)
+	}
+	page, next = scan(next)
+	if len(page) != 3 || page[2].Key != "k9" || next != nil {
+		t.Fatalf("poslednja strana %v, token %+v", page, next)
+	}
+}
@@ -1,77 +1,87 @@
 package lrucache
 
-type CacheNode struct {
-	key   string
-	value []byte
-	next  *CacheNode
-	prev  *CacheNode
+import "projekat/structs/cachepolicy"
+
+// Procena memorije koju pored ključa i vrednosti zauzima jedan unos keša
+const entryOverhead = 64
+
+// RowCache kešira rezultate čitanja ključeva iz SSTabela, uključujući i to da ključ ne postoji
+// (ili je obrisan). Veličina je ograničena brojem bajtova, a koje unose čuva bira politika
+// keša (LRU ili TinyLFU). Svaki upis ključa (i flush Memtable-a) mora pozvati Invalidate, a
+// brisanje opsega InvalidateRange, dok kompakcija ne menja vidljive vrednosti pa keš ostaje ispravan.
+type RowCache struct {
+	capacity int64
+	policy   cachepolicy.Policy[string, rowEntry]
+	hits     uint64
+	misses   uint64
 }
 
-type LRUCache struct {
-	hash     map[string]*CacheNode
-	head     *CacheNode
-	tail     *CacheNode
-	length   int
-	capacity int
+type rowEntry struct {
+	value  []byte
+	exists bool
 }
 
-func NewLRUCache(capacity int) LRUCache {
-	head := CacheNode{"", []byte{}, nil, nil}
-	tail := CacheNode{key: " ", value: []byte{}, next: &head, prev: nil}
-	head.prev = &tail
-	return LRUCache{make(map[string]*CacheNode), &head, &tail, 0, capacity}
+// NewRowCache pravi keš ograničen na capacity bajtova sa politikom zadatom po nazivu; 0 isključuje keš
+func NewRowCache(capacity int64, policy string) *RowCache {
+	return &RowCache{
+		capacity: capacity,
+		policy:   cachepolicy.New[string, rowEntry](policy, capacity, func(key string) string { return key }),
+	}
 }
 
-func (cache *LRUCache) CheckCache(key string) ([]byte, bool) {
-	node, ok := cache.hash[key]
-	if ok {
-		node.prev.next = node.next
-		node.next.prev = node.prev
-		node.next = cache.head
-		node.prev = cache.head.prev
-		cache.head.prev.next = node
-		cache.head.prev = node
-		return node.value, true
+// Get vraća keširan rezultat čitanja. cached je false ako ključ nije u kešu,
+// a exists je false ako je keširano da ključ ne postoji.
+func (c *RowCache) Get(key string) (value []byte, exists bool, cached bool) {
+	entry, ok := c.policy.Get(key)
+	if !ok {
+		c.misses++
+		return nil, false, false
 	}
-	return nil, false
+	c.hits++
+	return entry.value, entry.exists, true
+}
+
+// Put kešira vrednost ključa pročitanu sa diska
+func (c *RowCache) Put(key string, value []byte) {
+	c.policy.Put(key, rowEntry{value: value, exists: true}, int64(len(key)+len(value)+entryOverhead))
+}
+
+// PutMissing kešira da ključ ne postoji na disku
+func (c *RowCache) PutMissing(key string) {
+	c.policy.Put(key, rowEntry{}, int64(len(key)+entryOverhead))
 }
 
-func (cache *LRUCache) UpdateCache(key string, value []byte) {
-	node, exists := cache.hash[key]
-	if exists {
-		node.prev.next = node.next
-		node.next.prev = node.prev
-		node.next = cache.head
-		node.prev = cache.head.prev
-		cache.head.prev.next = node
-		cache.head.prev = node
-		node.value = value
-	} else {
-		newnode := CacheNode{key, value, cache.head, cache.head.prev}
-		cache.hash[key] = &newnode
-		cache.head.prev.next = &newnode
-		cache.head.prev = &newnode
-		if cache.length == cache.capacity {
-			cache.tail.next.next.prev = cache.tail
-			delete(cache.hash, cache.tail.next.key)
-			newnext := cache.tail.next.next
-			cache.tail.next.next = nil
-			cache.tail.next.prev = nil
-			cache.tail.next = newnext
-		} else {
-			cache.length++
+// Invalidate izbacuje ključ iz keša
+func (c *RowCache) Invalidate(key string) {
+	c.policy.Remove(key)
+}
+
+// InvalidateRange izbacuje iz keša sve ključeve iz [start, end)
+func (c *RowCache) InvalidateRange(start, end string) {
+	for _, key := range c.policy.Keys() {
+		if key >= start && key < end {
+			c.policy.Remove(key)
 		}
 	}
 }
 
-func (cache *LRUCache) DeleteFromCache(key string) {
-	node, exists := cache.hash[key]
-	if exists {
-		node.next.prev = node.prev
-		node.prev.next = node.next
-		node.next = nil
-		node.prev = nil
-		delete(cache.hash, node.key)
-		cache.length--
+// CacheStats opisuje popunjenost keša i broj pogodaka i promašaja
+type CacheStats struct {
+	Hits     uint64
+	Misses   uint64
+	Entries  int
+	Bytes    int64
+	Capacity int64
+}
+
+// HitRatio vraća udeo pogodaka u svim pretragama keša
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
 	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+func (c *RowCache) Stats() CacheStats {
+	return CacheStats{Hits: c.hits, Misses: c.misses, Entries: c.policy.Len(), Bytes: c.policy.Size(), Capacity: c.capacity}
 }
@@ -2,6 +2,7 @@ package memtable
 
 import (
 	"errors"
+	"unsafe"
 
 	"projekat/structs/cursor"
 	"projekat/structs/sstable"
@@ -18,13 +19,95 @@ type Record struct {
 // MemtableInterface definise zajednicki interfejs za sve implementacije Memtable-a
 type MemtableInterface interface {
 	Add(ts [16]byte, tombstone bool, key string, value []byte) error
-	Delete(key string) bool
+	// Delete upisuje tombstone sa vremenom brisanja ts (iz WAL-a) i prazni vrednost. Ključ koji
+	// nije u Memtable-u dobija novi zapis, jer starija verzija može biti na disku; tombstone se
+	// računa u popunjenost kao i svaki drugi zapis. Vraća true ako je ključ bio živ u Memtable-u.
+	Delete(ts [16]byte, key string) bool
 	Get(key string) ([]byte, bool, bool)
 	SetWatermark(index uint32)
 	GetWatermark() uint32
 	Flush() *[]Record
 	IsFull() bool
+	// SizeBytes vraća zauzeće Memtable-a u bajtovima (ključevi, vrednosti i zaglavlja zapisa)
+	SizeBytes() int
 	NewCursor() cursor.Cursor
+	// Brisanja opsega; implementacije ih dobijaju ugrađivanjem RangeTombstoneList
+	AddRangeTombstone(ts [16]byte, start, end string)
+	RangeTombstones() []sstable.RangeTombstone
+	FlushRangeTombstones() []sstable.RangeTombstone
+}
+
+// RangeTombstoneList čuva brisanja opsega upisana u Memtable. Ključevi iz opsega koji su već
+// u Memtable-u dobijaju tombstone pri brisanju, pa lista služi samo da sakrije starije
+// verzije ključeva sa diska dok se Memtable ne flush-uje.
+type RangeTombstoneList struct {
+	rangeTombstones []sstable.RangeTombstone
+}
+
+func (l *RangeTombstoneList) AddRangeTombstone(ts [16]byte, start, end string) {
+	l.rangeTombstones = append(l.rangeTombstones, sstable.RangeTombstone{Start: []byte(start), End: []byte(end), Timestamp: ts})
+}
+
+func (l *RangeTombstoneList) RangeTombstones() []sstable.RangeTombstone {
+	return l.rangeTombstones
+}
+
+// FlushRangeTombstones vraća brisanja opsega i prazni listu
+func (l *RangeTombstoneList) FlushRangeTombstones() []sstable.RangeTombstone {
+	rts := l.rangeTombstones
+	l.rangeTombstones = nil
+	return rts
+}
+
+// RecordOverhead je memorija koju zauzima jedan zapis pored ključa i vrednosti
+var RecordOverhead = int(unsafe.Sizeof(Record{}))
+
+// RecordSize vraća broj bajtova koje zapis zauzima u Memtable-u
+func RecordSize(key string, value []byte) int {
+	return len(key) + len(value) + RecordOverhead
+}
+
+// ByteCounter prati zauzeće Memtable-a u bajtovima. Kada je maxBytes zadat, Memtable je pun
+// kada zauzeće dostigne maxBytes; u suprotnom odlučuje broj zapisa (MaxMemtableSize).
+type ByteCounter struct {
+	maxBytes int
+	bytes    int
+}
+
+func NewByteCounter(maxBytes int) ByteCounter {
+	return ByteCounter{maxBytes: maxBytes}
+}
+
+// TrackWrite ažurira zauzeće posle upisa; oldValue se ignoriše ako ključ nije postojao
+func (c *ByteCounter) TrackWrite(key string, oldValue []byte, existed bool, newValue []byte) {
+	if existed {
+		c.bytes += len(newValue) - len(oldValue)
+	} else {
+		c.bytes += RecordSize(key, newValue)
+	}
+}
+
+func (c *ByteCounter) SizeBytes() int {
+	return c.bytes
+}
+
+// BytesFull vraća da li je Memtable pun; count i maxCount se koriste kada maxBytes nije zadat
+func (c *ByteCounter) BytesFull(count, maxCount int) bool {
+	if c.maxBytes > 0 {
+		return c.bytes >= c.maxBytes
+	}
+	return count >= maxCount
+}
+
+// ResetBytes poništava zauzeće posle flush-a
+func (c *ByteCounter) ResetBytes() {
+	c.bytes = 0
+}
+
+// FlushBatch je sadržaj Memtable-a pripremljen za upis u SSTabelu
+type FlushBatch struct {
+	Records         []sstable.Record
+	RangeTombstones []sstable.RangeTombstone
 }
 
 // Greška ukoliko je Memtable popunjen
@@ -42,19 +125,29 @@ func BoolToByte(b bool) byte {
 	return 0
 }
 
-// ConvertMemToSST konvertuje podatke iz Memtable u SSTable format
-func ConvertMemToSST(mt *MemtableInterface) *[]sstable.Record {
-	records := (*mt).Flush()
-	sstRecords := make([]sstable.Record, 0, len(*records))
-	for _, r := range *records {
+// ConvertMemToSST konvertuje podatke iz Memtable u SSTable format. Memtable se ne menja:
+// prazni se (ResetMemtable) tek kada je SSTable upisana, da zapisi ne nestanu ako upis ne uspe.
+func ConvertMemToSST(mt *MemtableInterface) *FlushBatch {
+	sstRecords := make([]sstable.Record, 0)
+	c := (*mt).NewCursor()
+	for ok := c.Seek(""); ok; ok = c.Next() {
+		key := c.Key()
 		sstRecords = append(sstRecords, sstable.Record{
-			Key:       []byte(r.Key),
-			Value:     r.Value,
-			KeySize:   uint64(len(r.Key)),
-			ValueSize: uint64(len(r.Value)),
-			Tombstone: r.Tombstone,
-			Timestamp: r.Timestamp,
+			Key:       []byte(key),
+			Value:     c.Value(),
+			KeySize:   uint64(len(key)),
+			ValueSize: uint64(len(c.Value())),
+			Tombstone: c.Tombstone(),
+			Timestamp: c.Timestamp(),
 		})
 	}
-	return &sstRecords
+	c.Close()
+	rangeTombstones := append([]sstable.RangeTombstone{}, (*mt).RangeTombstones()...)
+	return &FlushBatch{Records: sstRecords, RangeTombstones: rangeTombstones}
+}
+
+// ResetMemtable prazni Memtable posle uspešnog upisa na disk
+func ResetMemtable(mt MemtableInterface) {
+	mt.FlushRangeTombstones()
+	mt.Flush()
 }
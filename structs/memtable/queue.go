@@ -0,0 +1,178 @@
+package memtable
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Pauza pre ponovnog pokušaja neuspelog flush-a
+const flushRetryDelay = time.Second
+
+// Memtables čuva aktivni Memtable u koji se upisuje i red nepromenljivih (punih) Memtable-a
+// koje pozadinska gorutina redom, od najstarijeg, upisuje na disk. Upis se zaustavlja samo
+// kada broj nepromenljivih Memtable-a pređe dozvoljeni.
+//
+// Glavna petlja drži zaključavanje dok izvršava komandu. Flusher uzima najstariji Memtable pod
+// zaključavanjem, a SSTabelu upisuje bez njega - nepromenljivi Memtable se više ne menja, pa ga
+// komande i dalje čitaju. Zaključavanje se ponovo uzima samo da bi se tabela objavila u LSM
+// stablu i Memtable skinuo sa reda, pa komande ne čekaju na upis tabele.
+type Memtables struct {
+	mu           sync.Mutex
+	cond         *sync.Cond
+	active       MemtableInterface
+	immutable    []MemtableInterface // Od najstarijeg ka najnovijem
+	free         []MemtableInterface // Ispražnjeni Memtable-i za ponovnu upotrebu
+	newMemtable  func() MemtableInterface
+	maxImmutable int
+	flush        func(MemtableInterface) (func(), error)
+	running      bool
+	flushing     bool // Flusher upisuje Memtable bez zaključavanja
+}
+
+// NewMemtables kreira aktivni Memtable; newMemtable pravi nove Memtable-e pri rotaciji
+func NewMemtables(newMemtable func() MemtableInterface, maxImmutable int) *Memtables {
+	m := &Memtables{
+		active:       newMemtable(),
+		newMemtable:  newMemtable,
+		maxImmutable: max(maxImmutable, 0),
+	}
+	m.cond = sync.NewCond(&m.mu)
+	return m
+}
+
+func (m *Memtables) Lock() {
+	m.mu.Lock()
+}
+
+func (m *Memtables) Unlock() {
+	m.mu.Unlock()
+}
+
+// Active vraća Memtable u koji se upisuje
+func (m *Memtables) Active() MemtableInterface {
+	return m.active
+}
+
+// All vraća sve Memtable-e od najnovijeg ka najstarijem: aktivni, pa nepromenljive.
+// Čitanje se zaustavlja na prvom Memtable-u koji sadrži ključ.
+func (m *Memtables) All() []MemtableInterface {
+	all := make([]MemtableInterface, 0, len(m.immutable)+1)
+	all = append(all, m.active)
+	for i := len(m.immutable) - 1; i >= 0; i-- {
+		all = append(all, m.immutable[i])
+	}
+	return all
+}
+
+// ImmutableCount vraća broj Memtable-a koji čekaju flush
+func (m *Memtables) ImmutableCount() int {
+	return len(m.immutable)
+}
+
+// Add upisuje zapis u aktivni Memtable; kada se on popuni, zamrzava se i vraća true
+func (m *Memtables) Add(ts [16]byte, tombstone bool, key string, value []byte, segment uint32) bool {
+	return m.write(segment, func(mt MemtableInterface) {
+		mt.Add(ts, tombstone, key, value)
+	})
+}
+
+// Delete upisuje brisanje ključa u aktivni Memtable; kada se on popuni, zamrzava se i vraća true
+func (m *Memtables) Delete(ts [16]byte, key string, segment uint32) bool {
+	return m.write(segment, func(mt MemtableInterface) {
+		mt.Delete(ts, key)
+	})
+}
+
+// write izvršava upis nad aktivnim Memtable-om i zamrzava ga kada se popuni
+func (m *Memtables) write(segment uint32, apply func(MemtableInterface)) bool {
+	rotated := false
+	// Aktivni Memtable može biti popunjen i upisima mimo Add (brisanje opsega, token bucket)
+	if m.active.IsFull() {
+		m.rotate()
+		rotated = true
+	}
+	apply(m.active)
+	m.active.SetWatermark(segment)
+	if m.active.IsFull() {
+		m.rotate()
+		rotated = true
+	}
+	return rotated
+}
+
+// rotate zamrzava aktivni Memtable i budi flusher
+func (m *Memtables) rotate() {
+	m.immutable = append(m.immutable, m.active)
+	if len(m.free) > 0 {
+		m.active = m.free[len(m.free)-1]
+		m.free = m.free[:len(m.free)-1]
+	} else {
+		m.active = m.newMemtable()
+	}
+	m.cond.Broadcast()
+}
+
+// WaitForRoom čeka dok broj nepromenljivih Memtable-a ne padne na dozvoljeni.
+// Vraća true ako je upis morao da sačeka flush.
+func (m *Memtables) WaitForRoom() bool {
+	waited := false
+	for m.running && len(m.immutable) > m.maxImmutable {
+		waited = true
+		m.cond.Wait()
+	}
+	return waited
+}
+
+// StartFlusher pokreće gorutinu koja nepromenljive Memtable-e redom prosleđuje funkciji flush.
+// Poziva se sa zaključavanjem. flush se izvršava bez zaključavanja i ne sme menjati stanje koje
+// komande čitaju; ono se menja u funkciji koju flush vraća (objava tabele), a koja se izvršava
+// pod zaključavanjem neposredno pre nego što se Memtable skine sa reda.
+func (m *Memtables) StartFlusher(flush func(MemtableInterface) (func(), error)) {
+	m.flush = flush
+	m.running = true
+	go m.run()
+}
+
+func (m *Memtables) run() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for {
+		for m.running && len(m.immutable) == 0 {
+			m.cond.Wait()
+		}
+		if !m.running {
+			return
+		}
+		oldest := m.immutable[0]
+		m.flushing = true
+		m.mu.Unlock()
+		publish, err := m.flush(oldest)
+		m.mu.Lock()
+		m.flushing = false
+		if err != nil {
+			// Memtable ostaje na čelu reda i vidljiv za čitanje, a upis se ponavlja. Noviji
+			// Memtable-i se ne upisuju pre njega, pa se ni WAL segmenti sa njegovim zapisima ne brišu.
+			fmt.Printf("Greška pri kreiranju SSTable: %v\n", err)
+			m.cond.Broadcast()
+			m.mu.Unlock()
+			time.Sleep(flushRetryDelay)
+			m.mu.Lock()
+			continue
+		}
+		publish()
+		m.immutable = m.immutable[1:]
+		m.free = append(m.free, oldest)
+		m.cond.Broadcast()
+	}
+}
+
+// Close zaustavlja flush i čeka da se završi upis koji je u toku, da na disku ne bi ostala
+// nedovršena tabela; nepromenljivi Memtable-i koji nisu upisani ostaju u WAL-u
+func (m *Memtables) Close() {
+	m.running = false
+	m.cond.Broadcast()
+	for m.flushing {
+		m.cond.Wait()
+	}
+}
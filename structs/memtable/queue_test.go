@@ -0,0 +1,143 @@
+package memtable_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"projekat/structs/containers"
+	"projekat/structs/memtable"
+)
+
+// Neuspeli flush ne sme da izgubi zapise: Memtable ostaje na čelu reda, vidljiv za čitanje,
+// i ponovo se upisuje pre novijih Memtable-a
+func TestFlushFailureKeepsMemtable(t *testing.T) {
+	m := memtable.NewMemtables(func() memtable.MemtableInterface {
+		return containers.NewHashMapMemtable(2, 0)
+	}, 4)
+
+	attempts := make(chan int, 8)
+	flushed := make(chan []string, 8)
+	failures := 1
+	m.Lock()
+	m.StartFlusher(func(mt memtable.MemtableInterface) (func(), error) {
+		attempts <- failures
+		batch := memtable.ConvertMemToSST(&mt)
+		if failures > 0 {
+			failures--
+			return nil, errors.New("disk je pun")
+		}
+		keys := make([]string, 0, len(batch.Records))
+		for _, r := range batch.Records {
+			keys = append(keys, string(r.Key))
+		}
+		return func() {
+			memtable.ResetMemtable(mt)
+			flushed <- keys
+		}, nil
+	})
+	m.Add([16]byte{1}, false, "a", []byte("1"), 1)
+	m.Add([16]byte{2}, false, "b", []byte("2"), 1)
+	m.Unlock()
+
+	// Prvi pokušaj ne uspeva; zapisi su i dalje u redu
+	<-attempts
+	m.Lock()
+	if m.ImmutableCount() != 1 {
+		t.Fatalf("posle neuspelog flush-a očekivan 1 nepromenljiv Memtable, dobijeno %d", m.ImmutableCount())
+	}
+	found := false
+	for _, mt := range m.All() {
+		if v, _, ok := mt.Get("a"); ok && string(v) == "1" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("zapis neuspelog flush-a nije vidljiv za čitanje")
+	}
+	// Noviji Memtable čeka iza neuspelog
+	m.Add([16]byte{3}, false, "c", []byte("3"), 2)
+	m.Add([16]byte{4}, false, "d", []byte("4"), 2)
+	m.Unlock()
+
+	first := <-flushed
+	second := <-flushed
+	if len(first) != 2 || first[0] != "a" || first[1] != "b" {
+		t.Fatalf("prvi upisan Memtable treba da bude [a b], dobijeno %v", first)
+	}
+	if len(second) != 2 || second[0] != "c" || second[1] != "d" {
+		t.Fatalf("drugi upisan Memtable treba da bude [c d], dobijeno %v", second)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	m.Close()
+	if m.ImmutableCount() != 0 {
+		t.Fatalf("očekivan prazan red, dobijeno %d", m.ImmutableCount())
+	}
+}
+
+// Dok flusher upisuje SSTable, red nije zaključan: upis i čitanje se završavaju pre kraja
+// flush-a, a Close čeka da se upis koji je u toku objavi
+func TestFlushDoesNotBlockCommands(t *testing.T) {
+	m := memtable.NewMemtables(func() memtable.MemtableInterface {
+		return containers.NewHashMapMemtable(2, 0)
+	}, 4)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	published := false
+	m.Lock()
+	m.StartFlusher(func(mt memtable.MemtableInterface) (func(), error) {
+		close(started)
+		<-release
+		return func() {
+			memtable.ResetMemtable(mt)
+			published = true
+		}, nil
+	})
+	m.Add([16]byte{1}, false, "a", []byte("1"), 1)
+	m.Add([16]byte{2}, false, "b", []byte("2"), 1)
+	m.Unlock()
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		m.Lock()
+		defer m.Unlock()
+		m.Add([16]byte{3}, false, "c", []byte("3"), 2)
+		for _, mt := range m.All() {
+			if v, _, ok := mt.Get("a"); ok && string(v) == "1" {
+				close(done)
+				return
+			}
+		}
+		t.Error("zapis Memtable-a koji se upisuje nije vidljiv za čitanje")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upis i čitanje čekaju na flush")
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		m.Lock()
+		defer m.Unlock()
+		m.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close se vratio pre kraja flush-a")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-closed
+	m.Lock()
+	defer m.Unlock()
+	if !published || m.ImmutableCount() != 0 {
+		t.Fatalf("flush nije objavljen pre Close (objavljen %v, u redu %d)", published, m.ImmutableCount())
+	}
+}
@@ -27,21 +27,38 @@ func NewMerkleTree() MerkleTree {
 }
 
 func (mt *MerkleTree) ConstructMerkleTree(bytes []byte, blockSize int) {
-	// Generisanje listova stabla
-	leaves := make([]MerkleNode, 0)
+	// Generisanje hash-eva listova stabla
+	hashes := make([][]byte, 0)
 	for i := 0; i < len(bytes); i += blockSize {
 		chunk := bytes[i:min(i+blockSize, len(bytes)-1)]
 		hash := md5.Sum(chunk)
-		leaf := MerkleNode{hash[:], nil, nil}
-		leaves = append(leaves, leaf)
+		hashes = append(hashes, hash[:])
 	}
-	if len(leaves)%2 != 0 && len(leaves) != 1 {
+	mt.ConstructFromLeafHashes(hashes)
+}
+
+// ConstructFromLeafHashes gradi stablo nad već izračunatim hash-evima listova
+func (mt *MerkleTree) ConstructFromLeafHashes(hashes [][]byte) {
+	leaves := make([]MerkleNode, 0, len(hashes))
+	for _, hash := range hashes {
+		leaves = append(leaves, MerkleNode{hash, nil, nil})
+	}
+	if len(leaves) == 0 {
+		hash := md5.Sum(nil)
+		leaves = append(leaves, MerkleNode{hash[:], nil, nil})
+	}
+	// Stablo sa jednim listom - list je ujedno i koren
+	if len(leaves) == 1 {
+		mt.MerkleRoot = leaves[0]
+		return
+	}
+	if len(leaves)%2 != 0 {
 		leaves = append(leaves, MerkleNode{make([]byte, 16), nil, nil})
 	}
 	// Izgradnja viših nivoa stabla
 	nextlevel := make([]MerkleNode, 0)
 	for i := 1; i < len(leaves); i += 2 {
-		newhash := md5.Sum(append(leaves[i-1].Hash, leaves[i].Hash...))
+		newhash := md5.Sum(append(append([]byte{}, leaves[i-1].Hash...), leaves[i].Hash...))
 		newnode := MerkleNode{newhash[:], &leaves[i-1], &leaves[i]}
 		nextlevel = append(nextlevel, newnode)
 	}
@@ -52,7 +69,7 @@ func (mt *MerkleTree) ConstructMerkleTree(bytes []byte, blockSize int) {
 	for len(prevlevel) > 1 {
 		nextlevel = make([]MerkleNode, 0)
 		for i := 0; i < len(prevlevel); i += 2 {
-			newhash := md5.Sum(append(prevlevel[i].Hash, prevlevel[i+1].Hash...))
+			newhash := md5.Sum(append(append([]byte{}, prevlevel[i].Hash...), prevlevel[i+1].Hash...))
 			newnode := MerkleNode{newhash[:], &prevlevel[i], &prevlevel[i+1]}
 			nextlevel = append(nextlevel, newnode)
 		}
@@ -64,6 +81,40 @@ func (mt *MerkleTree) ConstructMerkleTree(bytes []byte, blockSize int) {
 	mt.MerkleRoot = prevlevel[0]
 }
 
+// LeafHasher računa hash-eve listova za podatke koji pristižu deo po deo,
+// tako da nije potrebno držati ceo data segment u memoriji
+type LeafHasher struct {
+	blockSize int
+	pending   []byte
+	hashes    [][]byte
+}
+
+func NewLeafHasher(blockSize int) *LeafHasher {
+	return &LeafHasher{blockSize: blockSize, pending: make([]byte, 0, blockSize), hashes: make([][]byte, 0)}
+}
+
+// Write dodaje nove bajtove; pun blok se hashira tek kada znamo da nije poslednji
+func (lh *LeafHasher) Write(p []byte) {
+	lh.pending = append(lh.pending, p...)
+	for len(lh.pending) > lh.blockSize {
+		hash := md5.Sum(lh.pending[:lh.blockSize])
+		lh.hashes = append(lh.hashes, hash[:])
+		lh.pending = append(lh.pending[:0], lh.pending[lh.blockSize:]...)
+	}
+}
+
+// Finish hashira poslednji blok na isti način kao ConstructMerkleTree i vraća stablo
+func (lh *LeafHasher) Finish() MerkleTree {
+	if len(lh.pending) > 0 {
+		hash := md5.Sum(lh.pending[:len(lh.pending)-1])
+		lh.hashes = append(lh.hashes, hash[:])
+		lh.pending = lh.pending[:0]
+	}
+	mt := NewMerkleTree()
+	mt.ConstructFromLeafHashes(lh.hashes)
+	return mt
+}
+
 func (mt MerkleTree) Serialize() []byte {
 	bytes := make([]byte, 0)
 	queue := make([]*MerkleNode, 0)
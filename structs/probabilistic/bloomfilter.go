@@ -3,10 +3,15 @@ package probabilistic
 import (
 	"crypto/md5"
 	"encoding/binary"
+	"hash/fnv"
 	"math"
 	"time"
 )
 
+// Najviši bit broja hash funkcija u serijalizovanom filteru označava double hashing
+// (tada se seed-ovi ne zapisuju)
+const bfDoubleHashFlag uint32 = 1 << 31
+
 type HashWithSeed struct {
 	Seed []byte
 }
@@ -14,6 +19,9 @@ type HashWithSeed struct {
 type BloomFilter struct {
 	array  []bool
 	hashes []HashWithSeed
+	// Double hashing: k indeksa se izvodi iz jednog 128-bitnog hash-a kao h1 + i*h2
+	doubleHash bool
+	k          uint32
 }
 
 func BF_CalculateM(expectedElements int, falsePositiveRate float64) uint {
@@ -50,8 +58,61 @@ func CreateBF(length int, rate float64) BloomFilter {
 	}
 }
 
+// CreateDoubleHashBF pravi filter koji za sve probe računa samo jedan hash
+func CreateDoubleHashBF(length int, rate float64) BloomFilter {
+	m := BF_CalculateM(length, rate)
+	return BloomFilter{
+		array:      make([]bool, m),
+		doubleHash: true,
+		k:          uint32(BF_CalculateK(length, m)),
+	}
+}
+
+// CreateDoubleHashBFBitsPerKey pravi filter sa zadatim brojem bitova po ključu
+func CreateDoubleHashBFBitsPerKey(length int, bitsPerKey int) BloomFilter {
+	m := uint(max(length*bitsPerKey, 1))
+	return BloomFilter{
+		array:      make([]bool, m),
+		doubleHash: true,
+		k:          uint32(max(math.Round(float64(bitsPerKey)*math.Log(2)), 1)),
+	}
+}
+
+// doubleHashBase vraća h1 i h2 iz jednog FNV-128a hash-a elementa
+func doubleHashBase(data []byte) (uint64, uint64) {
+	fn := fnv.New128a()
+	fn.Write(data)
+	sum := fn.Sum(nil)
+	h1 := binary.BigEndian.Uint64(sum[:8])
+	// h2 je neparan, pa je uzajamno prost sa m kada je m stepen dvojke i k proba pogađa k
+	// različitih pozicija; za ostale m (m se računa iz stope) probe se mogu ponoviti kada h2
+	// i m imaju zajednički delilac, što samo neznatno povećava stopu lažno pozitivnih
+	h2 := binary.BigEndian.Uint64(sum[8:]) | 1
+	return h1, h2
+}
+
+// HashCount vraća broj proba po elementu
+func (bf *BloomFilter) HashCount() int {
+	if bf.doubleHash {
+		return int(bf.k)
+	}
+	return len(bf.hashes)
+}
+
+// Size vraća broj bitova filtera
+func (bf *BloomFilter) Size() int {
+	return len(bf.array)
+}
+
 func (bf *BloomFilter) AddElement(elem string) {
 	temp := []byte(elem)
+	if bf.doubleHash {
+		h1, h2 := doubleHashBase(temp)
+		for i := uint64(0); i < uint64(bf.k); i++ {
+			bf.array[(h1+i*h2)%uint64(len(bf.array))] = true
+		}
+		return
+	}
 	for i := 0; i < int(len(bf.hashes)); i++ {
 		index := bf.hashes[i].Hash(temp)
 		compressed := index % uint64(len(bf.array))
@@ -61,6 +122,15 @@ func (bf *BloomFilter) AddElement(elem string) {
 
 func (bf *BloomFilter) IsAdded(elem string) bool {
 	temp := []byte(elem)
+	if bf.doubleHash {
+		h1, h2 := doubleHashBase(temp)
+		for i := uint64(0); i < uint64(bf.k); i++ {
+			if !bf.array[(h1+i*h2)%uint64(len(bf.array))] {
+				return false
+			}
+		}
+		return true
+	}
 	for i := 0; i < len(bf.hashes); i++ {
 		index := bf.hashes[i].Hash(temp)
 		compressed := index % uint64(len(bf.array))
@@ -82,6 +152,9 @@ func (bf BloomFilter) Serialize() []byte {
 			bytes = append(bytes, 0)
 		}
 	}
+	if bf.doubleHash {
+		return binary.BigEndian.AppendUint32(bytes, bf.k|bfDoubleHashFlag)
+	}
 	k := uint32(len(bf.hashes))
 	bytes = binary.BigEndian.AppendUint32(bytes, k)
 	for i := 0; i < int(k); i++ {
@@ -98,6 +171,13 @@ func (bf *BloomFilter) Deserialize(bytes []byte) {
 	}
 	bf.array = boolarr
 	k := binary.BigEndian.Uint32(bytes[4+m : 8+m])
+	if k&bfDoubleHashFlag != 0 {
+		bf.doubleHash = true
+		bf.k = k &^ bfDoubleHashFlag
+		bf.hashes = nil
+		return
+	}
+	bf.doubleHash = false
 	hasharr := make([]HashWithSeed, k)
 	for i := 0; i < int(k); i++ {
 		start := int(8 + m + uint32(i)*4)
@@ -0,0 +1,100 @@
+package probabilistic
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+func bfKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("ključ-%04d", i)
+	}
+	return keys
+}
+
+// Filter sa double hashing-om posle serijalizacije zadržava veličinu, broj proba i sve ključeve,
+// a seed-ovi se ne zapisuju
+func TestBloomFilterDoubleHashRoundTrip(t *testing.T) {
+	keys := bfKeys(1000)
+	for _, bf := range []BloomFilter{CreateDoubleHashBF(len(keys), 0.01), CreateDoubleHashBFBitsPerKey(len(keys), 10)} {
+		for _, key := range keys {
+			bf.AddElement(key)
+		}
+		data := bf.Serialize()
+		if want := 4 + bf.Size() + 4; len(data) != want {
+			t.Fatalf("serijalizovan filter ima %d bajtova, očekivano %d", len(data), want)
+		}
+		var decoded BloomFilter
+		decoded.Deserialize(data)
+		if decoded.Size() != bf.Size() || decoded.HashCount() != bf.HashCount() || !decoded.doubleHash {
+			t.Fatalf("dekodiran filter: %d bitova, %d proba, double hashing %v; očekivano %d, %d",
+				decoded.Size(), decoded.HashCount(), decoded.doubleHash, bf.Size(), bf.HashCount())
+		}
+		for _, key := range keys {
+			if !decoded.IsAdded(key) {
+				t.Fatalf("ključ %s nije pronađen posle deserijalizacije", key)
+			}
+		}
+		falsePositives := 0
+		for i := 0; i < 10000; i++ {
+			if decoded.IsAdded(fmt.Sprintf("drugi-%05d", i)) {
+				falsePositives++
+			}
+		}
+		if falsePositives > 300 {
+			t.Fatalf("%d lažno pozitivnih od 10000", falsePositives)
+		}
+	}
+}
+
+// Filteri starijih tabela imaju broj hash funkcija bez zastavice i seed funkcije posle njega;
+// bajtovi se ovde sastavljaju ručno, nezavisno od Serialize
+func TestBloomFilterLegacyDecode(t *testing.T) {
+	const m = 256
+	seeds := [][]byte{{0, 0, 0, 1}, {0, 0, 0, 2}, {0, 0, 0, 3}}
+	index := func(key string, seed []byte) int {
+		sum := md5.Sum(append([]byte(key), seed...))
+		return int(binary.BigEndian.Uint64(sum[:8]) % m)
+	}
+	keys := bfKeys(20)
+	bits := make([]byte, m)
+	for _, key := range keys {
+		for _, seed := range seeds {
+			bits[index(key, seed)] = 1
+		}
+	}
+	data := binary.BigEndian.AppendUint32(nil, m)
+	data = append(data, bits...)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(seeds)))
+	for _, seed := range seeds {
+		data = append(data, seed...)
+	}
+
+	var bf BloomFilter
+	bf.Deserialize(data)
+	if bf.doubleHash || bf.HashCount() != len(seeds) || bf.Size() != m {
+		t.Fatalf("stari filter dekodiran kao double hashing %v, %d proba, %d bitova", bf.doubleHash, bf.HashCount(), bf.Size())
+	}
+	for _, key := range keys {
+		if !bf.IsAdded(key) {
+			t.Fatalf("ključ %s nije pronađen u starom filteru", key)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("drugi-%04d", i)
+		want := true
+		for _, seed := range seeds {
+			want = want && bits[index(key, seed)] == 1
+		}
+		if bf.IsAdded(key) != want {
+			t.Fatalf("ključ %s: IsAdded %v, a biti seed funkcija %v", key, !want, want)
+		}
+	}
+	// Ponovna serijalizacija čuva stari format
+	if string(bf.Serialize()) != string(data) {
+		t.Fatal("stari filter se ne serijalizuje u isti format")
+	}
+}
@@ -68,6 +68,37 @@ func (cms *CountMinSketch) FindCount(elem string) uint32 {
 	return count
 }
 
+// AddHash beleži element zadat 64-bitnim hešom. Indeksi redova se izvode dvostrukim heširanjem
+// (h1 + i*h2), pa je po elementu potreban jedan heš umesto po jednog za svaki red.
+func (cms *CountMinSketch) AddHash(hash uint64) {
+	for i := range cms.Matrix {
+		cms.Matrix[i][rowIndex(hash, i, len(cms.Matrix[i]))]++
+	}
+}
+
+// FindCountHash vraća procenu broja pojavljivanja elementa zadatog 64-bitnim hešom
+func (cms *CountMinSketch) FindCountHash(hash uint64) uint32 {
+	count := uint32(math.MaxUint32)
+	for i := range cms.Matrix {
+		count = min(count, cms.Matrix[i][rowIndex(hash, i, len(cms.Matrix[i]))])
+	}
+	return count
+}
+
+func rowIndex(hash uint64, row int, m int) uint64 {
+	h1, h2 := hash&0xffffffff, hash>>32|1
+	return (h1 + uint64(row)*h2) % uint64(m)
+}
+
+// Halve deli sve brojače sa dva, tako da starija pojavljivanja vremenom gube na težini
+func (cms *CountMinSketch) Halve() {
+	for i := range cms.Matrix {
+		for j := range cms.Matrix[i] {
+			cms.Matrix[i][j] /= 2
+		}
+	}
+}
+
 func (cms *CountMinSketch) Serialize() []byte {
 	bytes := make([]byte, 0)
 	k := uint32(len(cms.Matrix))
@@ -0,0 +1,54 @@
+package probabilistic
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// Procena nikada nije manja od stvarnog broja pojavljivanja, a za retko popunjen sketch je tačna
+func TestCountMinSketchHash(t *testing.T) {
+	cms := CreateCountMinSketch(0.001, 0.01)
+	r := rand.New(rand.NewSource(1))
+	counts := make(map[uint64]uint32)
+	hashes := make([]uint64, 500)
+	for i := range hashes {
+		hashes[i] = r.Uint64()
+	}
+	for i := 0; i < 20000; i++ {
+		h := hashes[r.Intn(len(hashes))]
+		cms.AddHash(h)
+		counts[h]++
+	}
+	exact := 0
+	for h, want := range counts {
+		got := cms.FindCountHash(h)
+		if got < want {
+			t.Fatalf("heš %x: procena %d manja od stvarnog broja %d", h, got, want)
+		}
+		if got == want {
+			exact++
+		}
+	}
+	if exact < len(counts)*9/10 {
+		t.Fatalf("tačno procenjeno samo %d od %d elemenata", exact, len(counts))
+	}
+	if got := cms.FindCountHash(r.Uint64()); got > 20 {
+		t.Fatalf("element koji nije dodat ima procenu %d", got)
+	}
+}
+
+// Halve prepolovljava sve brojače, pa i procene
+func TestCountMinSketchHalve(t *testing.T) {
+	cms := CreateCountMinSketch(0.01, 0.01)
+	for i := 0; i < 9; i++ {
+		cms.AddHash(42)
+	}
+	cms.AddHash(7)
+	cms.Halve()
+	if got := cms.FindCountHash(42); got != 4 {
+		t.Fatalf("posle Halve: %d, očekivano 4", got)
+	}
+	if got := cms.FindCountHash(7); got != 0 {
+		t.Fatalf("posle Halve: %d, očekivano 0", got)
+	}
+}
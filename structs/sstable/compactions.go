@@ -2,10 +2,12 @@ package sstable
 
 import (
 	"bytes"
+	"container/heap"
 	"encoding/binary"
 	"os"
 	"path/filepath"
 	"projekat/structs/blockmanager"
+	"projekat/structs/probabilistic"
 	"slices"
 	"strings"
 )
@@ -30,9 +32,21 @@ func ReadTableFromDir(subdirPath string) (*SSTable, error) {
 			if strings.HasSuffix(f.Name(), "Summary.db") {
 				sst.SummaryFilePath = filepath.Join(subdirPath, f.Name())
 			}
-			if strings.HasSuffix(f.Name(), "Filter.db") {
+			if strings.HasSuffix(f.Name(), "-Filter.db") {
 				sst.FilterFilePath = filepath.Join(subdirPath, f.Name())
 			}
+			if strings.HasSuffix(f.Name(), "-PrefixFilter.db") {
+				sst.PrefixFilterFilePath = filepath.Join(subdirPath, f.Name())
+			}
+			if strings.HasSuffix(f.Name(), "-RangeFilter.db") {
+				sst.RangeFilterFilePath = filepath.Join(subdirPath, f.Name())
+			}
+			if strings.HasSuffix(f.Name(), "-IndexOffsets.db") {
+				sst.IndexOffsetsFilePath = filepath.Join(subdirPath, f.Name())
+			}
+			if strings.HasSuffix(f.Name(), "-RangeTombstones.db") {
+				sst.RangeTombstonesFilePath = filepath.Join(subdirPath, f.Name())
+			}
 			if strings.HasSuffix(f.Name(), "Metadata.db") {
 				sst.MetadataFilePath = filepath.Join(subdirPath, f.Name())
 			}
@@ -62,88 +76,311 @@ func ReadSummaryFromTable(sst *SSTable, bm *blockmanager.BlockManager, blockSize
 	return &sum, err
 }
 
-func Compaction(tables []*SSTable, blockSize int, bm *blockmanager.BlockManager,
-	dir string, step int, single bool, lsm byte, compress bool, dict *Dictionary, dictPath string) (*SSTable, string, error) {
+// CountRecordsInTable prebrojava zapise SSTabele preko njenog indexa
+func CountRecordsInTable(sst *SSTable, bm *blockmanager.BlockManager, blockSize int) (int, error) {
+	var indices []Index
+	if sst.SingleSSTable {
+		offsets, err := parseHeader(bm, sst.SingleFilePath, blockSize)
+		if err != nil {
+			return 0, err
+		}
+		indices, err = ReadIndexBlockSingleFile(bm, sst.SingleFilePath, offsets[1], offsets[2]-offsets[1], blockSize)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		info, err := os.Stat(sst.IndexFilePath)
+		if err != nil {
+			return 0, err
+		}
+		indices, err = ReadIndexBlock(bm, sst.IndexFilePath, 0, info.Size(), blockSize)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(indices), nil
+}
 
-	recordMatrix := make([][]*Record, len(tables))
-	// Učitavanje svih Summary-ja
-	summaries := make([]*Summary, len(tables))
-	for i := range tables {
-		sum, err := ReadSummaryFromTable(tables[i], bm, blockSize)
+// TableStats opisuje jednu tabelu nivoa za komandu LEVELS
+type TableStats struct {
+	Dir      string
+	MinKey   []byte
+	MaxKey   []byte
+	Records  int
+	Size     int64
+	Overlaps bool
+}
+
+// DescribeLevel vraća opis svake tabele nivoa i označava tabele čiji se opseg
+// preklapa sa nekom drugom tabelom istog nivoa
+func DescribeLevel(dirs []string, bm *blockmanager.BlockManager, blockSize int) ([]TableStats, error) {
+	stats := make([]TableStats, 0, len(dirs))
+	for _, dir := range dirs {
+		sst, err := ReadTableFromDir(dir)
 		if err != nil {
-			return nil, "", err
+			return nil, err
+		}
+		sum, err := ReadSummaryFromTable(sst, bm, blockSize)
+		if err != nil {
+			return nil, err
 		}
-		summaries[i] = sum
+		count, err := CountRecordsInTable(sst, bm, blockSize)
+		if err != nil {
+			return nil, err
+		}
+		size, err := tableSize(dir)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, TableStats{Dir: dir, MinKey: sum.MinKey, MaxKey: sum.MaxKey, Records: count, Size: size})
 	}
-	// Parsiranje svih zapisa u tabeli
-	for i := range tables {
-		records := make([]*Record, 0)
-		maxKey := summaries[i].MaxKey
-		offset := int64(0)
-		var path string
-		if tables[i].SingleSSTable {
-			path = tables[i].SingleFilePath
-			offset += 48
-		} else {
-			path = tables[i].DataFilePath
-		}
-		for {
-			record, length, err := ReadRecordAtOffset(bm, path, offset, blockSize, compress, dict)
-			if err != nil {
-				return nil, "", err
-			}
-			records = append(records, record)
-			if bytes.Equal(record.Key, maxKey) {
+	for i := range stats {
+		for j := range stats {
+			if i != j && bytes.Compare(stats[i].MinKey, stats[j].MaxKey) <= 0 && bytes.Compare(stats[j].MinKey, stats[i].MaxKey) <= 0 {
+				stats[i].Overlaps = true
 				break
 			}
-			offset += int64(length)
 		}
-		recordMatrix[i] = records
 	}
+	return stats, nil
+}
 
-	cursors := make([]int, len(tables))
-	sortedRecords := make([]Record, 0)
-	for {
-		// Maksimalna vrednost za string, iteriramo i tražimo najmanju vrednost
-		nextKey := "\xff"
-		for i, cursor := range cursors {
-			if cursor == len(recordMatrix[i]) {
+// ReadFilterFromTable učitava Bloom filter SSTabele bez obzira na format
+func ReadFilterFromTable(sst *SSTable, bm *blockmanager.BlockManager, blockSize int) (*probabilistic.BloomFilter, error) {
+	if sst.SingleSSTable {
+		offsets, err := parseHeader(bm, sst.SingleFilePath, blockSize)
+		if err != nil {
+			return nil, err
+		}
+		return LoadBloomFilterSingleFile(bm, sst.SingleFilePath, blockSize, offsets[3], offsets[4])
+	}
+	return LoadBloomFilter(bm, sst.FilterFilePath, blockSize)
+}
+
+// mergeItem je jedan ulaz u k-way merge - cursor nad jednom ulaznom tabelom
+type mergeItem struct {
+	cursor *SSTableCursor
+	order  int
+}
+
+// mergeHeap je min-heap ulaznih cursora uređen po trenutnom ključu
+type mergeHeap []*mergeItem
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	ki, kj := h[i].cursor.Key(), h[j].cursor.Key()
+	if ki != kj {
+		return ki < kj
+	}
+	return h[i].order < h[j].order
+}
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(*mergeItem)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// tombstoneGuard odlučuje da li se tombstone sme fizički obrisati tokom kompakcije.
+// Tombstone se čuva sve dok neka tabela van kompakcije (stariji nivoi ili drugi tier-ovi)
+// može sadržati stariju verziju ključa - u suprotnom bi ta verzija ponovo "oživela".
+type tombstoneGuard struct {
+	older []*TableReader
+}
+
+func newTombstoneGuard(older []*TableReader) *tombstoneGuard {
+	return &tombstoneGuard{older: older}
+}
+
+// mayContainOlder vraća true ako bar jedna starija tabela može sadržati ključ
+func (g *tombstoneGuard) mayContainOlder(key []byte) bool {
+	for _, reader := range g.older {
+		if bytes.Compare(key, reader.Summary.MinKey) < 0 || bytes.Compare(key, reader.Summary.MaxKey) > 0 {
+			continue
+		}
+		if reader.Filter.IsAdded(string(key)) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlapsOlder vraća true ako opseg neke starije tabele seče brisanje opsega
+func (g *tombstoneGuard) overlapsOlder(rt RangeTombstone) bool {
+	for _, reader := range g.older {
+		if rt.Overlaps(reader.Summary.MinKey, reader.Summary.MaxKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// olderTables vraća readere tabela na nivoima >= fromLevel koje ne učestvuju u kompakciji, a čiji
+// opseg seče [minKey, maxKey] (opseg ulaza zajedno sa njihovim brisanjima opsega). Ostale tabele
+// ne mogu sadržati nijedan ključ kompakcije, pa se ne otvaraju; readeri se uzimaju iz TableCache-a.
+func (c *compactor) olderTables(lsm map[byte][]string, fromLevel byte, exclude []string, minKey, maxKey []byte) ([]*TableReader, error) {
+	readers := make([]*TableReader, 0)
+	for level, dirs := range lsm {
+		if level < fromLevel {
+			continue
+		}
+		for _, dir := range dirs {
+			if slices.Contains(exclude, dir) {
+				continue
+			}
+			tableMin, tableMax, err := c.tables.KeyRange(dir)
+			if err != nil {
+				return nil, err
+			}
+			if bytes.Compare(tableMax, minKey) < 0 || bytes.Compare(tableMin, maxKey) > 0 {
 				continue
 			}
-			if string(recordMatrix[i][cursor].Key) < nextKey {
-				nextKey = string(recordMatrix[i][cursor].Key)
+			reader, err := c.tables.Get(dir)
+			if err != nil {
+				return nil, err
 			}
+			readers = append(readers, reader)
 		}
-		// Ovo je moguće samo ako preskočimo sve indekse - sve zapise smo prošli
-		if nextKey == "\xff" {
-			break
+	}
+	return readers, nil
+}
+
+// inputRange vraća opseg ključeva koje kompakcija može dodirnuti: ključeve ulaznih tabela
+// i opsege njihovih brisanja opsega
+func (c *compactor) inputRange(dirs []string) ([]byte, []byte, error) {
+	var minKey, maxKey []byte
+	extend := func(lo, hi []byte) {
+		if minKey == nil || bytes.Compare(lo, minKey) < 0 {
+			minKey = lo
+		}
+		if maxKey == nil || bytes.Compare(hi, maxKey) > 0 {
+			maxKey = hi
+		}
+	}
+	for _, dir := range dirs {
+		reader, err := c.tables.Get(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		extend(reader.Summary.MinKey, reader.Summary.MaxKey)
+		for _, rt := range reader.RangeTombstones {
+			extend(rt.Start, rt.End)
 		}
+	}
+	return minKey, maxKey, nil
+}
+
+// Compaction spaja ulazne tabele k-way merge-om preko SSTable cursora.
+// Zapisi se čitaju blok po blok i odmah upisuju u izlaznu tabelu, pa memorija ne zavisi od
+// veličine nivoa. Ako je maxTableSize > 0, izlaz se deli na više tabela te veličine data segmenta.
+// Tombstone-ovi se brišu samo ako nijedna tabela iz older ne može sadržati stariju verziju ključa.
+// Zapisi obuhvaćeni novijim brisanjem opsega iz ulaznih tabela se izbacuju, a samo brisanje
+// opsega se prenosi u prvu izlaznu tabelu dok god neka tabela iz older seče njegov opseg.
+// Vraća foldere svih kreiranih tabela.
+func Compaction(tables []*SSTable, older []*TableReader, blockSize int, bm *blockmanager.BlockManager,
+	dir string, step int, single bool, lsm byte, compress bool, dict *Dictionary, dictPath string,
+	maxTableSize int64, filter FilterPolicy) ([]string, error) {
+
+	guard := newTombstoneGuard(older)
+
+	h := &mergeHeap{}
+	var rangeTombstones, kept []RangeTombstone
+	for i := range tables {
+		sum, err := ReadSummaryFromTable(tables[i], bm, blockSize)
+		if err != nil {
+			return nil, err
+		}
+		rts, err := ReadRangeTombstonesFromTable(tables[i], bm, blockSize)
+		if err != nil {
+			return nil, err
+		}
+		rangeTombstones = append(rangeTombstones, rts...)
+		for _, rt := range rts {
+			if guard.overlapsOlder(rt) {
+				kept = append(kept, rt)
+			}
+		}
+		c, err := newTableCursor(bm, tables[i], "", string(sum.MaxKey), blockSize, compress, dict)
+		if err != nil {
+			return nil, err
+		}
+		if c.Seek("") {
+			heap.Push(h, &mergeItem{cursor: &c, order: i})
+		}
+	}
+
+	newDirs := make([]string, 0)
+	var w *SSTableWriter
+	for h.Len() > 0 {
+		// Skidamo sve verzije najmanjeg ključa i zadržavamo onu sa najnovijim timestampom
+		key := (*h)[0].cursor.Key()
 		var nextRecord *Record = nil
-		for i, cursor := range cursors {
-			if cursor == len(recordMatrix[i]) {
-				continue
+		for h.Len() > 0 && (*h)[0].cursor.Key() == key {
+			item := heap.Pop(h).(*mergeItem)
+			rec := item.cursor.Record()
+			if nextRecord == nil || binary.LittleEndian.Uint64(nextRecord.Timestamp[:8]) < binary.LittleEndian.Uint64(rec.Timestamp[:8]) {
+				nextRecord = rec
 			}
-			if nextKey == string(recordMatrix[i][cursor].Key) {
-				if nextRecord == nil {
-					nextRecord = recordMatrix[i][cursor]
-					// Ako imaju isti ključ - poredimo timestampove
-				} else if binary.LittleEndian.Uint64(nextRecord.Timestamp[:8]) < binary.LittleEndian.Uint64(recordMatrix[i][cursor].Timestamp[:8]) {
-					nextRecord = recordMatrix[i][cursor]
-				}
-				cursors[i]++
+			if item.cursor.Next() {
+				heap.Push(h, item)
 			}
 		}
-		// Zapisi koji su obrisani se preskaču - fizičko brisanje
-		if nextRecord.Tombstone {
+		// Zapisi koji su obrisani se preskaču - fizičko brisanje, osim ako stariju verziju
+		// ključa i dalje može sadržati neka tabela van kompakcije
+		if nextRecord.Tombstone && !guard.mayContainOlder(nextRecord.Key) {
 			continue
 		}
-		sortedRecords = append(sortedRecords, *nextRecord)
+		// Zapis obrisan opsegom se izbacuje; starije verzije (ako ih ima) sakriva sačuvano brisanje opsega
+		if timestampValue(nextRecord.Timestamp) <= CoveringTimestamp(rangeTombstones, nextRecord.Key) {
+			continue
+		}
+		if w == nil {
+			var err error
+			w, err = NewSSTableWriter(dir, step, bm, blockSize, lsm, single, compress, dict, dictPath, filter)
+			if err != nil {
+				return nil, err
+			}
+			w.AddRangeTombstones(kept)
+			kept = nil
+		}
+		if err := w.Add(*nextRecord); err != nil {
+			w.Abort()
+			return nil, err
+		}
+		// Izlazna tabela je dostigla ciljnu veličinu - zatvaramo je i počinjemo novu
+		if maxTableSize > 0 && w.DataSize() >= maxTableSize {
+			_, sstDir, err := w.Finish()
+			if err != nil {
+				return nil, err
+			}
+			newDirs = append(newDirs, sstDir)
+			w = nil
+		}
 	}
-	compacted, sstDir, err := CreateSSTable(sortedRecords, dir, step, bm, blockSize, lsm, single, compress, dict, dictPath)
-	if err != nil {
-		return nil, "", err
+	// Tabela ne može biti bez zapisa, pa brisanje opsega bez izlaznih zapisa prati
+	// tombstone njegovog početnog ključa (koji ionako briše)
+	if w == nil && len(kept) > 0 {
+		var err error
+		w, err = NewSSTableWriter(dir, step, bm, blockSize, lsm, single, compress, dict, dictPath, filter)
+		if err != nil {
+			return nil, err
+		}
+		w.AddRangeTombstones(kept)
+		if err := w.Add(Record{Key: kept[0].Start, Tombstone: true, Timestamp: kept[0].Timestamp}); err != nil {
+			w.Abort()
+			return nil, err
+		}
+	}
+	if w != nil {
+		_, sstDir, err := w.Finish()
+		if err != nil {
+			return nil, err
+		}
+		newDirs = append(newDirs, sstDir)
 	}
-	return compacted, sstDir, nil
+	return newDirs, nil
 }
 
 func CheckLSMLevels(bm *blockmanager.BlockManager, dirPath string, blockSize int) (map[byte][]string, error) {
@@ -196,46 +433,6 @@ func CheckLSMLevels(bm *blockmanager.BlockManager, dirPath string, blockSize int
 	return levelsMap, nil
 }
 
-func SizeTieredCompaction(bm *blockmanager.BlockManager, lsm *map[byte][]string, dirPath string,
-	maxInLevel int, blockSize int, step int, single bool, compression bool, dict *Dictionary, dictPath string) error {
-	loop := true
-	for loop {
-		loop = false
-		for k, level := range *lsm {
-			if len(level) > maxInLevel {
-				tables := make([]*SSTable, 0)
-				loop = true
-				for _, subdirPath := range level {
-					table, err := ReadTableFromDir(subdirPath)
-					if err != nil {
-						return nil
-					}
-					tables = append(tables, table)
-				}
-				_, sstDir, err := Compaction(tables, blockSize, bm, dirPath, step, single, k+1, compression, dict, dictPath)
-				if err != nil {
-					return err
-				}
-				// Brisanje SSTabela koje su kompaktovane
-				for _, path := range level {
-					err := os.RemoveAll(path)
-					if err != nil {
-						return err
-					}
-				}
-				// Apdejt LSM stabla
-				(*lsm)[k] = make([]string, 0)
-				_, ok := (*lsm)[k+1]
-				if !ok {
-					(*lsm)[k+1] = make([]string, 0)
-				}
-				(*lsm)[k+1] = append((*lsm)[k+1], sstDir)
-			}
-		}
-	}
-	return nil
-}
-
 func moveToLowerLevel(levelDir string, bm *blockmanager.BlockManager, blockSize int, lvl byte) error {
 	files, err := os.ReadDir(levelDir)
 	if err != nil {
@@ -252,8 +449,7 @@ func moveToLowerLevel(levelDir string, bm *blockmanager.BlockManager, blockSize
 			return nil
 		}
 		summaryBlock[offsets[2]%int64(blockSize)] = lvl
-		bm.Block_idx = int(offsets[2]) / blockSize
-		err = bm.WriteBlock(filePath, summaryBlock)
+		err = bm.WriteBlockAt(filePath, int(offsets[2])/blockSize, summaryBlock)
 		if err != nil {
 			return err
 		}
@@ -266,8 +462,7 @@ func moveToLowerLevel(levelDir string, bm *blockmanager.BlockManager, blockSize
 					return err
 				}
 				summaryBlock[0] = lvl
-				bm.Block_idx = 0
-				err = bm.WriteBlock(summaryPath, summaryBlock)
+				err = bm.WriteBlockAt(summaryPath, 0, summaryBlock)
 				if err != nil {
 					return err
 				}
@@ -276,102 +471,3 @@ func moveToLowerLevel(levelDir string, bm *blockmanager.BlockManager, blockSize
 	}
 	return nil
 }
-
-func LeveledCompaction(bm *blockmanager.BlockManager, lsm *map[byte][]string, dirPath string,
-	maxInLevel int, blockSize int, step int, single bool, compression bool, dict *Dictionary, dictPath string) error {
-	loop := true
-	for loop {
-		loop = false
-		for k, level := range *lsm {
-			leveledMax := maxInLevel
-			for i := byte(0); i < k; i++ {
-				leveledMax *= 10
-			}
-			if len(level) > leveledMax {
-				tables := make([]*SSTable, 0)
-				loop = true
-				nextLevel, ok := (*lsm)[k+1]
-				if ok {
-					// Kompakcija ukoliko na sledećem nivou ima tabeli
-					compactedDirs := make([]string, 0)
-					compactedDirs = append(compactedDirs, level[0])
-					uppersst, err := ReadTableFromDir(level[0])
-					if err != nil {
-						return err
-					}
-					(*lsm)[k] = (*lsm)[k][1:]
-					tables = append(tables, uppersst)
-					upperSummary, err := ReadSummaryFromTable(uppersst, bm, blockSize)
-					minStr := string(upperSummary.MinKey)
-					maxStr := string(upperSummary.MaxKey)
-					if err != nil {
-						return err
-					}
-					for _, lowerDir := range nextLevel {
-						lowersst, err := ReadTableFromDir(lowerDir)
-						if err != nil {
-							return err
-						}
-						lowerSummary, err := ReadSummaryFromTable(lowersst, bm, blockSize)
-						if err != nil {
-							return err
-						}
-						// Opsezi se ne preklapaju - preskačemo
-						if minStr > string(lowerSummary.MaxKey) || maxStr < string(lowerSummary.MinKey) {
-							continue
-							// Opseg gornje tabele je unutar druge - kompaktujemo samo njih dve
-						} else if string(lowerSummary.MaxKey) > maxStr && string(lowerSummary.MinKey) < minStr {
-							tables = append(tables, lowersst)
-							compactedDirs = append(compactedDirs, lowerDir)
-							break
-						} else {
-							tables = append(tables, lowersst)
-							compactedDirs = append(compactedDirs, lowerDir)
-						}
-					}
-					// Nijedan opseg se ne poklapa - pomeramo SSTabelu na sledeći nivo
-					if len(compactedDirs) == 1 {
-						err := moveToLowerLevel(compactedDirs[0], bm, blockSize, k+1)
-						if err != nil {
-							return nil
-						}
-						(*lsm)[k+1] = append((*lsm)[k+1], compactedDirs[0])
-						// Opsezi se poklapaju - kompaktujemo sve pohvatane table
-					} else {
-						_, newPath, err := Compaction(tables, blockSize, bm, dirPath, step, single, k+1, compression, dict, dictPath)
-						if err != nil {
-							return err
-						}
-						// Uklanjamo sve kompaktovane tabele i izbacujemo ih iz LSM stabla
-						for _, p := range compactedDirs {
-							err := os.RemoveAll(p)
-							if err != nil {
-								return nil
-							}
-						}
-						for _, p := range compactedDirs[1:] {
-							for j, oldPath := range (*lsm)[k+1] {
-								if p == oldPath {
-									(*lsm)[k+1] = slices.Delete((*lsm)[k+1], j, j+1)
-									break
-								}
-							}
-						}
-						(*lsm)[k+1] = append((*lsm)[k+1], newPath)
-					}
-				} else {
-					// Ako nema, prebacujemo jednu tabelu u sledeći nivo
-					err := moveToLowerLevel(level[0], bm, blockSize, k+1)
-					if err != nil {
-						return err
-					}
-					// Dodajemo novi nivo LSM stablu a iz starog izbacujemo nulti element
-					(*lsm)[k+1] = make([]string, 0)
-					(*lsm)[k+1] = append((*lsm)[k+1], level[0])
-					(*lsm)[k] = (*lsm)[k][1:]
-				}
-			}
-		}
-	}
-	return nil
-}
@@ -0,0 +1,257 @@
+package sstable
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"projekat/config"
+	"projekat/structs/blockmanager"
+)
+
+const testBlockSize = 512
+
+// testLSM je LSM stablo u privremenom folderu
+type testLSM struct {
+	t      *testing.T
+	dir    string
+	single bool
+	bm     *blockmanager.BlockManager
+	dict   *Dictionary
+	tables *TableCache
+	levels map[byte][]string
+}
+
+func newTestLSM(t *testing.T, single bool) *testLSM {
+	bm := blockmanager.NewBlockManager(testBlockSize, 64)
+	return &testLSM{t: t, dir: t.TempDir(), single: single, bm: bm, dict: NewDictionary(),
+		tables: NewTableCache(16, bm, testBlockSize, nil), levels: make(map[byte][]string)}
+}
+
+// ts pravi timestamp koji se poredi po prvih 8 bajtova, kao u ostatku sistema
+func ts(i uint64) [16]byte {
+	var t [16]byte
+	binary.LittleEndian.PutUint64(t[:8], i)
+	return t
+}
+
+func put(key, value string, at uint64) Record {
+	return Record{Key: []byte(key), Value: []byte(value), ValueSize: uint64(len(value)), Timestamp: ts(at)}
+}
+
+func del(key string, at uint64) Record {
+	return Record{Key: []byte(key), Tombstone: true, Timestamp: ts(at)}
+}
+
+func testKey(i int) string {
+	return fmt.Sprintf("k%03d", i)
+}
+
+// add upisuje tabelu na dati nivo kao najnoviju tabelu tog nivoa (zapisi moraju biti sortirani)
+func (l *testLSM) add(level byte, records []Record, rts []RangeTombstone) string {
+	_, dir, err := CreateSSTable(records, rts, l.dir, 4, l.bm, testBlockSize, level, l.single, false, l.dict,
+		filepath.Join(l.dir, "dict.db"), FilterPolicy{LevelFPRates: []float64{0.01}})
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	l.levels[level] = append(l.levels[level], dir)
+	return dir
+}
+
+// strategy pravi strategiju kompakcije zadatog algoritma nad LSM stablom
+func (l *testLSM) strategy(algorithm string, maxInLevel int) CompactionStrategy {
+	cfg := config.Config{BlockSize: testBlockSize, SummaryStep: 4, SSTableSingleFile: l.single,
+		CompactionAlgorithm: algorithm, MaxCountInLevel: maxInLevel}
+	strategy, err := NewCompactionStrategy(cfg, l.bm, l.dir, l.dict, filepath.Join(l.dir, "dict.db"), l.tables)
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	return strategy
+}
+
+// compact izvršava kompakcije zadatog algoritma dok nivoi ne budu u granicama maxInLevel
+func (l *testLSM) compact(algorithm string, maxInLevel int) error {
+	return l.strategy(algorithm, maxInLevel).MaybeCompact(&l.levels)
+}
+
+// each prolazi kroz sve zapise svih tabela
+func (l *testLSM) each(fn func(rec *Record)) {
+	for _, dirs := range l.levels {
+		for _, dir := range dirs {
+			c, err := NewCursor(l.bm, dir, "", "\xff", testBlockSize, false, l.dict, nil)
+			if err != nil {
+				l.t.Fatal(err)
+			}
+			for ok := c.Seek(""); ok; ok = c.Next() {
+				fn(c.Record())
+			}
+			c.Close()
+		}
+	}
+}
+
+// rangeTombstones vraća brisanja opsega iz svih tabela LSM stabla
+func (l *testLSM) rangeTombstones() []RangeTombstone {
+	rts := make([]RangeTombstone, 0)
+	for _, dirs := range l.levels {
+		for _, dir := range dirs {
+			reader, err := l.tables.Get(dir)
+			if err != nil {
+				l.t.Fatal(err)
+			}
+			rts = append(rts, reader.RangeTombstones...)
+		}
+	}
+	return rts
+}
+
+// get traži ključ kao čitanje sa diska: nivoi od 0, na nivou od najnovije tabele, prvi pogodak
+// je rezultat. Vraća vrednost i da li je ključ živ.
+func (l *testLSM) get(key string) (string, bool) {
+	rts := l.rangeTombstones()
+	for _, level := range sortedLevels(l.levels) {
+		dirs := l.levels[level]
+		for i := len(dirs) - 1; i >= 0; i-- {
+			reader, err := l.tables.Get(dirs[i])
+			if err != nil {
+				l.t.Fatal(err)
+			}
+			rec, found, err := reader.Search([]byte(key), l.bm, testBlockSize, false, l.dict)
+			if err != nil {
+				l.t.Fatal(err)
+			}
+			if found {
+				rec = ApplyRangeTombstones(rec, rts)
+				if rec.Tombstone {
+					return "", false
+				}
+				return string(rec.Value), true
+			}
+		}
+	}
+	return "", false
+}
+
+// tombstones vraća ključeve svih tombstone-ova i početke brisanja opsega fizički zapisanih
+// u tabelama LSM stabla
+func (l *testLSM) tombstones() []string {
+	keys := make([]string, 0)
+	for _, rt := range l.rangeTombstones() {
+		keys = append(keys, string(rt.Start))
+	}
+	l.each(func(rec *Record) {
+		if rec.Tombstone {
+			keys = append(keys, string(rec.Key))
+		}
+	})
+	return keys
+}
+
+// check proverava da su obrisani ključevi nevidljivi, a ostali imaju očekivanu vrednost
+func (l *testLSM) check(stage string, want map[string]string, deleted []string) {
+	for key, value := range want {
+		if got, live := l.get(key); !live || got != value {
+			l.t.Fatalf("%s: %s = %q (živ %v), očekivano %q", stage, key, got, live, value)
+		}
+	}
+	for _, key := range deleted {
+		if value, live := l.get(key); live {
+			l.t.Fatalf("%s: obrisan ključ %s je ponovo vidljiv (%q)", stage, key, value)
+		}
+	}
+}
+
+// Pri size-tiered kompakciji tombstone-i i brisanje opsega stižu na nivo 1 dok stare tabele na tom
+// nivou i dalje sadrže ključeve, pa se smeju obrisati tek kada se nivo 1 spusti i spoji sa njima
+func TestDeleteSurvivesSizeTieredCompaction(t *testing.T) {
+	for _, single := range []bool{false, true} {
+		l := newTestLSM(t, single)
+		want := make(map[string]string)
+		old := make([]Record, 0)
+		for i := 0; i < 50; i++ {
+			old = append(old, put(testKey(i), "staro", 1))
+			want[testKey(i)] = "staro"
+			if i == 15 {
+				l.add(1, old, nil)
+				old = make([]Record, 0)
+			}
+		}
+		l.add(1, old, nil)
+
+		deleted := []string{testKey(5), testKey(6)}
+		for i := 10; i < 40; i++ {
+			deleted = append(deleted, testKey(i))
+		}
+		l.add(0, []Record{del(testKey(5), 10), del(testKey(6), 10), put(testKey(7), "novo", 10)}, nil)
+		l.add(0, []Record{put("k041", "novo", 11)},
+			[]RangeTombstone{{Start: []byte(testKey(10)), End: []byte(testKey(40)), Timestamp: ts(11)}})
+		l.add(0, []Record{put("k008x", "novo", 12)}, nil)
+		l.add(0, []Record{put("k009x", "novo", 13)}, nil)
+		want[testKey(7)], want["k008x"], want["k009x"], want["k041"] = "novo", "novo", "novo", "novo"
+		for _, key := range deleted {
+			delete(want, key)
+		}
+
+		if err := l.compact("SizeTiered", 3); err != nil {
+			t.Fatal(err)
+		}
+		if len(l.levels[0]) != 0 || len(l.levels[1]) != 3 {
+			t.Fatalf("single=%v: očekivane tri tabele na nivou 1, dobijeno %v", single, l.levels)
+		}
+		l.check(fmt.Sprintf("single=%v, nivo 0", single), want, deleted)
+		if left := l.tombstones(); len(left) != 3 {
+			t.Fatalf("single=%v: tombstone-i %v, a stare tabele na nivou 1 sadrže ključeve", single, left)
+		}
+
+		// Ceo nivo 1 se spaja na nivou 2 - starijih verzija više nema
+		if err := l.compact("SizeTiered", 2); err != nil {
+			t.Fatal(err)
+		}
+		l.check(fmt.Sprintf("single=%v, nivo 1", single), want, deleted)
+		if left := l.tombstones(); len(left) != 0 {
+			t.Fatalf("single=%v: tombstone-i nisu obrisani na najnižem nivou: %v", single, left)
+		}
+	}
+}
+
+// Pri leveled kompakciji tombstone ostaje dok stariju verziju drži tabela na dubljem nivou, a
+// briše se čim je stara verzija spojena, iako na dubljem nivou postoje tabele drugog opsega.
+// Brisanje opsega seže van ključeva svoje tabele, do stare tabele koju kompakcija ne spaja.
+func TestDeleteSurvivesLeveledCompaction(t *testing.T) {
+	for _, single := range []bool{false, true} {
+		l := newTestLSM(t, single)
+		want := make(map[string]string)
+		deep, upper := make([]Record, 0), make([]Record, 0)
+		for i := 0; i < 50; i++ {
+			if i < 10 {
+				deep = append(deep, put(testKey(i), "staro", 1))
+			} else {
+				upper = append(upper, put(testKey(i), "staro", 2))
+			}
+			want[testKey(i)] = "staro"
+		}
+		l.add(2, deep, nil)
+		l.add(2, []Record{put("z000", "staro", 1), put("z100", "staro", 1)}, nil)
+		l.add(1, upper, nil)
+		l.add(0, []Record{del(testKey(5), 10), del(testKey(20), 10)}, nil)
+		l.add(0, []Record{put(testKey(30), "novo", 11)},
+			[]RangeTombstone{{Start: []byte("y"), End: []byte("z050"), Timestamp: ts(11)}})
+		deleted := []string{testKey(5), testKey(20), "z000"}
+		want[testKey(30)], want["z100"] = "novo", "staro"
+		for _, key := range deleted {
+			delete(want, key)
+		}
+
+		if err := l.compact("Leveled", 1); err != nil {
+			t.Fatal(err)
+		}
+		if len(l.levels[0]) > 1 {
+			t.Fatalf("single=%v: nivo 0 nije kompaktovan: %v", single, l.levels)
+		}
+		l.check(fmt.Sprintf("single=%v", single), want, deleted)
+		if left := l.tombstones(); len(left) != 2 || left[0] != "y" || left[1] != testKey(5) {
+			t.Fatalf("single=%v: tombstone-i %v, očekivani brisanje opsega i %s", single, left, testKey(5))
+		}
+	}
+}
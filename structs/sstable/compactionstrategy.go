@@ -0,0 +1,595 @@
+package sstable
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"projekat/config"
+	"projekat/structs/blockmanager"
+)
+
+// CompactionStrategy je zajednički interfejs za sve algoritme kompakcije.
+// MaybeCompact se poziva posle svakog flush-a i izvršava kompakcije sve dok
+// LSM stablo ne zadovolji uslove strategije.
+// CompactLevel, CompactRange i CompactAll pokreće korisnik ručno i iste su za sve strategije.
+type CompactionStrategy interface {
+	MaybeCompact(lsm *map[byte][]string) error
+	CompactLevel(lsm *map[byte][]string, level byte) error
+	CompactRange(lsm *map[byte][]string, from string, to string) error
+	CompactAll(lsm *map[byte][]string) error
+}
+
+// NewCompactionStrategy bira strategiju na osnovu CompactionAlgorithm iz konfiguracije
+func NewCompactionStrategy(cfg config.Config, bm *blockmanager.BlockManager, dirPath string,
+	dict *Dictionary, dictPath string, tables *TableCache) (CompactionStrategy, error) {
+	c := &compactor{
+		bm:           bm,
+		tables:       tables,
+		dirPath:      dirPath,
+		blockSize:    cfg.BlockSize,
+		step:         cfg.SummaryStep,
+		single:       cfg.SSTableSingleFile,
+		compression:  cfg.SSTableCompression,
+		dict:         dict,
+		dictPath:     dictPath,
+		maxTableSize: cfg.MaxSSTableSize,
+		filter:       NewFilterPolicy(cfg),
+	}
+	switch cfg.CompactionAlgorithm {
+	case "SizeTiered":
+		return &SizeTieredStrategy{compactor: c, maxInLevel: cfg.MaxCountInLevel}, nil
+	case "Leveled":
+		return &LeveledStrategy{
+			compactor:    c,
+			maxInLevel:   cfg.MaxCountInLevel,
+			baseBytes:    cfg.LevelBaseBytes,
+			multiplier:   max(int64(cfg.LevelSizeMultiplier), 2),
+			victimPolicy: cfg.CompactionVictimPolicy,
+			cursors:      make(map[byte][]byte),
+		}, nil
+	case "TimeWindow":
+		if cfg.TimeWindowSize <= 0 {
+			return nil, fmt.Errorf("TimeWindowSize mora biti pozitivan")
+		}
+		return &TimeWindowStrategy{
+			compactor:  c,
+			windowSize: time.Duration(cfg.TimeWindowSize) * time.Second,
+			maxInLevel: cfg.MaxCountInLevel,
+		}, nil
+	case "Hybrid":
+		return &HybridStrategy{compactor: c, tieredLevels: byte(cfg.HybridTieredLevels), maxInLevel: cfg.MaxCountInLevel}, nil
+	}
+	return nil, fmt.Errorf("nepoznat algoritam kompakcije: %s", cfg.CompactionAlgorithm)
+}
+
+// -------------------------------------------------------------------------------------------------------------------------------
+// Zajedničke operacije nad LSM stablom
+// -------------------------------------------------------------------------------------------------------------------------------
+
+// compactor sadrži parametre za upis novih tabela koje dele sve strategije
+type compactor struct {
+	bm           *blockmanager.BlockManager
+	tables       *TableCache
+	dirPath      string
+	blockSize    int
+	step         int
+	single       bool
+	compression  bool
+	dict         *Dictionary
+	dictPath     string
+	maxTableSize int64
+	filter       FilterPolicy
+}
+
+// sortedLevels vraća nivoe LSM stabla u rastućem redosledu
+func sortedLevels(lsm map[byte][]string) []byte {
+	levels := make([]byte, 0, len(lsm))
+	for level := range lsm {
+		levels = append(levels, level)
+	}
+	slices.Sort(levels)
+	return levels
+}
+
+// removeFromLSM izbacuje folder tabele iz nivoa na kom se nalazi
+func removeFromLSM(lsm *map[byte][]string, dir string) {
+	for level, dirs := range *lsm {
+		for i, p := range dirs {
+			if p == dir {
+				(*lsm)[level] = slices.Delete(dirs, i, i+1)
+				return
+			}
+		}
+	}
+}
+
+// mergeTables kompaktuje zadate tabele u targetLevel, briše ih sa diska i ažurira LSM stablo.
+// Ako je split true, izlaz se deli na tabele veličine MaxSSTableSize.
+func (c *compactor) mergeTables(lsm *map[byte][]string, dirs []string, targetLevel byte, split bool) error {
+	tables := make([]*SSTable, 0, len(dirs))
+	for _, dir := range dirs {
+		table, err := ReadTableFromDir(dir)
+		if err != nil {
+			return err
+		}
+		tables = append(tables, table)
+	}
+	// Starije verzije ključeva mogu postojati na ciljnom i svim nižim nivoima
+	minKey, maxKey, err := c.inputRange(dirs)
+	if err != nil {
+		return err
+	}
+	older, err := c.olderTables(*lsm, targetLevel, dirs, minKey, maxKey)
+	if err != nil {
+		return err
+	}
+	maxTableSize := int64(0)
+	if split {
+		maxTableSize = c.maxTableSize
+	}
+	newDirs, err := Compaction(tables, older, c.blockSize, c.bm, c.dirPath, c.step, c.single, targetLevel,
+		c.compression, c.dict, c.dictPath, maxTableSize, c.filter)
+	if err != nil {
+		return err
+	}
+	// Brisanje SSTabela koje su kompaktovane
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		c.tables.Evict(dir)
+		removeFromLSM(lsm, dir)
+	}
+	(*lsm)[targetLevel] = append((*lsm)[targetLevel], newDirs...)
+	return nil
+}
+
+// moveTable premešta tabelu na drugi nivo bez prepisivanja podataka
+func (c *compactor) moveTable(lsm *map[byte][]string, dir string, targetLevel byte) error {
+	if err := moveToLowerLevel(dir, c.bm, c.blockSize, targetLevel); err != nil {
+		return err
+	}
+	// Summary u kešu sadrži stari nivo
+	c.tables.Evict(dir)
+	removeFromLSM(lsm, dir)
+	(*lsm)[targetLevel] = append((*lsm)[targetLevel], dir)
+	return nil
+}
+
+// tableInfo opisuje tabelu za potrebe izbora kompakcije
+type tableInfo struct {
+	dir    string
+	minKey []byte
+	maxKey []byte
+	size   int64
+}
+
+// describeTables vraća opseg ključeva (iz TableCache-a) i veličinu na disku za svaku zadatu tabelu
+func (c *compactor) describeTables(dirs []string) ([]tableInfo, error) {
+	infos := make([]tableInfo, 0, len(dirs))
+	for _, dir := range dirs {
+		minKey, maxKey, err := c.tables.KeyRange(dir)
+		if err != nil {
+			return nil, err
+		}
+		size, err := tableSize(dir)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, tableInfo{dir: dir, minKey: minKey, maxKey: maxKey, size: size})
+	}
+	return infos, nil
+}
+
+// tableSize vraća ukupnu veličinu svih fajlova tabele
+func tableSize(dir string) (int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	size := int64(0)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
+
+// keyRange vraća najmanji i najveći ključ svih zadatih tabela
+func keyRange(infos []tableInfo) ([]byte, []byte) {
+	var minKey, maxKey []byte
+	for i, info := range infos {
+		if i == 0 || bytes.Compare(info.minKey, minKey) < 0 {
+			minKey = info.minKey
+		}
+		if i == 0 || bytes.Compare(info.maxKey, maxKey) > 0 {
+			maxKey = info.maxKey
+		}
+	}
+	return minKey, maxKey
+}
+
+// overlapping vraća tabele čiji se opseg ključeva preklapa sa [minKey, maxKey]
+func overlapping(infos []tableInfo, minKey, maxKey []byte) []tableInfo {
+	result := make([]tableInfo, 0)
+	for _, info := range infos {
+		// Opsezi se ne preklapaju - preskačemo
+		if bytes.Compare(minKey, info.maxKey) > 0 || bytes.Compare(maxKey, info.minKey) < 0 {
+			continue
+		}
+		result = append(result, info)
+	}
+	return result
+}
+
+// mergeIntoLeveled spušta tabele u nivo sa disjunktnim opsezima: spajaju se sa svim tabelama
+// ciljnog nivoa čiji se opseg preklapa, a ako takvih nema i tabela je jedna, samo se premešta
+func (c *compactor) mergeIntoLeveled(lsm *map[byte][]string, dirs []string, targetLevel byte) error {
+	return c.pushDown(lsm, dirs, targetLevel, true)
+}
+
+// pushDown spaja tabele sa tranzitivno preklapajućim tabelama ciljnog nivoa; allowMove dozvoljava
+// premeštanje bez prepisivanja kada nema preklapanja (ručna kompakcija uvek prepisuje podatke)
+func (c *compactor) pushDown(lsm *map[byte][]string, dirs []string, targetLevel byte, allowMove bool) error {
+	upper, err := c.describeTables(dirs)
+	if err != nil {
+		return err
+	}
+	target, err := c.describeTables((*lsm)[targetLevel])
+	if err != nil {
+		return err
+	}
+	minKey, maxKey := keyRange(upper)
+	lower := overlapping(target, minKey, maxKey)
+	// Na nivoima sa preklapajućim tabelama (size-tiered, time-window) izbor se širi dok se ne zatvori:
+	// izlaz se dodaje kao najnovija tabela nivoa, pa bi izostavljena tabela koja deli ključeve sa
+	// spojenim, a novija je od njih, posle kompakcije bila zaklonjena starijom verzijom
+	for len(lower) > 0 {
+		minKey, maxKey = keyRange(append(slices.Clone(upper), lower...))
+		expanded := overlapping(target, minKey, maxKey)
+		if len(expanded) == len(lower) {
+			break
+		}
+		lower = expanded
+	}
+	// Nijedan opseg se ne poklapa - pomeramo SSTabelu na sledeći nivo
+	if allowMove && len(lower) == 0 && len(dirs) == 1 {
+		return c.moveTable(lsm, dirs[0], targetLevel)
+	}
+	// Opsezi se poklapaju - kompaktujemo sve pohvatane tabele
+	inputs := slices.Clone(dirs)
+	for _, info := range lower {
+		inputs = append(inputs, info.dir)
+	}
+	return c.mergeTables(lsm, inputs, targetLevel, true)
+}
+
+// CompactLevel spaja sve tabele nivoa sa preklapajućim tabelama sledećeg nivoa
+func (c *compactor) CompactLevel(lsm *map[byte][]string, level byte) error {
+	dirs, ok := (*lsm)[level]
+	if !ok || len(dirs) == 0 {
+		return fmt.Errorf("nivo %d ne sadrži tabele", level)
+	}
+	return c.pushDown(lsm, slices.Clone(dirs), level+1, false)
+}
+
+// CompactRange spušta sve tabele koje sadrže ključeve iz [from, to] do najnižeg nivoa,
+// tako da na kraju u opsegu ostane samo jedna verzija svakog ključa, bez tombstone-ova
+func (c *compactor) CompactRange(lsm *map[byte][]string, from string, to string) error {
+	if from > to {
+		return fmt.Errorf("početak opsega je veći od kraja")
+	}
+	levels := sortedLevels(*lsm)
+	if len(levels) == 0 {
+		return nil
+	}
+	// Ako postoji samo nivo 0, podaci se spuštaju na nivo 1
+	bottom := max(levels[len(levels)-1], 1)
+	for _, k := range levels {
+		if k == bottom {
+			break
+		}
+		infos, err := c.describeTables((*lsm)[k])
+		if err != nil {
+			return err
+		}
+		selected := overlapping(infos, []byte(from), []byte(to))
+		if len(selected) == 0 {
+			continue
+		}
+		// Na nivoima sa preklapajućim tabelama izbor se širi dok ne obuhvati sve tabele
+		// koje dele ključeve sa izabranim, inače bi novija verzija mogla završiti ispod starije
+		for {
+			minKey, maxKey := keyRange(selected)
+			expanded := overlapping(infos, minKey, maxKey)
+			if len(expanded) == len(selected) {
+				break
+			}
+			selected = expanded
+		}
+		dirs := make([]string, 0, len(selected))
+		for _, info := range selected {
+			dirs = append(dirs, info.dir)
+		}
+		if err := c.pushDown(lsm, dirs, k+1, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CompactAll spušta sve podatke na najniži nivo
+func (c *compactor) CompactAll(lsm *map[byte][]string) error {
+	levels := sortedLevels(*lsm)
+	if len(levels) == 0 {
+		return nil
+	}
+	// Ako postoji samo nivo 0, podaci se spuštaju na nivo 1
+	bottom := max(levels[len(levels)-1], 1)
+	for _, k := range levels {
+		if k == bottom {
+			break
+		}
+		if len((*lsm)[k]) == 0 {
+			continue
+		}
+		if err := c.CompactLevel(lsm, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableCreationTime vraća vreme kreiranja tabele zapisano u nazivu njenog foldera
+func tableCreationTime(dir string) time.Time {
+	name := strings.TrimSuffix(filepath.Base(dir), "-sstable")
+	ns, err := strconv.ParseInt(name, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// -------------------------------------------------------------------------------------------------------------------------------
+// Size-tiered
+// -------------------------------------------------------------------------------------------------------------------------------
+
+// SizeTieredStrategy kompaktuje ceo nivo u jednu tabelu narednog nivoa kada broj tabela pređe maxInLevel
+type SizeTieredStrategy struct {
+	*compactor
+	maxInLevel int
+}
+
+func (s *SizeTieredStrategy) MaybeCompact(lsm *map[byte][]string) error {
+	loop := true
+	for loop {
+		loop = false
+		for _, k := range sortedLevels(*lsm) {
+			level := (*lsm)[k]
+			if len(level) > s.maxInLevel {
+				loop = true
+				// Size-tiered kompakcija uvek pravi jednu tabelu po nivou
+				if err := s.mergeTables(lsm, slices.Clone(level), k+1, false); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// -------------------------------------------------------------------------------------------------------------------------------
+// Leveled
+// -------------------------------------------------------------------------------------------------------------------------------
+
+// LeveledStrategy drži tabele na nivoima >= 1 sa disjunktnim opsezima.
+// Nivo 0 se posmatra posebno: njegove tabele se preklapaju, pa se kada ih ima više od maxInLevel
+// sve zajedno spajaju sa preklapajućim tabelama nivoa 1.
+// Kapacitet nivoa k >= 1 je baseBytes * multiplier^(k-1) bajtova; ako baseBytes nije zadat,
+// koristi se stari kapacitet od maxInLevel * 10^k tabela.
+type LeveledStrategy struct {
+	*compactor
+	maxInLevel   int
+	baseBytes    int64
+	multiplier   int64
+	victimPolicy string
+	// Poslednji kompaktovani ključ po nivou za round-robin izbor tabele
+	cursors map[byte][]byte
+}
+
+func (s *LeveledStrategy) MaybeCompact(lsm *map[byte][]string) error {
+	loop := true
+	for loop {
+		loop = false
+		for _, k := range sortedLevels(*lsm) {
+			level := (*lsm)[k]
+			if k == 0 {
+				if len(level) > s.maxInLevel {
+					loop = true
+					if err := s.mergeIntoLeveled(lsm, slices.Clone(level), 1); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			infos, err := s.describeTables(level)
+			if err != nil {
+				return err
+			}
+			if !s.overCapacity(k, infos) {
+				continue
+			}
+			loop = true
+			victim, err := s.pickVictim(lsm, k, infos)
+			if err != nil {
+				return err
+			}
+			if err := s.mergeIntoLeveled(lsm, []string{victim}, k+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// overCapacity proverava da li je nivo k prešao ciljnu veličinu
+func (s *LeveledStrategy) overCapacity(k byte, infos []tableInfo) bool {
+	if s.baseBytes <= 0 {
+		leveledMax := s.maxInLevel
+		for i := byte(0); i < k; i++ {
+			leveledMax *= 10
+		}
+		return len(infos) > leveledMax
+	}
+	target := s.baseBytes
+	for i := byte(1); i < k; i++ {
+		target *= s.multiplier
+	}
+	total := int64(0)
+	for _, info := range infos {
+		total += info.size
+	}
+	return total > target
+}
+
+// pickVictim bira tabelu sa nivoa k koja se spušta na nivo k+1
+func (s *LeveledStrategy) pickVictim(lsm *map[byte][]string, k byte, infos []tableInfo) (string, error) {
+	if s.victimPolicy == "RoundRobin" {
+		// Prva tabela posle poslednjeg kompaktovanog ključa, uz povratak na početak nivoa
+		slices.SortFunc(infos, func(a, b tableInfo) int { return bytes.Compare(a.minKey, b.minKey) })
+		victim := infos[0]
+		for _, info := range infos {
+			if bytes.Compare(info.minKey, s.cursors[k]) > 0 {
+				victim = info
+				break
+			}
+		}
+		s.cursors[k] = victim.maxKey
+		return victim.dir, nil
+	}
+	// Tabela sa najmanjim odnosom preklapanja sa sledećim nivoom - najmanje prepisivanja po spuštenom bajtu
+	next, err := s.describeTables((*lsm)[k+1])
+	if err != nil {
+		return "", err
+	}
+	victim := infos[0].dir
+	bestScore := -1.0
+	for _, info := range infos {
+		overlap := int64(0)
+		for _, lower := range overlapping(next, info.minKey, info.maxKey) {
+			overlap += lower.size
+		}
+		score := float64(overlap) / float64(max(info.size, 1))
+		if bestScore < 0 || score < bestScore {
+			victim = info.dir
+			bestScore = score
+		}
+	}
+	return victim, nil
+}
+
+// -------------------------------------------------------------------------------------------------------------------------------
+// Time-window
+// -------------------------------------------------------------------------------------------------------------------------------
+
+// TimeWindowStrategy grupiše tabele po vremenskim prozorima (pogodno za TTL i vremenske serije).
+// Tabele tekućeg prozora ostaju na nivou 0 i kompaktuju se size-tiered kada ih ima više od maxInLevel,
+// a kada se prozor zatvori sve njegove tabele se spajaju u jednu tabelu na nivou 1.
+// Tabele različitih prozora se nikada ne spajaju, pa se stari prozori mogu brisati u celosti.
+type TimeWindowStrategy struct {
+	*compactor
+	windowSize time.Duration
+	maxInLevel int
+}
+
+func (s *TimeWindowStrategy) window(dir string) int64 {
+	return tableCreationTime(dir).UnixNano() / int64(s.windowSize)
+}
+
+func (s *TimeWindowStrategy) MaybeCompact(lsm *map[byte][]string) error {
+	current := time.Now().UnixNano() / int64(s.windowSize)
+	windows := make(map[int64][]string)
+	order := make([]int64, 0)
+	for _, dir := range (*lsm)[0] {
+		w := s.window(dir)
+		if _, ok := windows[w]; !ok {
+			order = append(order, w)
+		}
+		windows[w] = append(windows[w], dir)
+	}
+	slices.Sort(order)
+	for _, w := range order {
+		dirs := windows[w]
+		if w < current {
+			// Zatvoren prozor se uvek prepisuje (i kada ima jednu tabelu), tako da redosled
+			// naziva tabela na nivou 1 prati redosled prozora i posle ponovnog pokretanja
+			if err := s.mergeTables(lsm, dirs, 1, false); err != nil {
+				return err
+			}
+		} else if len(dirs) > s.maxInLevel {
+			// Tekući prozor - size-tiered kompakcija unutar nivoa 0
+			if err := s.mergeTables(lsm, dirs, 0, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// -------------------------------------------------------------------------------------------------------------------------------
+// Hybrid (tiered pa leveled)
+// -------------------------------------------------------------------------------------------------------------------------------
+
+// HybridStrategy koristi size-tiered kompakciju na prvih tieredLevels nivoa (jeftin upis za sveže podatke)
+// i leveled kompakciju na dubljim nivoima (malo tabela po ključu za čitanje).
+// Kapacitet leveled nivoa k je maxInLevel * 10^(k - tieredLevels).
+type HybridStrategy struct {
+	*compactor
+	tieredLevels byte
+	maxInLevel   int
+}
+
+func (s *HybridStrategy) MaybeCompact(lsm *map[byte][]string) error {
+	loop := true
+	for loop {
+		loop = false
+		for _, k := range sortedLevels(*lsm) {
+			level := (*lsm)[k]
+			if k < s.tieredLevels {
+				if len(level) <= s.maxInLevel {
+					continue
+				}
+				loop = true
+				if k+1 < s.tieredLevels {
+					if err := s.mergeTables(lsm, slices.Clone(level), k+1, false); err != nil {
+						return err
+					}
+				} else if err := s.mergeIntoLeveled(lsm, slices.Clone(level), k+1); err != nil {
+					// Prelazak iz tiered u leveled deo - ceo nivo se spaja sa preklapajućim tabelama
+					return err
+				}
+				continue
+			}
+			leveledMax := s.maxInLevel
+			for i := s.tieredLevels; i < k; i++ {
+				leveledMax *= 10
+			}
+			if len(level) > leveledMax {
+				loop = true
+				if err := s.mergeIntoLeveled(lsm, []string{level[0]}, k+1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package sstable
+
+import "testing"
+
+// Pri spuštanju na nivo sa preklapajućim tabelama mora se spojiti i tabela koja ne seče ulaz
+// direktno, već preko druge spojene tabele; inače bi izlaz, dodat kao najnoviji, zaklonio
+// noviju verziju ključa u izostavljenoj tabeli
+func TestPushDownMergesTransitiveOverlap(t *testing.T) {
+	for _, single := range []bool{false, true} {
+		l := newTestLSM(t, single)
+		l.add(1, []Record{put("a", "1", 1), put("k", "staro", 1), put("z", "1", 1)}, nil)
+		l.add(1, []Record{put("k", "novo", 5)}, nil)
+		l.add(0, []Record{put("b", "2", 6)}, nil)
+
+		if err := l.strategy("SizeTiered", 3).CompactLevel(&l.levels, 0); err != nil {
+			t.Fatal(err)
+		}
+		if len(l.levels[0]) != 0 || len(l.levels[1]) != 1 {
+			t.Fatalf("single=%v: očekivana jedna tabela na nivou 1, dobijeno %v", single, l.levels)
+		}
+		for key, want := range map[string]string{"a": "1", "b": "2", "k": "novo", "z": "1"} {
+			if got, ok := l.get(key); !ok || got != want {
+				t.Fatalf("single=%v: %s = %q (živ %v), očekivano %q", single, key, got, ok, want)
+			}
+		}
+	}
+}
@@ -7,9 +7,13 @@ import (
 	"io"
 	"os"
 	"projekat/structs/blockmanager"
+	"sync"
 )
 
+// Dictionary je globalni rečnik kompresije ključeva; bezbedan je za istovremeni pristup, jer
+// flusher dodaje ključeve nove tabele dok komande čitaju postojeće
 type Dictionary struct {
+	mu             sync.Mutex
 	strToID        map[string]uint64
 	idToStr        map[uint64]string
 	nextID         uint64
@@ -41,6 +45,8 @@ func NewDictionaryWithThreshold(threshold int) *Dictionary {
 
 // GetID vraća ID za ključ, kreira novi ako ne postoji i automatski čuva kad treba
 func (d *Dictionary) GetID(key string, bm *blockmanager.BlockManager, path string, blockSize int) uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	if id, ok := d.strToID[key]; ok {
 		return id
 	}
@@ -51,14 +57,23 @@ func (d *Dictionary) GetID(key string, bm *blockmanager.BlockManager, path strin
 	d.unsavedBuffer++
 
 	if d.unsavedBuffer >= d.flushThreshold {
-		_ = d.SaveToFile(path, bm, blockSize)
+		_ = d.save(path, bm, blockSize)
 		d.unsavedBuffer = 0
 	}
 	return id
 }
 
+// idOf vraća ID ključa koji je već u rečniku (0 ako ga nema)
+func (d *Dictionary) idOf(key string) uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.strToID[key]
+}
+
 // Lookup vraća originalni ključ za dati ID
 func (d *Dictionary) Lookup(id uint64) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	val, ok := d.idToStr[id]
 	if !ok {
 		return "", errors.New("key id not found")
@@ -68,6 +83,12 @@ func (d *Dictionary) Lookup(id uint64) (string, error) {
 
 // SaveToFile serijalizuje rečnik u datoteku koristeći BlockManager
 func (d *Dictionary) SaveToFile(path string, bm *blockmanager.BlockManager, blockSize int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.save(path, bm, blockSize)
+}
+
+func (d *Dictionary) save(path string, bm *blockmanager.BlockManager, blockSize int) error {
 	buf := &bytes.Buffer{}
 	tmp := make([]byte, binary.MaxVarintLen64)
 
@@ -85,6 +106,8 @@ func (d *Dictionary) SaveToFile(path string, bm *blockmanager.BlockManager, bloc
 
 // LoadFromFile učitava rečnik iz datoteke koristeći BlockManager
 func (d *Dictionary) LoadFromFile(path string, bm *blockmanager.BlockManager, blockSize int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	d.strToID = make(map[string]uint64)
 	d.idToStr = make(map[uint64]string)
 	d.nextID = 1
@@ -133,6 +156,8 @@ func (d *Dictionary) LoadFromFile(path string, bm *blockmanager.BlockManager, bl
 
 // ForceSaveToFile eksplicitno zapisuje ceo rečnik (npr. pri izlasku iz programa)
 func (d *Dictionary) ForceSaveToFile(path string, bm *blockmanager.BlockManager, blockSize int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	d.unsavedBuffer = 0
-	return d.SaveToFile(path, bm, blockSize)
+	return d.save(path, bm, blockSize)
 }
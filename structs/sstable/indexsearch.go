@@ -0,0 +1,212 @@
+package sstable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/bits"
+	"os"
+	"sort"
+
+	"projekat/structs/blockmanager"
+)
+
+// Niz offseta indexa počinje korakom summary-ja i brojem unosa, a zatim sledi offset
+// (od početka indexa) svakog unosa redom, po 8 bajtova. Unos summary-ja j pokazuje na
+// unos indexa j*step, pa se offseti dela indexa koji on pokriva čitaju direktno.
+const indexOffsetsHeaderSize = 16
+
+// indexLayout opisuje gde se u tabeli nalaze index i niz offseta njegovih unosa
+type indexLayout struct {
+	// Početak data segmenta (u tabeli sa više fajlova data je u posebnom fajlu)
+	dataStart int64
+	path      string
+	start     int64
+	length    int64
+	// Starije tabele nemaju niz offseta (offsetsPath je prazan)
+	offsetsPath  string
+	offsetsStart int64
+	step         int
+	count        int
+}
+
+// loadIndexLayout pronalazi index i niz offseta tabele
+func loadIndexLayout(sst *SSTable, bm *blockmanager.BlockManager, blockSize int) (indexLayout, error) {
+	var l indexLayout
+	if sst.SingleSSTable {
+		offsets, err := parseHeader(bm, sst.SingleFilePath, blockSize)
+		if err != nil {
+			return l, err
+		}
+		l.dataStart = offsets[sectionData]
+		l.path = sst.SingleFilePath
+		l.start = offsets[sectionIndex]
+		l.length = offsets[sectionIndex+1] - offsets[sectionIndex]
+		if start, _, ok := sectionBounds(offsets, sectionIndexOffsets); ok {
+			l.offsetsPath = sst.SingleFilePath
+			l.offsetsStart = start
+		}
+	} else {
+		info, err := os.Stat(sst.IndexFilePath)
+		if err != nil {
+			return l, err
+		}
+		l.path = sst.IndexFilePath
+		l.length = info.Size()
+		if sst.IndexOffsetsFilePath != "" {
+			if _, err := os.Stat(sst.IndexOffsetsFilePath); err == nil {
+				l.offsetsPath = sst.IndexOffsetsFilePath
+			}
+		}
+	}
+	if l.offsetsPath != "" {
+		head, err := readSegment(bm, l.offsetsPath, l.offsetsStart, indexOffsetsHeaderSize, blockSize)
+		if err != nil {
+			return l, err
+		}
+		l.step = int(binary.LittleEndian.Uint64(head[:8]))
+		l.count = int(binary.LittleEndian.Uint64(head[8:16]))
+	}
+	return l, nil
+}
+
+// findSummaryEntry binarnom pretragom vraća poslednji unos summary-ja čiji je ključ <= key
+// (odnosno prvi unos ako takav ne postoji)
+func findSummaryEntry(summary *Summary, key []byte) int {
+	j := sort.Search(len(summary.Entries), func(i int) bool {
+		return bytes.Compare(summary.Entries[i].Key, key) > 0
+	})
+	return max(j-1, 0)
+}
+
+// readBlock vraća deo indexa koji pokriva j-ti unos summary-ja. Kod tabela sa nizom offseta
+// veliki deo indexa se ne čita unapred: binarna pretraga čita samo offsete i unose koje
+// proverava, pa broj pročitanih blokova raste sa logaritmom koraka summary-ja. Deo koji staje
+// u onoliko blokova koliko pretraga ima koraka, kao i index starijih tabela, čita se ceo.
+func (l indexLayout) readBlock(bm *blockmanager.BlockManager, summary *Summary, j int, blockSize int) (indexBlock, error) {
+	if j >= len(summary.Entries) {
+		return indexBlock{}, nil
+	}
+	idxOff := int64(summary.Entries[j].Offset)
+	bound := l.length
+	if j+1 < len(summary.Entries) {
+		bound = int64(summary.Entries[j+1].Offset)
+	}
+	first := j * l.step
+	if l.offsetsPath != "" && l.step > 0 && first < l.count {
+		n := min(l.step, l.count-first)
+		blocks := (l.start+bound-1)/int64(blockSize) - (l.start+idxOff)/int64(blockSize) + 1
+		if blocks > int64(bits.Len(uint(n))) {
+			return indexBlock{
+				n:         n,
+				bm:        bm,
+				layout:    l,
+				first:     first,
+				blockSize: blockSize,
+				entries:   make(map[int]indexEntry),
+			}, nil
+		}
+	}
+	data, err := readSegment(bm, l.path, l.start+idxOff, int(bound-idxOff), blockSize)
+	if err != nil {
+		return indexBlock{}, err
+	}
+	offsets := walkIndexBlock(data)
+	return indexBlock{n: len(offsets), data: data, offsets: offsets}, nil
+}
+
+// walkIndexBlock računa offsete unosa prolaskom kroz blok (za tabele bez niza offseta)
+func walkIndexBlock(data []byte) []int64 {
+	var offsets []int64
+	pos := int64(0)
+	for pos+8 <= int64(len(data)) {
+		ksz := int64(binary.LittleEndian.Uint64(data[pos : pos+8]))
+		// Prazan ključ znači da je ostatak padding bloka
+		if ksz == 0 || pos+16+ksz > int64(len(data)) {
+			break
+		}
+		offsets = append(offsets, pos)
+		pos += 16 + ksz
+	}
+	return offsets
+}
+
+// indexBlock je deo indexa od n unosa u kome se unos na poziciji i čita bez parsiranja
+// prethodnih unosa
+type indexBlock struct {
+	n int
+	// Starije tabele: ceo deo indexa i offseti unosa u njemu
+	data    []byte
+	offsets []int64
+	// Tabele sa nizom offseta: offset i unos se čitaju sa diska kada zatrebaju i pamte se
+	bm        *blockmanager.BlockManager
+	layout    indexLayout
+	first     int
+	blockSize int
+	entries   map[int]indexEntry
+}
+
+// indexEntry je unos indexa: ključ i offset njegovog zapisa u data segmentu
+type indexEntry struct {
+	key        []byte
+	dataOffset uint64
+}
+
+// entry vraća i-ti unos dela indexa
+func (b indexBlock) entry(i int) (indexEntry, error) {
+	if b.entries == nil {
+		off := b.offsets[i]
+		ksz := int64(binary.LittleEndian.Uint64(b.data[off : off+8]))
+		return indexEntry{
+			key:        b.data[off+8 : off+8+ksz],
+			dataOffset: binary.LittleEndian.Uint64(b.data[off+8+ksz : off+16+ksz]),
+		}, nil
+	}
+	if e, ok := b.entries[i]; ok {
+		return e, nil
+	}
+	l := b.layout
+	raw, err := readSegment(b.bm, l.offsetsPath, l.offsetsStart+indexOffsetsHeaderSize+int64(b.first+i)*8, 8, b.blockSize)
+	if err != nil {
+		return indexEntry{}, err
+	}
+	off := l.start + int64(binary.LittleEndian.Uint64(raw))
+	raw, err = readSegment(b.bm, l.path, off, 8, b.blockSize)
+	if err != nil {
+		return indexEntry{}, err
+	}
+	ksz := int(binary.LittleEndian.Uint64(raw))
+	raw, err = readSegment(b.bm, l.path, off+8, ksz+8, b.blockSize)
+	if err != nil {
+		return indexEntry{}, err
+	}
+	e := indexEntry{key: raw[:ksz], dataOffset: binary.LittleEndian.Uint64(raw[ksz:])}
+	b.entries[i] = e
+	return e, nil
+}
+
+// search vraća poziciju prvog unosa čiji je ključ >= key
+func (b indexBlock) search(key []byte) (int, error) {
+	var err error
+	i := sort.Search(b.n, func(i int) bool {
+		if err != nil {
+			return true
+		}
+		var e indexEntry
+		e, err = b.entry(i)
+		return err != nil || bytes.Compare(e.key, key) >= 0
+	})
+	return i, err
+}
+
+// find vraća offset zapisa sa datim ključem u data segmentu
+func (b indexBlock) find(key []byte) (uint64, bool, error) {
+	i, err := b.search(key)
+	if err != nil || i >= b.n {
+		return 0, false, err
+	}
+	e, err := b.entry(i)
+	if err != nil || !bytes.Equal(e.key, key) {
+		return 0, false, err
+	}
+	return e.dataOffset, true, nil
+}
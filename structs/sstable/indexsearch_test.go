@@ -0,0 +1,76 @@
+package sstable
+
+import (
+	"math/bits"
+	"path/filepath"
+	"testing"
+
+	"projekat/structs/blockmanager"
+)
+
+// indexLookup traži ključ u delu indexa i vraća offset zapisa i broj blokova pročitanih sa diska
+func indexLookup(t *testing.T, bm *blockmanager.BlockManager, l indexLayout, summary *Summary, key []byte) (uint64, int) {
+	before := bm.BlocksRead
+	block, err := l.readBlock(bm, summary, findSummaryEntry(summary, key), testBlockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	off, found, err := block.find(key)
+	if err != nil || !found {
+		t.Fatalf("ključ %s nije pronađen u indexu (%v)", key, err)
+	}
+	return off, bm.BlocksRead - before
+}
+
+// Pretraga indexa čita samo offsete i unose koje binarna pretraga proverava, pa broj pročitanih
+// blokova po ključu raste sa logaritmom koraka summary-ja, a ne sa veličinom dela indexa
+// koji čitaju starije tabele
+func TestIndexLookupReadsFewBlocks(t *testing.T) {
+	records := testRecords(3000)
+	for _, single := range []bool{false, true} {
+		for _, step := range []int{4, 64, 512} {
+			dir := t.TempDir()
+			bm := blockmanager.NewBlockManager(testBlockSize, 64)
+			_, sstDir, err := CreateSSTable(records, nil, dir, step, bm, testBlockSize, 0, single, false, NewDictionary(),
+				filepath.Join(dir, "dict.db"), FilterPolicy{LevelFPRates: []float64{0.01}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sst, err := ReadTableFromDir(sstDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			summary, err := ReadSummaryFromTable(sst, bm, testBlockSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			layout, err := loadIndexLayout(sst, bm, testBlockSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			legacy := layout
+			legacy.offsetsPath = ""
+
+			most, mostLegacy := 0, 0
+			for i := 0; i < len(records); i += 97 {
+				key := records[i].Key
+				// Prazan keš: broji se svaki blok koji pretraga dotakne
+				cold := blockmanager.NewBlockManager(testBlockSize, 64)
+				off, reads := indexLookup(t, cold, layout, summary, key)
+				legacyOff, legacyReads := indexLookup(t, blockmanager.NewBlockManager(testBlockSize, 64), legacy, summary, key)
+				if off != legacyOff {
+					t.Fatalf("single=%v, korak %d: %s na offsetu %d, a prolaskom kroz index %d", single, step, key, off, legacyOff)
+				}
+				most, mostLegacy = max(most, reads), max(mostLegacy, legacyReads)
+			}
+			t.Logf("single=%v, korak %d: najviše %d blokova po ključu, ceo deo indexa %d", single, step, most, mostLegacy)
+			// Po proveri unosa: offset i unos, svaki najviše na granici dva bloka
+			if bound := 4 * (bits.Len(uint(step)) + 1); most > bound {
+				t.Fatalf("single=%v, korak %d: %d blokova po ključu, očekivano najviše %d", single, step, most, bound)
+			}
+			if step == 512 && most*2 > mostLegacy {
+				t.Fatalf("single=%v, korak %d: %d blokova po ključu, a ceo deo indexa %d", single, step, most, mostLegacy)
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package sstable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"strings"
+
+	"projekat/config"
+	"projekat/structs/blockmanager"
+	"projekat/structs/probabilistic"
+)
+
+// Vrste ekstraktora prefiksa
+const (
+	prefixNone byte = iota
+	prefixFixed
+	prefixDelimiter
+)
+
+// PrefixExtractor izdvaja prefiks ključa koji se dodaje u prefiksni Bloom filter tabele.
+// Fiksni ekstraktor uzima prvih Length bajtova, a ekstraktor sa delimiterom sve do
+// prvog pojavljivanja delimitera (uključujući i njega). Ključevi bez prefiksa se ne dodaju.
+type PrefixExtractor struct {
+	kind      byte
+	length    int
+	delimiter []byte
+}
+
+func NewPrefixExtractor(cfg config.Config) PrefixExtractor {
+	switch cfg.PrefixExtractor {
+	case "Fixed":
+		if cfg.PrefixLength > 0 {
+			return PrefixExtractor{kind: prefixFixed, length: cfg.PrefixLength}
+		}
+	case "Delimiter":
+		if cfg.PrefixDelimiter != "" {
+			return PrefixExtractor{kind: prefixDelimiter, delimiter: []byte(cfg.PrefixDelimiter)}
+		}
+	}
+	return PrefixExtractor{kind: prefixNone}
+}
+
+func (pe PrefixExtractor) Enabled() bool {
+	return pe.kind != prefixNone
+}
+
+// Extract vraća prefiks ključa, odnosno false ako ključ nema prefiks.
+// Isto važi i za prefiks upita: ako on sadrži ceo izdvojeni prefiks, svi ključevi koji počinju
+// upitom imaju isti izdvojeni prefiks, pa se filter može koristiti.
+func (pe PrefixExtractor) Extract(key []byte) ([]byte, bool) {
+	switch pe.kind {
+	case prefixFixed:
+		if len(key) >= pe.length {
+			return key[:pe.length], true
+		}
+	case prefixDelimiter:
+		if i := bytes.Index(key, pe.delimiter); i >= 0 {
+			return key[:i+len(pe.delimiter)], true
+		}
+	}
+	return nil, false
+}
+
+// serialize zapisuje opis ekstraktora kako bi se tabela čitala istim pravilom
+// i nakon promene konfiguracije
+func (pe PrefixExtractor) serialize(buf *bytes.Buffer) {
+	buf.WriteByte(pe.kind)
+	binary.Write(buf, binary.LittleEndian, uint32(pe.length))
+	binary.Write(buf, binary.LittleEndian, uint32(len(pe.delimiter)))
+	buf.Write(pe.delimiter)
+}
+
+// PrefixFilter je prefiksni Bloom filter jedne tabele zajedno sa ekstraktorom kojim je napravljen
+type PrefixFilter struct {
+	Extractor PrefixExtractor
+	Filter    *probabilistic.BloomFilter
+}
+
+// MayContain vraća false samo ako tabela sigurno nema nijedan ključ sa datim prefiksom
+func (pf *PrefixFilter) MayContain(prefix string) bool {
+	if pf == nil {
+		return true
+	}
+	p, ok := pf.Extractor.Extract([]byte(prefix))
+	if !ok {
+		return true
+	}
+	return pf.Filter.IsAdded(string(p))
+}
+
+func encodePrefixFilter(pe PrefixExtractor, bf *probabilistic.BloomFilter) []byte {
+	buf := &bytes.Buffer{}
+	pe.serialize(buf)
+	buf.Write(bf.Serialize())
+	return buf.Bytes()
+}
+
+func decodePrefixFilter(data []byte) (*PrefixFilter, error) {
+	if len(data) < 9 {
+		return nil, errors.New("neispravan prefiksni filter")
+	}
+	pe := PrefixExtractor{kind: data[0], length: int(binary.LittleEndian.Uint32(data[1:5]))}
+	delimLen := int(binary.LittleEndian.Uint32(data[5:9]))
+	if len(data) < 9+delimLen {
+		return nil, errors.New("neispravan prefiksni filter")
+	}
+	pe.delimiter = append([]byte{}, data[9:9+delimLen]...)
+	bf := &probabilistic.BloomFilter{}
+	bf.Deserialize(data[9+delimLen:])
+	return &PrefixFilter{Extractor: pe, Filter: bf}, nil
+}
+
+// ReadPrefixFilterFromTable učitava prefiksni filter tabele; vraća nil ako ga tabela nema
+func ReadPrefixFilterFromTable(sst *SSTable, bm *blockmanager.BlockManager, blockSize int) (*PrefixFilter, error) {
+	data, err := readOptionalSection(sst, sectionPrefixFilter, sst.PrefixFilterFilePath, bm, blockSize)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return decodePrefixFilter(data)
+}
+
+// readOptionalSection čita deo tabele koji starije tabele nemaju. Za tabelu u jednom fajlu
+// deo postoji ako ga header navodi i nije prazan, a za tabelu u više fajlova ako fajl postoji.
+func readOptionalSection(sst *SSTable, section int, multiPath string, bm *blockmanager.BlockManager,
+	blockSize int) ([]byte, error) {
+	if sst.SingleSSTable {
+		offsets, err := parseHeader(bm, sst.SingleFilePath, blockSize)
+		if err != nil {
+			return nil, err
+		}
+		start, end, ok := sectionBounds(offsets, section)
+		if !ok {
+			return nil, nil
+		}
+		return readSegment(bm, sst.SingleFilePath, start, int(end-start), blockSize)
+	}
+	if multiPath == "" {
+		return nil, nil
+	}
+	info, err := os.Stat(multiPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return readSegment(bm, multiPath, 0, int(info.Size()), blockSize)
+}
+
+// MayContainPrefix proverava opseg tabele i prefiksni filter bez čitanja sa diska
+func (r *TableReader) MayContainPrefix(prefix string) bool {
+	if string(r.Summary.MaxKey) < prefix {
+		return false
+	}
+	if string(r.Summary.MinKey) > prefix && !strings.HasPrefix(string(r.Summary.MinKey), prefix) {
+		return false
+	}
+	return r.PrefixFilter.MayContain(prefix)
+}
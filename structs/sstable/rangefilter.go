@@ -0,0 +1,397 @@
+package sstable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+	"sort"
+
+	"projekat/structs/blockmanager"
+)
+
+// Prva reč sažetog filtera opsega. Stariji format (sortirani skup prefiksa) počinje dužinom
+// prefiksa, koja nikada nije ovolika, pa se takav filter prepoznaje i ne koristi.
+const rangeFilterMagic = 0xFFFFFFFF
+
+// Zastavice grane trie-a u privremenim fajlovima builder-a
+const (
+	labelHasChild = 1 << iota
+	labelFirst
+	labelPrefixKey
+)
+
+// RangeFilter odgovara na pitanje "može li tabela sadržati ključ iz [a, b]".
+// Po uzoru na SuRF, prefiksi ključeva dužine PrefixLength (kraći ključevi se čuvaju celi) čine
+// trie kodiran kao LOUDS-Sparse: oznake grana svih čvorova redom po nivoima, uz po bit po grani
+// za "grana ima dete" i "prva grana čvora" i po bit po čvoru za "u čvoru se završava ključ".
+// Čvorovi se ne čuvaju eksplicitno - dete i granice čvora se računaju rank i select operacijama
+// nad bitovima, pa filter zauzima oko bajt i tri bita po grani.
+// Svaki prefiks predstavlja interval ključeva koji njime počinju, pa filter nikada ne odbacuje
+// tabelu koja sadrži ključ iz opsega, a za kratke upite nad tabelama širokog opsega najčešće
+// pronalazi prazninu između prefiksa.
+type RangeFilter struct {
+	prefixLength int
+	labels       []byte
+	hasChild     *bitVector
+	louds        *bitVector
+	prefixKey    *bitVector
+}
+
+// trieLabel je grana trie-a koja čeka upis: njena zastavica "ima dete" se menja sve dok je
+// grana poslednja na svom nivou
+type trieLabel struct {
+	label byte
+	flags byte
+	set   bool
+}
+
+// rangeFilterBuilder gradi trie dok ključevi stižu sortirani. Grane nastaju redom po nivoima,
+// pa se svaki nivo upisuje u svoj privremeni fajl; u memoriji ostaju samo poslednji prefiks i
+// poslednja grana svakog nivoa.
+type rangeFilterBuilder struct {
+	prefixLength  int
+	out           *spillFile
+	levels        []*spillFile
+	pending       []trieLabel
+	count         int
+	labels        int
+	nodes         int
+	last          []byte
+	rootPrefixKey bool
+}
+
+func newRangeFilterBuilder(prefixLength int) *rangeFilterBuilder {
+	return &rangeFilterBuilder{prefixLength: prefixLength}
+}
+
+func (b *rangeFilterBuilder) add(key []byte) error {
+	entry := key[:min(len(key), b.prefixLength)]
+	if b.count > 0 && bytes.Equal(b.last, entry) {
+		return nil
+	}
+	shared := 0
+	if b.count > 0 {
+		for shared < len(b.last) && shared < len(entry) && b.last[shared] == entry[shared] {
+			shared++
+		}
+	}
+	// Prva grana novog prefiksa ulazi u čvor prethodnog prefiksa, osim ako je prethodni prefiks
+	// ceo sadržan u novom: tada njegova poslednja grana dobija dete u kom se on završava
+	flags := byte(0)
+	if b.count == 0 {
+		b.rootPrefixKey = len(entry) == 0
+		flags = labelFirst
+	} else if shared == len(b.last) {
+		if shared > 0 {
+			b.pending[shared-1].flags |= labelHasChild
+		}
+		flags = labelFirst | labelPrefixKey
+	}
+	for d := shared; d < len(entry); d++ {
+		if d > shared {
+			flags = labelFirst
+		}
+		if d < len(entry)-1 {
+			flags |= labelHasChild
+		}
+		if err := b.push(d, entry[d], flags); err != nil {
+			return err
+		}
+	}
+	b.last = append(b.last[:0], entry...)
+	b.count++
+	return nil
+}
+
+// push dodaje granu na nivo; prethodna grana tog nivoa je konačna i upisuje se
+func (b *rangeFilterBuilder) push(level int, label byte, flags byte) error {
+	for len(b.pending) <= level {
+		b.pending = append(b.pending, trieLabel{})
+		b.levels = append(b.levels, nil)
+	}
+	if err := b.flushLevel(level); err != nil {
+		return err
+	}
+	b.pending[level] = trieLabel{label: label, flags: flags, set: true}
+	b.labels++
+	if flags&labelFirst != 0 {
+		b.nodes++
+	}
+	return nil
+}
+
+func (b *rangeFilterBuilder) flushLevel(level int) error {
+	p := b.pending[level]
+	if !p.set {
+		return nil
+	}
+	if b.levels[level] == nil {
+		sp, err := newSpillFile()
+		if err != nil {
+			return err
+		}
+		b.levels[level] = sp
+	}
+	_, err := b.levels[level].Write([]byte{p.label, p.flags})
+	b.pending[level].set = false
+	return err
+}
+
+// eachLabel prolazi kroz sve grane redom po nivoima
+func (b *rangeFilterBuilder) eachLabel(fn func(label, flags byte)) error {
+	record := make([]byte, 2)
+	for _, sp := range b.levels {
+		if sp == nil {
+			continue
+		}
+		rdr, err := sp.reader()
+		if err != nil {
+			return err
+		}
+		for {
+			if _, err := io.ReadFull(rdr, record); err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+			fn(record[0], record[1])
+		}
+	}
+	return nil
+}
+
+// sections vraća header i kodiran trie.
+// Format: [oznaka][dužina prefiksa][broj grana][broj čvorova] (po 4 bajta), pa oznake grana,
+// bitovi "ima dete", bitovi "prva grana čvora" i bitovi "u čvoru se završava ključ"
+func (b *rangeFilterBuilder) sections() ([]sectionPart, error) {
+	for level := range b.pending {
+		if err := b.flushLevel(level); err != nil {
+			return nil, err
+		}
+	}
+	nodes := b.nodes
+	if b.labels == 0 && b.rootPrefixKey {
+		nodes = 1
+	}
+	header := make([]byte, 0, 16)
+	header = binary.LittleEndian.AppendUint32(header, rangeFilterMagic)
+	header = binary.LittleEndian.AppendUint32(header, uint32(b.prefixLength))
+	header = binary.LittleEndian.AppendUint32(header, uint32(b.labels))
+	header = binary.LittleEndian.AppendUint32(header, uint32(nodes))
+
+	if err := b.eachLabel(func(label, _ byte) { b.out.Write([]byte{label}) }); err != nil {
+		return nil, err
+	}
+	bw := &bitWriter{out: b.out}
+	for _, flag := range []byte{labelHasChild, labelFirst} {
+		if err := b.eachLabel(func(_, flags byte) { bw.write(flags&flag != 0) }); err != nil {
+			return nil, err
+		}
+		bw.flush()
+	}
+	if b.labels == 0 {
+		bw.write(b.rootPrefixKey)
+	} else if err := b.eachLabel(func(_, flags byte) {
+		if flags&labelFirst != 0 {
+			bw.write(flags&labelPrefixKey != 0)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	bw.flush()
+	return []sectionPart{{buf: header}, {spill: b.out}}, nil
+}
+
+// remove briše sve privremene fajlove builder-a
+func (b *rangeFilterBuilder) remove() {
+	for _, sp := range append(b.levels, b.out) {
+		if sp != nil {
+			sp.remove()
+		}
+	}
+}
+
+func decodeRangeFilter(data []byte) (*RangeFilter, error) {
+	if len(data) < 4 || binary.LittleEndian.Uint32(data) != rangeFilterMagic {
+		// Filter starijeg formata - tabela se uvek pretražuje
+		return nil, nil
+	}
+	if len(data) < 16 {
+		return nil, errors.New("neispravan filter opsega")
+	}
+	prefixLength := int(binary.LittleEndian.Uint32(data[4:]))
+	labels := int(binary.LittleEndian.Uint32(data[8:]))
+	nodes := int(binary.LittleEndian.Uint32(data[12:]))
+	labelBits := (labels + 7) / 8
+	if len(data) < 16+labels+2*labelBits+(nodes+7)/8 {
+		return nil, errors.New("neispravan filter opsega")
+	}
+	data = data[16:]
+	rf := &RangeFilter{prefixLength: prefixLength, labels: data[:labels]}
+	data = data[labels:]
+	rf.hasChild = newBitVector(data[:labelBits], labels)
+	data = data[labelBits:]
+	rf.louds = newBitVector(data[:labelBits], labels)
+	data = data[labelBits:]
+	rf.prefixKey = newBitVector(data[:(nodes+7)/8], nodes)
+	return rf, nil
+}
+
+// MayContain vraća false samo ako tabela sigurno nema nijedan ključ iz [a, b]
+func (rf *RangeFilter) MayContain(a, b []byte) bool {
+	if rf == nil {
+		return true
+	}
+	// Najmanji ključ prvog intervala koji doseže do a mora biti najviše b
+	first, ok := rf.seek(0, 0, a)
+	return ok && bytes.Compare(first, b) <= 0
+}
+
+// seek vraća najmanji početak intervala u podstablu čvora koji doseže do a; put do čvora je
+// a[:depth], gde je depth dubina čvora
+func (rf *RangeFilter) seek(node, depth int, a []byte) ([]byte, bool) {
+	if depth == len(a) {
+		return rf.leftmost(node, a[:depth:depth])
+	}
+	// Ključ koji se završava u čvoru je pravi prefiks od a, pa je manji od njega
+	start, end := rf.nodeRange(node)
+	pos := start + sort.Search(end-start, func(i int) bool { return rf.labels[start+i] >= a[depth] })
+	if pos < end && rf.labels[pos] == a[depth] {
+		if rf.hasChild.get(pos) {
+			if first, ok := rf.seek(rf.hasChild.rank(pos), depth+1, a); ok {
+				return first, true
+			}
+		} else if depth+1 == rf.prefixLength || depth+1 == len(a) {
+			// Skraćen prefiks obuhvata sve ključeve koji njime počinju, pa i a
+			return a[:depth+1], true
+		}
+		pos++
+	}
+	if pos == end {
+		return nil, false
+	}
+	first := append(a[:depth:depth], rf.labels[pos])
+	if !rf.hasChild.get(pos) {
+		return first, true
+	}
+	return rf.leftmost(rf.hasChild.rank(pos), first)
+}
+
+// leftmost vraća najmanji prefiks u podstablu čvora; path je put do čvora
+func (rf *RangeFilter) leftmost(node int, path []byte) ([]byte, bool) {
+	for {
+		if rf.prefixKey.get(node) {
+			return path, true
+		}
+		start, end := rf.nodeRange(node)
+		if start == end {
+			return nil, false
+		}
+		path = append(path, rf.labels[start])
+		if !rf.hasChild.get(start) {
+			return path, true
+		}
+		node = rf.hasChild.rank(start)
+	}
+}
+
+// nodeRange vraća pozicije prve grane čvora i prve grane sledećeg čvora
+func (rf *RangeFilter) nodeRange(node int) (int, int) {
+	start := rf.louds.selectOne(node)
+	if start < 0 {
+		return 0, 0
+	}
+	end := rf.louds.selectOne(node + 1)
+	if end < 0 {
+		end = len(rf.labels)
+	}
+	return start, end
+}
+
+// bitVector je niz bitova sa brojem jedinica pre svake reči, za rank i select
+type bitVector struct {
+	words []uint64
+	ranks []int
+	n     int
+}
+
+// newBitVector čita n bitova zapisanih od najnižeg bita prvog bajta
+func newBitVector(data []byte, n int) *bitVector {
+	bv := &bitVector{words: make([]uint64, (n+63)/64), n: n}
+	for i, b := range data {
+		bv.words[i/8] |= uint64(b) << (8 * (i % 8))
+	}
+	bv.ranks = make([]int, len(bv.words)+1)
+	for i, w := range bv.words {
+		bv.ranks[i+1] = bv.ranks[i] + bits.OnesCount64(w)
+	}
+	return bv
+}
+
+func (bv *bitVector) get(i int) bool {
+	return i < bv.n && bv.words[i/64]&(1<<(i%64)) != 0
+}
+
+// rank vraća broj jedinica na pozicijama [0, i]
+func (bv *bitVector) rank(i int) int {
+	w := i / 64
+	return bv.ranks[w] + bits.OnesCount64(bv.words[w]&(^uint64(0)>>(63-i%64)))
+}
+
+// selectOne vraća poziciju k-te jedinice (od nule); -1 ako je nema
+func (bv *bitVector) selectOne(k int) int {
+	if k < 0 || k >= bv.ranks[len(bv.words)] {
+		return -1
+	}
+	// Poslednja reč pre koje ima najviše k jedinica
+	w := sort.Search(len(bv.words), func(i int) bool { return bv.ranks[i+1] > k })
+	word := bv.words[w]
+	for j := bv.ranks[w]; j < k; j++ {
+		word &= word - 1
+	}
+	return w*64 + bits.TrailingZeros64(word)
+}
+
+// bitWriter pakuje bitove u bajtove, od najnižeg bita
+type bitWriter struct {
+	out  io.Writer
+	cur  byte
+	used int
+}
+
+func (bw *bitWriter) write(bit bool) {
+	if bit {
+		bw.cur |= 1 << bw.used
+	}
+	bw.used++
+	if bw.used == 8 {
+		bw.flush()
+	}
+}
+
+// flush upisuje započet bajt; sledeći bit počinje novi bajt
+func (bw *bitWriter) flush() {
+	if bw.used > 0 {
+		bw.out.Write([]byte{bw.cur})
+		bw.cur, bw.used = 0, 0
+	}
+}
+
+// ReadRangeFilterFromTable učitava filter opsega tabele; vraća nil ako ga tabela nema
+func ReadRangeFilterFromTable(sst *SSTable, bm *blockmanager.BlockManager, blockSize int) (*RangeFilter, error) {
+	data, err := readOptionalSection(sst, sectionRangeFilter, sst.RangeFilterFilePath, bm, blockSize)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return decodeRangeFilter(data)
+}
+
+// MayContainRange proverava opseg tabele i filter opsega bez čitanja sa diska
+func (r *TableReader) MayContainRange(minKey, maxKey string) bool {
+	if string(r.Summary.MaxKey) < minKey || string(r.Summary.MinKey) > maxKey {
+		return false
+	}
+	return r.RangeFilter.MayContain([]byte(minKey), []byte(maxKey))
+}
@@ -0,0 +1,131 @@
+package sstable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// buildRangeFilter gradi i dekodira filter opsega za sortirane ključeve
+func buildRangeFilter(t *testing.T, prefixLength int, keys [][]byte) *RangeFilter {
+	b := newRangeFilterBuilder(prefixLength)
+	out, err := newSpillFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.out = out
+	defer b.remove()
+	for _, key := range keys {
+		if err := b.add(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parts, err := b.sections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data bytes.Buffer
+	for _, part := range parts {
+		data.Write(part.buf)
+		if part.spill != nil {
+			rdr, err := part.spill.reader()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data.ReadFrom(rdr)
+		}
+	}
+	rf, err := decodeRangeFilter(data.Bytes())
+	if err != nil || rf == nil {
+		t.Fatalf("dekodiranje filtera: %v", err)
+	}
+	return rf
+}
+
+// randomKey vraća ključ nad malom azbukom, pa ključevi često dele prefikse i jedan ključ je
+// prefiks drugog
+func randomKey(r *rand.Rand, maxLen int) []byte {
+	key := make([]byte, r.Intn(maxLen+1))
+	for i := range key {
+		key[i] = "abcd"[r.Intn(4)]
+	}
+	return key
+}
+
+// Filter nikada ne odbacuje opseg u kom postoji ključ, a odbacuje opsege u prazninama
+func TestRangeFilterMayContain(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for round := 0; round < 200; round++ {
+		prefixLength := 1 + r.Intn(5)
+		keys := make([][]byte, r.Intn(40))
+		for i := range keys {
+			keys[i] = randomKey(r, 7)
+		}
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+		rf := buildRangeFilter(t, prefixLength, keys)
+
+		rejected := 0
+		for q := 0; q < 200; q++ {
+			a, b := randomKey(r, 7), randomKey(r, 7)
+			if bytes.Compare(a, b) > 0 {
+				a, b = b, a
+			}
+			contains := false
+			for _, key := range keys {
+				if bytes.Compare(key, a) >= 0 && bytes.Compare(key, b) <= 0 {
+					contains = true
+				}
+			}
+			may := rf.MayContain(a, b)
+			if contains && !may {
+				t.Fatalf("prefiks %d, ključevi %q: opseg [%q, %q] odbačen", prefixLength, keys, a, b)
+			}
+			if !may {
+				rejected++
+			}
+		}
+		if len(keys) > 0 && prefixLength >= 4 && rejected == 0 {
+			t.Fatalf("prefiks %d, ključevi %q: nijedan opseg nije odbačen", prefixLength, keys)
+		}
+	}
+}
+
+func TestRangeFilterEdgeCases(t *testing.T) {
+	keys := [][]byte{{}, []byte("ab"), []byte("abc"), []byte("abcdef"), []byte("b")}
+	rf := buildRangeFilter(t, 4, keys)
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"", "", true},
+		{"a", "aa", false},
+		{"ab", "ab", true},
+		{"abb", "abbz", false},
+		{"abc", "abc", true},
+		// "abcdef" je skraćen na "abcd", pa pokriva sve ključeve sa tim prefiksom
+		{"abcdzz", "abce", true},
+		{"abce", "az", false},
+		{"b", "b", true},
+		{"ba", "zz", false},
+	}
+	for _, c := range cases {
+		if got := rf.MayContain([]byte(c.a), []byte(c.b)); got != c.want {
+			t.Errorf("[%q, %q]: %v, očekivano %v", c.a, c.b, got, c.want)
+		}
+	}
+
+	// Tabela samo sa praznim ključem
+	rf = buildRangeFilter(t, 4, [][]byte{{}})
+	if !rf.MayContain(nil, nil) || rf.MayContain([]byte("a"), []byte("b")) {
+		t.Error("filter sa praznim ključem")
+	}
+
+	// Filter starijeg formata (sortirani skup prefiksa) se ne koristi
+	old := binary.LittleEndian.AppendUint32(nil, 4)
+	old = binary.LittleEndian.AppendUint32(old, 0)
+	if rf, err := decodeRangeFilter(old); err != nil || rf != nil || !rf.MayContain([]byte("a"), []byte("b")) {
+		t.Errorf("stari format: %v, %v", rf, err)
+	}
+}
@@ -0,0 +1,124 @@
+package sstable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"projekat/structs/blockmanager"
+)
+
+// RangeTombstone briše sve ključeve iz [Start, End) čija je verzija starija od brisanja.
+// Ključevi upisani posle brisanja (sa novijim timestamp-om) ostaju vidljivi.
+type RangeTombstone struct {
+	Start     []byte
+	End       []byte
+	Timestamp [16]byte
+}
+
+// Covers proverava da li brisanje obuhvata ključ
+func (rt RangeTombstone) Covers(key []byte) bool {
+	return bytes.Compare(key, rt.Start) >= 0 && bytes.Compare(key, rt.End) < 0
+}
+
+// Overlaps proverava da li brisanje obuhvata bar jedan ključ iz [minKey, maxKey]
+func (rt RangeTombstone) Overlaps(minKey, maxKey []byte) bool {
+	return bytes.Compare(rt.Start, maxKey) <= 0 && bytes.Compare(rt.End, minKey) > 0
+}
+
+// timestampValue vraća vreme upisa iz timestamp-a (prvih 8 bajtova)
+func timestampValue(ts [16]byte) uint64 {
+	return binary.LittleEndian.Uint64(ts[:8])
+}
+
+// CoveringTimestamp vraća vreme najnovijeg brisanja opsega koje obuhvata ključ; 0 ako ga nema
+func CoveringTimestamp(rts []RangeTombstone, key []byte) uint64 {
+	covering := uint64(0)
+	for _, rt := range rts {
+		if rt.Covers(key) {
+			covering = max(covering, timestampValue(rt.Timestamp))
+		}
+	}
+	return covering
+}
+
+// ApplyRangeTombstones vraća tombstone umesto zapisa ako ga briše neko od brisanja opsega
+// upisano u isto vreme ili kasnije od zapisa
+func ApplyRangeTombstones(rec *Record, rts []RangeTombstone) *Record {
+	if rec == nil || rec.Tombstone || timestampValue(rec.Timestamp) > CoveringTimestamp(rts, rec.Key) {
+		return rec
+	}
+	deleted := *rec
+	deleted.Tombstone, deleted.Separated = true, false
+	deleted.Value, deleted.ValueSize = nil, 0
+	return &deleted
+}
+
+// encodeRangeTombstones serijalizuje brisanja opsega sortirana po početku:
+// [broj (8)], pa za svako [timestamp (16)][dužina početka (8)][početak][dužina kraja (8)][kraj]
+func encodeRangeTombstones(rts []RangeTombstone) []byte {
+	if len(rts) == 0 {
+		return nil
+	}
+	sorted := append([]RangeTombstone{}, rts...)
+	sort.SliceStable(sorted, func(i, j int) bool { return bytes.Compare(sorted[i].Start, sorted[j].Start) < 0 })
+	buf := binary.LittleEndian.AppendUint64(nil, uint64(len(sorted)))
+	for _, rt := range sorted {
+		buf = append(buf, rt.Timestamp[:]...)
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(len(rt.Start)))
+		buf = append(buf, rt.Start...)
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(len(rt.End)))
+		buf = append(buf, rt.End...)
+	}
+	return buf
+}
+
+func decodeRangeTombstones(data []byte) ([]RangeTombstone, error) {
+	errCorrupt := errors.New("neispravna brisanja opsega u SSTabeli")
+	if len(data) < 8 {
+		return nil, errCorrupt
+	}
+	count := binary.LittleEndian.Uint64(data[:8])
+	pos := 8
+	// readBytes čita niz bajtova kome prethodi njegova dužina
+	readBytes := func() ([]byte, bool) {
+		if pos+8 > len(data) {
+			return nil, false
+		}
+		n := int(binary.LittleEndian.Uint64(data[pos : pos+8]))
+		pos += 8
+		if n < 0 || pos+n > len(data) {
+			return nil, false
+		}
+		b := append([]byte{}, data[pos:pos+n]...)
+		pos += n
+		return b, true
+	}
+	rts := make([]RangeTombstone, 0, count)
+	for i := uint64(0); i < count; i++ {
+		var rt RangeTombstone
+		if pos+16 > len(data) {
+			return nil, errCorrupt
+		}
+		copy(rt.Timestamp[:], data[pos:pos+16])
+		pos += 16
+		var ok1, ok2 bool
+		rt.Start, ok1 = readBytes()
+		rt.End, ok2 = readBytes()
+		if !ok1 || !ok2 {
+			return nil, errCorrupt
+		}
+		rts = append(rts, rt)
+	}
+	return rts, nil
+}
+
+// ReadRangeTombstonesFromTable učitava brisanja opsega tabele; vraća nil ako ih tabela nema
+func ReadRangeTombstonesFromTable(sst *SSTable, bm *blockmanager.BlockManager, blockSize int) ([]RangeTombstone, error) {
+	data, err := readOptionalSection(sst, sectionRangeTombstones, sst.RangeTombstonesFilePath, bm, blockSize)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return decodeRangeTombstones(data)
+}
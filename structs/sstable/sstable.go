@@ -9,7 +9,6 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"time"
 
 	"projekat/config"
 	"projekat/structs/blockmanager"
@@ -22,6 +21,8 @@ type Record struct {
 	CRC       uint32
 	Timestamp [16]byte
 	Tombstone bool
+	// Vrednost je pokazivač na unos u value log-u
+	Separated bool
 	KeySize   uint64
 	ValueSize uint64
 	Key       []byte
@@ -64,6 +65,13 @@ type SSTable struct {
 	FilterFilePath   string
 	MetadataFilePath string
 
+	// Opcioni delovi (starije tabele ih nemaju)
+	PrefixFilterFilePath string
+	RangeFilterFilePath  string
+	IndexOffsetsFilePath string
+	// Brisanja opsega; fajl postoji samo ako ih tabela ima
+	RangeTombstonesFilePath string
+
 	// Pomoćne strukture
 	Filter   *probabilistic.BloomFilter
 	Metadata *merkletree.MerkleTree
@@ -85,19 +93,24 @@ func NewMultiFileSSTable(path string, ts int64) *SSTable {
 		SummaryFilePath:  filepath.Join(path, fmt.Sprintf("%d-Summary.db", ts)),
 		FilterFilePath:   filepath.Join(path, fmt.Sprintf("%d-Filter.db", ts)),
 		MetadataFilePath: filepath.Join(path, fmt.Sprintf("%d-Metadata.db", ts)),
+
+		PrefixFilterFilePath: filepath.Join(path, fmt.Sprintf("%d-PrefixFilter.db", ts)),
+		RangeFilterFilePath:  filepath.Join(path, fmt.Sprintf("%d-RangeFilter.db", ts)),
+		IndexOffsetsFilePath: filepath.Join(path, fmt.Sprintf("%d-IndexOffsets.db", ts)),
+
+		RangeTombstonesFilePath: filepath.Join(path, fmt.Sprintf("%d-RangeTombstones.db", ts)),
 	}
 }
 
 // writeBlocks deli ulazni bajt-niz na blokove veličine BlockManager-a i zapisuje svaki blok redom u datoteku.
 func writeBlocks(bm *blockmanager.BlockManager, path string, buf []byte, blockSize int) error {
 	bs := blockSize
-	bm.Block_idx = 0
-	for len(buf) > 0 {
+	for idx := 0; len(buf) > 0; idx++ {
 		n := bs
 		if len(buf) < bs {
 			n = len(buf)
 		}
-		if err := bm.WriteBlock(path, buf[:n]); err != nil {
+		if err := bm.WriteBlockAt(path, idx, buf[:n]); err != nil {
 			return err
 		}
 		buf = buf[n:]
@@ -135,11 +148,33 @@ func readSegment(bm *blockmanager.BlockManager, path string, offset int64, lengt
 	return out, nil
 }
 
+// Vrednosti bajta zastavice zapisa
+const (
+	flagLive byte = iota
+	flagTombstone
+	flagSeparated
+)
+
+func (r Record) flag() byte {
+	switch {
+	case r.Tombstone:
+		return flagTombstone
+	case r.Separated:
+		return flagSeparated
+	}
+	return flagLive
+}
+
+func (r *Record) setFlag(flag byte) {
+	r.Tombstone = flag == flagTombstone
+	r.Separated = flag == flagSeparated
+}
+
 // calculateCRC računa CRC32 (IEEE) preko svih polja osim samog CRC-a.
 func calculateCRC(record Record) uint32 {
 	buffer := bytes.Buffer{}
 	binary.Write(&buffer, binary.LittleEndian, record.Timestamp)
-	binary.Write(&buffer, binary.LittleEndian, record.Tombstone)
+	buffer.WriteByte(record.flag())
 	binary.Write(&buffer, binary.LittleEndian, record.KeySize)
 	binary.Write(&buffer, binary.LittleEndian, record.ValueSize)
 	buffer.Write(record.Key)
@@ -155,12 +190,12 @@ func calculateCompressedCRC(record Record, dict *Dictionary) uint32 {
 	// Tombstone (1B)
 	if record.Tombstone {
 		buf.WriteByte(1)
-		WriteUvarint(buf, dict.strToID[string(record.Key)]) // Samo ID ključa
+		WriteUvarint(buf, dict.idOf(string(record.Key))) // Samo ID ključa
 	} else {
-		buf.WriteByte(0)
-		WriteUvarint(buf, dict.strToID[string(record.Key)]) // ID ključa
-		WriteUvarint(buf, uint64(len(record.Value)))        // Varint dužina vrednosti
-		buf.Write(record.Value)                             // Vrednost
+		buf.WriteByte(record.flag())
+		WriteUvarint(buf, dict.idOf(string(record.Key))) // ID ključa
+		WriteUvarint(buf, uint64(len(record.Value)))     // Varint dužina vrednosti
+		buf.Write(record.Value)                          // Vrednost
 	}
 	return crc32.ChecksumIEEE(buf.Bytes())
 }
@@ -197,7 +232,7 @@ func recordBytes(r Record, keyId uint64, compress bool) []byte {
 			buf.WriteByte(1)
 			WriteUvarint(buf, keyId) // Samo ID ključa
 		} else {
-			buf.WriteByte(0)
+			buf.WriteByte(r.flag())
 			WriteUvarint(buf, keyId)                // ID ključa
 			WriteUvarint(buf, uint64(len(r.Value))) // Varint dužina vrednosti
 			buf.Write(r.Value)                      // Vrednost
@@ -215,11 +250,7 @@ func recordBytes(r Record, keyId uint64, compress bool) []byte {
 		r.ValueSize = uint64(len(r.Value))
 
 		buf.Write(r.Timestamp[:])
-		if r.Tombstone {
-			buf.WriteByte(1)
-		} else {
-			buf.WriteByte(0)
-		}
+		buf.WriteByte(r.flag())
 		binary.Write(buf, binary.LittleEndian, r.KeySize)
 		binary.Write(buf, binary.LittleEndian, r.ValueSize)
 		buf.Write(r.Key)
@@ -237,210 +268,24 @@ func recordBytes(r Record, keyId uint64, compress bool) []byte {
 // - step     : razmak (u broju zapisa) između dva unosa u Summary-ju
 // - bm       : globalni BlockManager
 // Funkcija vraća *SSTable sa popunjenim BloomFilter-om i MerkleTree-om.
-func CreateSSTable(records []Record, dir string, step int, bm *blockmanager.BlockManager, blockSize int,
-	lsm byte, singleFile bool, compress bool, dict *Dictionary, dictPath string) (*SSTable, string, error) {
+func CreateSSTable(records []Record, rangeTombstones []RangeTombstone, dir string, step int, bm *blockmanager.BlockManager,
+	blockSize int, lsm byte, singleFile bool, compress bool, dict *Dictionary, dictPath string, filter FilterPolicy) (*SSTable, string, error) {
 	if len(records) == 0 {
 		return nil, "", errors.New("no records to create SSTable")
 	}
 
-	if singleFile {
-		return createSingleFileSSTable(records, dir, step, bm, blockSize, lsm, compress, dict, dictPath)
-	}
-	return createMultiFileSSTable(records, dir, step, bm, blockSize, lsm, compress, dict, dictPath)
-}
-
-// createMultiFileSSTable kreira SSTable u više fajlova koristeći BlockManager.
-func createMultiFileSSTable(records []Record, dir string, step int, bm *blockmanager.BlockManager, blockSize int,
-	lsm byte, compress bool, dict *Dictionary, dictPath string) (*SSTable, string, error) {
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return nil, "", err
-	}
-
-	timestamp := time.Now().UnixNano()
-	sstDir := filepath.Join(dir, fmt.Sprintf("%d-sstable", timestamp))
-	if err := os.MkdirAll(sstDir, 0755); err != nil {
+	w, err := NewSSTableWriter(dir, step, bm, blockSize, lsm, singleFile, compress, dict, dictPath, filter)
+	if err != nil {
 		return nil, "", err
 	}
-	sst := NewMultiFileSSTable(sstDir, timestamp)
-
-	bloom := probabilistic.CreateBF(len(records), 0.01)
-	summaryEntries := make([]SummaryEntry, 0)
-	indexOffset := uint64(0)
-
-	dataBuf := &bytes.Buffer{}
-	indexBuf := &bytes.Buffer{}
-
-	for i, rec := range records {
-		rec.KeySize = uint64(len(rec.Key))
-		rec.ValueSize = uint64(len(rec.Value))
-		rec.CRC = calculateCRC(rec)
-		rb := recordBytes(rec, dict.GetID(string(rec.Key), bm, dictPath, blockSize), compress)
-
-		// Zapis u dataBuf
-		offsetNow := uint64(dataBuf.Len())
-		dataBuf.Write(rb)
-
-		// Zapis u indexBuf
-		binary.Write(indexBuf, binary.LittleEndian, rec.KeySize)
-		indexBuf.Write(rec.Key)
-		binary.Write(indexBuf, binary.LittleEndian, offsetNow)
-
-		// Bloom filter
-		bloom.AddElement(string(rec.Key))
-
-		// Summary
-		if i%step == 0 {
-			summaryEntries = append(summaryEntries, SummaryEntry{Key: rec.Key, Offset: indexOffset})
+	w.AddRangeTombstones(rangeTombstones)
+	for _, rec := range records {
+		if err := w.Add(rec); err != nil {
+			w.Abort()
+			return nil, "", err
 		}
-
-		indexOffset += 8 + rec.KeySize + 8
-	}
-	if dataBuf.Len()%blockSize != 0 {
-		padding := make([]byte, blockSize-(dataBuf.Len()/blockSize))
-		dataBuf.Write(padding)
-	}
-	// Zapis data i index
-	_ = writeBlocks(bm, sst.DataFilePath, dataBuf.Bytes(), blockSize)
-	_ = writeBlocks(bm, sst.IndexFilePath, indexBuf.Bytes(), blockSize)
-
-	// Summary
-	sum := make([]byte, 0)
-	sum = append(sum, lsm)
-	minK := records[0].Key
-	maxK := records[len(records)-1].Key
-	sum = binary.LittleEndian.AppendUint64(sum, uint64(len(minK)))
-	sum = append(sum, minK...)
-	sum = binary.LittleEndian.AppendUint64(sum, uint64(len(maxK)))
-	sum = append(sum, maxK...)
-	sum = binary.LittleEndian.AppendUint64(sum, uint64(len(summaryEntries)))
-	for _, se := range summaryEntries {
-		sum = binary.LittleEndian.AppendUint64(sum, uint64(len(se.Key)))
-		sum = append(sum, se.Key...)
-		sum = binary.LittleEndian.AppendUint64(sum, se.Offset)
-	}
-	_ = writeBlocks(bm, sst.SummaryFilePath, sum, blockSize)
-
-	// Filter
-	_ = writeBlocks(bm, sst.FilterFilePath, bloom.Serialize(), blockSize)
-
-	// Merkle
-	mt := merkletree.NewMerkleTree()
-	mt.ConstructMerkleTree(dataBuf.Bytes(), blockSize)
-	_ = writeBlocks(bm, sst.MetadataFilePath, mt.Serialize(), blockSize)
-
-	sst.Filter = &bloom
-	sst.Metadata = &mt
-	return sst, sstDir, nil
-}
-
-// createSingleFileSSTable kreira SSTable u jednom fajlu koristeci BlockManager.
-func createSingleFileSSTable(records []Record, dir string, step int, bm *blockmanager.BlockManager, blockSize int,
-	lsm byte, compress bool, dict *Dictionary, dictPath string) (*SSTable, string, error) {
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return nil, "", err
 	}
-
-	timestamp := time.Now().UnixNano()
-	sstDir := filepath.Join(dir, fmt.Sprintf("%d-sstable", timestamp))
-	if err := os.MkdirAll(sstDir, 0755); err != nil {
-		return nil, "", err
-	}
-	sst := NewSingleFileSSTable(sstDir, timestamp)
-	var sstOffset int64 = 48
-	b := &bytes.Buffer{}
-	bloom := probabilistic.CreateBF(len(records), 0.01)
-	offsetMap := make([]int64, 6)
-
-	// zapis u databuf
-	dataBuf := &bytes.Buffer{}
-	offsets := make([]uint64, len(records))
-	for i, rec := range records {
-		rec.KeySize = uint64(len(rec.Key))
-		rec.ValueSize = uint64(len(rec.Value))
-		rec.CRC = calculateCRC(rec)
-		rb := recordBytes(rec, dict.GetID(string(rec.Key), bm, dictPath, blockSize), compress)
-		offsets[i] = uint64(dataBuf.Len())
-		dataBuf.Write(rb)
-		bloom.AddElement(string(rec.Key))
-	}
-
-	// if dataBuf.Len()%blockSize != 0 {
-	// 	padding := make([]byte, blockSize-(dataBuf.Len()%blockSize))
-	// 	dataBuf.Write(padding)
-	// }
-
-	offsetMap[0] = sstOffset
-	sstOffset += int64(dataBuf.Len())
-	b.Write(dataBuf.Bytes())
-
-	// zapis u indexbuf
-	indexBuf := &bytes.Buffer{}
-	for i, rec := range records {
-		binary.Write(indexBuf, binary.LittleEndian, uint64(len(rec.Key)))
-		indexBuf.Write(rec.Key)
-		binary.Write(indexBuf, binary.LittleEndian, offsets[i])
-	}
-
-	offsetMap[1] = sstOffset
-	sstOffset += int64(indexBuf.Len())
-	b.Write(indexBuf.Bytes())
-
-	// zapis u summarybuf
-	summaryBuf := &bytes.Buffer{}
-	binary.Write(summaryBuf, binary.LittleEndian, lsm)
-	minK := records[0].Key
-	maxK := records[len(records)-1].Key
-	binary.Write(summaryBuf, binary.LittleEndian, uint64(len(minK)))
-	summaryBuf.Write(minK)
-	binary.Write(summaryBuf, binary.LittleEndian, uint64(len(maxK)))
-	summaryBuf.Write(maxK)
-	count := (len(records) + step - 1) / step
-	binary.Write(summaryBuf, binary.LittleEndian, uint64(count))
-	indexOffset := uint64(0)
-	for i := 0; i < len(records); i++ {
-		rec := records[i]
-		keySize := uint64(len(rec.Key))
-		if i%step == 0 {
-			binary.Write(summaryBuf, binary.LittleEndian, keySize)
-			summaryBuf.Write(rec.Key)
-			binary.Write(summaryBuf, binary.LittleEndian, indexOffset)
-		}
-		indexOffset += 8 + keySize + 8
-	}
-
-	offsetMap[2] = sstOffset
-	sstOffset += int64(summaryBuf.Len())
-	b.Write(summaryBuf.Bytes())
-
-	filterBytes := bloom.Serialize()
-	offsetMap[3] = sstOffset
-	sstOffset += int64(len(filterBytes))
-	b.Write(filterBytes)
-
-	mt := merkletree.NewMerkleTree()
-	mt.ConstructMerkleTree(dataBuf.Bytes(), blockSize)
-	metadata := mt.Serialize()
-	offsetMap[4] = sstOffset
-	sstOffset += int64(len(metadata))
-	b.Write(metadata)
-	// Upisivanje indeksa kraja sadržaja bloka
-	offsetMap[5] = sstOffset
-
-	header := &bytes.Buffer{}
-	for _, off := range offsetMap {
-		binary.Write(header, binary.LittleEndian, uint64(off))
-	}
-
-	bytesToWrite := append(header.Bytes(), b.Bytes()...)
-
-	bm.Block_idx = 0
-	if err := writeBlocks(bm, sst.SingleFilePath, bytesToWrite, blockSize); err != nil {
-		return nil, "", err
-	}
-
-	sst.Filter = &bloom
-	sst.Metadata = &mt
-	return sst, sstDir, nil
+	return w.Finish()
 }
 
 // ReadRecordAtOffset čita kompletan Record iz Data fajla počevši od zadatog offseta.
@@ -455,7 +300,7 @@ func ReadRecordAtOffset(bm *blockmanager.BlockManager, path string, offs int64,
 		binary.Read(rdr, binary.LittleEndian, &rec.CRC)
 		rdr.Read(rec.Timestamp[:])
 		tomb, _ := rdr.ReadByte()
-		rec.Tombstone = tomb == 1
+		rec.setFlag(tomb)
 
 		offset := offs + 21
 
@@ -470,7 +315,7 @@ func ReadRecordAtOffset(bm *blockmanager.BlockManager, path string, offs int64,
 				return nil, 0, err
 			}
 			rec.Key = []byte(keyStr)
-			totalLen := int(rdr.Size()) + r.Len()
+			totalLen := int(rdr.Size()) + int(r.Size()) - r.Len()
 			return rec, totalLen, nil
 		} else {
 			buf, _ := readSegment(bm, path, offset, 20, blockSize)
@@ -506,7 +351,8 @@ func ReadRecordAtOffset(bm *blockmanager.BlockManager, path string, offs int64,
 		rec := &Record{}
 		binary.Read(rdr, binary.LittleEndian, &rec.CRC)
 		rdr.Read(rec.Timestamp[:])
-		binary.Read(rdr, binary.LittleEndian, &rec.Tombstone)
+		flag, _ := rdr.ReadByte()
+		rec.setFlag(flag)
 		binary.Read(rdr, binary.LittleEndian, &rec.KeySize)
 		binary.Read(rdr, binary.LittleEndian, &rec.ValueSize)
 
@@ -525,6 +371,63 @@ func ReadRecordAtOffset(bm *blockmanager.BlockManager, path string, offs int64,
 	}
 }
 
+// ReadRecordKeyAtOffset čita samo zaglavlje i ključ zapisa na offsetu, bez vrednosti.
+// Vraća i ukupnu dužinu zapisa; CRC se ne proverava jer se vrednost ne čita.
+func ReadRecordKeyAtOffset(bm *blockmanager.BlockManager, path string, offs int64, blockSize int, compress bool, dict *Dictionary) (*Record, int, error) {
+	if compress {
+		header, err := readSegment(bm, path, offs, 21, blockSize) // CRC (4) + TS (16) + tomb (1)
+		if err != nil {
+			return nil, 0, err
+		}
+		rdr := bytes.NewReader(header)
+		rec := &Record{}
+		binary.Read(rdr, binary.LittleEndian, &rec.CRC)
+		rdr.Read(rec.Timestamp[:])
+		tomb, _ := rdr.ReadByte()
+		rec.setFlag(tomb)
+
+		// ID ključa, a za žive zapise i dužina vrednosti
+		length := 20
+		if rec.Tombstone {
+			length = 10
+		}
+		buf, _ := readSegment(bm, path, offs+21, length, blockSize)
+		r := bytes.NewReader(buf)
+		keyId, _ := ReadUvarint(r)
+		if !rec.Tombstone {
+			valSize, _ := ReadUvarint(r)
+			rec.ValueSize = valSize
+		}
+		keyStr, err := dict.Lookup(keyId)
+		if err != nil {
+			return nil, 0, err
+		}
+		rec.Key = []byte(keyStr)
+		total := 21 + int(r.Size()) - r.Len() + int(rec.ValueSize)
+		return rec, total, nil
+	}
+	header, err := readSegment(bm, path, offs, 37, blockSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	rdr := bytes.NewReader(header)
+	rec := &Record{}
+	binary.Read(rdr, binary.LittleEndian, &rec.CRC)
+	rdr.Read(rec.Timestamp[:])
+	flag, _ := rdr.ReadByte()
+	rec.setFlag(flag)
+	binary.Read(rdr, binary.LittleEndian, &rec.KeySize)
+	binary.Read(rdr, binary.LittleEndian, &rec.ValueSize)
+
+	total := 37 + int(rec.KeySize) + int(rec.ValueSize)
+	key, err := readSegment(bm, path, offs+37, int(rec.KeySize), blockSize)
+	if err != nil {
+		return nil, total, err
+	}
+	rec.Key = append([]byte{}, key...)
+	return rec, total, nil
+}
+
 // ReadIndexBlock čita Index i parsira sve unose.
 func ReadIndexBlock(bm *blockmanager.BlockManager, path string, offs int64, length int64, blockSize int) ([]Index, error) {
 	buf, err := readSegment(bm, path, offs, int(length), blockSize)
@@ -677,16 +580,18 @@ func ValidateMerkleTree(bm *blockmanager.BlockManager, sst *SSTable, blockSize i
 	return diff, nil
 }
 
-// FindIndexBlockOffset traži offset Index bloka u Summary-ju za dati ključ.
+// FindIndexBlockOffset binarnom pretragom Summary-ja traži offset Index bloka za dati ključ
+// i vraća ga zajedno sa krajem bloka.
 func FindIndexBlockOffset(summary Summary, key []byte, indexBound int64) (int64, int64) {
+	if len(summary.Entries) == 0 {
+		return 0, indexBound
+	}
+	j := findSummaryEntry(&summary, key)
 	bound := indexBound
-	for i := len(summary.Entries) - 1; i >= 0; i-- {
-		if bytes.Compare(summary.Entries[i].Key, key) <= 0 {
-			return int64(summary.Entries[i].Offset), bound
-		}
-		bound = int64(summary.Entries[i].Offset)
+	if j+1 < len(summary.Entries) {
+		bound = int64(summary.Entries[j+1].Offset)
 	}
-	return 0, bound
+	return int64(summary.Entries[j].Offset), bound
 }
 
 // SearchMultiFile sprovodi standardni Bloom → Summary → Index → Data redosled.
@@ -699,26 +604,17 @@ func SearchMultiFile(bm *blockmanager.BlockManager, sst *SSTable, key []byte, su
 		return nil, 0, fmt.Errorf("key outside summary range")
 	}
 
-	indexInfo, err := os.Stat(sst.IndexFilePath)
+	layout, err := loadIndexLayout(sst, bm, blockSize)
 	if err != nil {
 		return nil, 0, err
 	}
-	idxOff, bound := FindIndexBlockOffset(summary, key, indexInfo.Size())
-	indexLen := bound - idxOff
-
-	indices, err := ReadIndexBlock(bm, sst.IndexFilePath, idxOff, indexLen, blockSize)
+	block, err := layout.readBlock(bm, &summary, findSummaryEntry(&summary, key), blockSize)
 	if err != nil {
 		return nil, 0, err
 	}
-
-	var dataOff uint64
-	found := false
-	for _, idx := range indices {
-		if bytes.Equal(idx.Key, key) {
-			dataOff = idx.Offset
-			found = true
-			break
-		}
+	dataOff, found, err := block.find(key)
+	if err != nil {
+		return nil, 0, err
 	}
 	if !found {
 		return nil, 0, fmt.Errorf("key not found in index")
@@ -727,21 +623,56 @@ func SearchMultiFile(bm *blockmanager.BlockManager, sst *SSTable, key []byte, su
 	return read, int(dataOff) + offset, err
 }
 
+// Redni brojevi delova Single File SSTabele. Header sadrži početak svakog dela redom,
+// a poslednji offset je kraj korisnih bajtova. Starije tabele imaju samo delove do Metadata.
+const (
+	sectionData = iota
+	sectionIndex
+	sectionSummary
+	sectionFilter
+	sectionMetadata
+	sectionPrefixFilter
+	sectionRangeFilter
+	sectionIndexOffsets
+	sectionRangeTombstones
+	sectionCount
+)
+
+// Veličina headera tabela sa samo osnovnim delovima
+const legacyHeaderSize = (sectionMetadata + 2) * 8
+
 // parseHeader čita header iz SSTable fajla i vraća offsete za Summary, Index,
-// Bloom filter, Merkle stablo i kraj korisnih bajtova
+// Bloom filter, Merkle stablo, dodatne delove i kraj korisnih bajtova
 func parseHeader(bm *blockmanager.BlockManager, path string, blockSize int) ([]int64, error) {
-	// Header zauzima prvih 48 bajtova Single File SSTabele
-	buf, err := readSegment(bm, path, 0, 48, blockSize)
+	// Data segment počinje odmah iza headera, pa je prvi offset ujedno i dužina headera
+	first, err := readSegment(bm, path, 0, 8, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	headerSize := int(binary.LittleEndian.Uint64(first))
+	if headerSize < legacyHeaderSize || headerSize%8 != 0 {
+		return nil, errors.New("neispravan header SSTabele")
+	}
+	buf, err := readSegment(bm, path, 0, headerSize, blockSize)
 	if err != nil {
 		return nil, err
 	}
-	offsets := make([]int64, 6)
-	for i := 0; i < 6; i++ {
+	offsets := make([]int64, headerSize/8)
+	for i := range offsets {
 		offsets[i] = int64(binary.LittleEndian.Uint64(buf[i*8 : (i+1)*8]))
 	}
 	return offsets, nil
 }
 
+// sectionBounds vraća početak i kraj dela tabele; false ako ga header ne navodi ili je prazan
+func sectionBounds(offsets []int64, section int) (int64, int64, bool) {
+	if section+1 >= len(offsets) {
+		return 0, 0, false
+	}
+	start, end := offsets[section], offsets[section+1]
+	return start, end, end > start
+}
+
 // LoadSummarySingleFile učitava Summary iz jednog SSTable fajla.
 func LoadSummarySingleFile(bm *blockmanager.BlockManager, path string, blockSize int, summaryOffset int64, nextOffset int64) (Summary, error) {
 	length := nextOffset - summaryOffset
@@ -814,7 +745,7 @@ func ReadRecordAtOffsetSingleFile(bm *blockmanager.BlockManager, path string, of
 		binary.Read(rdr, binary.LittleEndian, &rec.CRC)
 		rdr.Read(rec.Timestamp[:])
 		tomb, _ := rdr.ReadByte()
-		rec.Tombstone = tomb == 1
+		rec.setFlag(tomb)
 
 		currOffset := offset + 21
 
@@ -828,7 +759,7 @@ func ReadRecordAtOffsetSingleFile(bm *blockmanager.BlockManager, path string, of
 				return nil, 0, err
 			}
 			rec.Key = []byte(keyStr)
-			totalLen := int(rdr.Size()) + r.Len()
+			totalLen := int(rdr.Size()) + int(r.Size()) - r.Len()
 			return rec, totalLen, nil
 		} else {
 			// ID + ValueSize + Value
@@ -866,7 +797,8 @@ func ReadRecordAtOffsetSingleFile(bm *blockmanager.BlockManager, path string, of
 		rec := &Record{}
 		binary.Read(rdr, binary.LittleEndian, &rec.CRC)
 		rdr.Read(rec.Timestamp[:])
-		binary.Read(rdr, binary.LittleEndian, &rec.Tombstone)
+		flag, _ := rdr.ReadByte()
+		rec.setFlag(flag)
 		binary.Read(rdr, binary.LittleEndian, &rec.KeySize)
 		binary.Read(rdr, binary.LittleEndian, &rec.ValueSize)
 
@@ -938,23 +870,17 @@ func SearchSingleFile(bm *blockmanager.BlockManager, sst *SSTable, key []byte, b
 		return nil, 0, fmt.Errorf("key outside summary range")
 	}
 
-	idxOff, bound := FindIndexBlockOffset(summary, key, offsets[2])
-	indexLen := bound - idxOff
-	idxOff += offsets[1]
-
-	indices, err := ReadIndexBlockSingleFile(bm, sst.SingleFilePath, idxOff, indexLen, blockSize)
+	layout, err := loadIndexLayout(sst, bm, blockSize)
 	if err != nil {
 		return nil, 0, err
 	}
-
-	var dataOff uint64
-	found := false
-	for _, idx := range indices {
-		if bytes.Equal(idx.Key, key) {
-			dataOff = idx.Offset
-			found = true
-			break
-		}
+	block, err := layout.readBlock(bm, &summary, findSummaryEntry(&summary, key), blockSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	dataOff, found, err := block.find(key)
+	if err != nil {
+		return nil, 0, err
 	}
 	if !found {
 		return nil, 0, fmt.Errorf("key not found in index")
@@ -1,130 +1,344 @@
 package sstable
 
 import (
-	"fmt"
-	"os"
 	"projekat/structs/blockmanager"
 )
 
 type SSTableCursor struct {
 	bm          *blockmanager.BlockManager
 	sst         *SSTable
+	summary     *Summary
 	current     *Record
 	minKey      string
 	maxKey      string
-	offset      int
+	dataPath    string
+	dataStart   int64
+	index       indexLayout
+	offset      int64
 	blockSize   int
 	compression bool
 	dict        *Dictionary
+	exhausted   bool
+	// Value log za čitanje odvojenih vrednosti; kompakcija ga ne koristi pa kopira pokazivače
+	values *ValueLog
+	// U keysOnly režimu se čitaju samo ključevi, a vrednost trenutnog zapisa (na recordOffset)
+	// tek kada se zatraži
+	keysOnly     bool
+	partial      bool
+	recordOffset int64
+	// Deo indexa poslednje pretrage i položaji trenutnog i sledećeg zapisa u indexu: Prev ide
+	// unazad kroz isti deo indexa, a novi deo čita tek kada pređe granicu unosa summary-ja
+	block      indexBlock
+	blockEntry int
+	pos        indexPos
+	nextPos    indexPos
 }
 
-func NewCursor(bm *blockmanager.BlockManager, path string, minKey string, maxKey string, blockSize int, compression bool, dict *Dictionary) (SSTableCursor, error) {
+// indexPos je položaj zapisa u indexu: unos summary-ja i redni broj u delu indexa koji on
+// pokriva; entry -1 znači da položaj nije poznat
+type indexPos struct {
+	entry int
+	i     int
+}
+
+var unknownPos = indexPos{entry: -1}
+
+func NewCursor(bm *blockmanager.BlockManager, path string, minKey string, maxKey string, blockSize int, compression bool,
+	dict *Dictionary, values *ValueLog) (SSTableCursor, error) {
 	sst, err := ReadTableFromDir(path)
 	if err != nil {
-		fmt.Printf("Greška prilikom čitanja SSTable.")
+		return SSTableCursor{}, err
 	}
+	sc, err := newTableCursor(bm, sst, minKey, maxKey, blockSize, compression, dict)
+	sc.values = values
+	return sc, err
+}
+
+// newTableCursor otvara cursor nad već pročitanom SSTabelom
+func newTableCursor(bm *blockmanager.BlockManager, sst *SSTable, minKey string, maxKey string, blockSize int,
+	compression bool, dict *Dictionary) (SSTableCursor, error) {
 	sum, err := ReadSummaryFromTable(sst, bm, blockSize)
 	if err != nil {
-		fmt.Printf("Greška prilikom čitanja SSTable.")
+		return SSTableCursor{}, err
 	}
-	if string(sum.MaxKey) < minKey || string(sum.MinKey) > maxKey {
-
+	sc := SSTableCursor{
+		bm:          bm,
+		sst:         sst,
+		summary:     sum,
+		minKey:      minKey,
+		maxKey:      maxKey,
+		blockSize:   blockSize,
+		compression: compression,
+		dict:        dict,
+		blockEntry:  -1,
+		pos:         unknownPos,
+		nextPos:     unknownPos,
+	}
+	sc.index, err = loadIndexLayout(sst, bm, blockSize)
+	if err != nil {
+		return SSTableCursor{}, err
 	}
-	var indices []Index
-	var offset int
+	sc.dataPath, sc.dataStart = sst.DataFilePath, sc.index.dataStart
 	if sst.SingleSSTable {
-		offsets, err := parseHeader(bm, sst.SingleFilePath, blockSize)
-		if err != nil {
-			return SSTableCursor{}, err
-		}
+		sc.dataPath = sst.SingleFilePath
+	}
+	// Opseg tabele se ne preklapa sa traženim - cursor je odmah prazan
+	if string(sum.MaxKey) < minKey || (maxKey != "" && string(sum.MinKey) > maxKey) {
+		sc.exhausted = true
+		return sc, nil
+	}
+	sc.offset, sc.nextPos, err = sc.locate(minKey)
+	if err != nil {
+		return SSTableCursor{}, err
+	}
+	return sc, nil
+}
 
-		idxOff, bound := FindIndexBlockOffset(*sum, []byte(minKey), offsets[2])
-		indexLen := bound - idxOff
-		idxOff += offsets[1]
+// blockFor vraća deo indexa j-tog unosa summary-ja; poslednji pročitani deo se ne čita ponovo
+func (sc *SSTableCursor) blockFor(j int) (indexBlock, error) {
+	if sc.blockEntry == j {
+		return sc.block, nil
+	}
+	block, err := sc.index.readBlock(sc.bm, sc.summary, j, sc.blockSize)
+	if err != nil {
+		return indexBlock{}, err
+	}
+	sc.block, sc.blockEntry = block, j
+	return block, nil
+}
 
-		indices, err = ReadIndexBlockSingleFile(bm, sst.SingleFilePath, idxOff, indexLen, blockSize)
-		if err != nil {
-			return SSTableCursor{}, err
-		}
+// entryLen vraća broj unosa u delu indexa j-tog unosa summary-ja, ako je poznat bez čitanja
+func (sc *SSTableCursor) entryLen(j int) (int, bool) {
+	if sc.blockEntry == j {
+		return sc.block.n, true
+	}
+	l := sc.index
+	if l.offsetsPath != "" && l.step > 0 && j*l.step < l.count {
+		return min(l.step, l.count-j*l.step), true
+	}
+	return 0, false
+}
 
-	} else {
-		indexInfo, err := os.Stat(sst.IndexFilePath)
+// advance vraća položaj zapisa koji sledi iza zapisa na položaju p
+func (sc *SSTableCursor) advance(p indexPos) indexPos {
+	if p.entry < 0 {
+		return unknownPos
+	}
+	n, ok := sc.entryLen(p.entry)
+	if !ok {
+		return unknownPos
+	}
+	if p.i+1 < n {
+		return indexPos{p.entry, p.i + 1}
+	}
+	return indexPos{p.entry + 1, 0}
+}
+
+// locate vraća offset (u data segmentu) i položaj u indexu poslednjeg zapisa čiji je ključ
+// manji od traženog, odnosno prvog zapisa ukoliko takav ne postoji
+func (sc *SSTableCursor) locate(key string) (int64, indexPos, error) {
+	j := findSummaryEntry(sc.summary, []byte(key))
+	block, err := sc.blockFor(j)
+	if err != nil {
+		return 0, unknownPos, err
+	}
+	i, err := block.search([]byte(key))
+	if err != nil || block.n == 0 {
+		return sc.dataStart, unknownPos, err
+	}
+	p := indexPos{j, max(i-1, 0)}
+	e, err := block.entry(p.i)
+	if err != nil {
+		return 0, unknownPos, err
+	}
+	return sc.dataStart + int64(e.dataOffset), p, nil
+}
+
+// locateBefore vraća offset (u data segmentu) i položaj u indexu poslednjeg zapisa čiji je
+// ključ manji od traženog (ili jednak ako je inclusive); ok je false ako takav zapis ne postoji
+func (sc *SSTableCursor) locateBefore(key string, inclusive bool) (int64, indexPos, bool, error) {
+	j := findSummaryEntry(sc.summary, []byte(key))
+	block, err := sc.blockFor(j)
+	if err != nil {
+		return 0, unknownPos, false, err
+	}
+	i, err := block.search([]byte(key))
+	if err != nil {
+		return 0, unknownPos, false, err
+	}
+	if inclusive && i < block.n {
+		e, err := block.entry(i)
 		if err != nil {
-			return SSTableCursor{}, err
+			return 0, unknownPos, false, err
 		}
-		idxOff, bound := FindIndexBlockOffset(*sum, []byte(minKey), indexInfo.Size())
-		indexLen := bound - idxOff
-		indices, err = ReadIndexBlock(bm, sst.IndexFilePath, idxOff, indexLen, blockSize)
-		if err != nil {
-			return SSTableCursor{}, err
+		if string(e.key) == key {
+			i++
 		}
 	}
-	for _, rec := range indices {
-		if string(rec.Key) >= minKey {
-			break
+	return sc.entryBefore(indexPos{j, i})
+}
+
+// entryBefore vraća offset i položaj zapisa ispred položaja p; na početku dela indexa
+// prethodni zapis je poslednji u prethodnom delu
+func (sc *SSTableCursor) entryBefore(p indexPos) (int64, indexPos, bool, error) {
+	block, err := sc.blockFor(p.entry)
+	if err != nil {
+		return 0, unknownPos, false, err
+	}
+	if p.i == 0 {
+		if p.entry == 0 {
+			return 0, unknownPos, false, nil
 		}
-		offset = int(rec.Offset)
+		p.entry--
+		block, err = sc.blockFor(p.entry)
+		if err != nil || block.n == 0 {
+			return 0, unknownPos, false, err
+		}
+		p.i = block.n
 	}
-	// Trebamo biti na početku Data segmenta - preskačemo header u SingleFileSSTable
-	if sst.SingleSSTable {
-		offset += 48
+	p.i--
+	e, err := block.entry(p.i)
+	if err != nil {
+		return 0, unknownPos, false, err
 	}
-	return SSTableCursor{
-		bm:          bm,
-		sst:         sst,
-		current:     nil,
-		minKey:      minKey,
-		maxKey:      maxKey,
-		offset:      offset,
-		blockSize:   blockSize,
-		compression: compression,
-		dict:        dict,
-	}, nil
+	return sc.dataStart + int64(e.dataOffset), p, true, nil
 }
 
-// SSTableCursor se inicijalizuje s vrednošču koja ili pripada opsegu ili je prva pre opsega
-func (sc *SSTableCursor) Seek(seekKey string) bool {
-	var offsetDelta int
-	var err error
-	if sc.sst.SingleSSTable {
-		sc.current, offsetDelta, err = ReadRecordAtOffsetSingleFile(sc.bm, sc.sst.SingleFilePath, int64(sc.offset), sc.blockSize, sc.compression, sc.dict)
-		if err != nil {
-			return false
-		}
-	} else {
-		sc.current, offsetDelta, err = ReadRecordAtOffset(sc.bm, sc.sst.DataFilePath, int64(sc.offset), sc.blockSize, sc.compression, sc.dict)
-		if err != nil {
-			return false
-		}
+// readAt čita zapis na zadatom offsetu i položaju u indexu i proverava da li je u opsegu cursora
+func (sc *SSTableCursor) readAt(offset int64, p indexPos) bool {
+	sc.offset = offset
+	sc.nextPos = p
+	sc.exhausted = false
+	if !sc.readNext() {
+		return false
 	}
 	if string(sc.current.Key) < sc.minKey {
-		sc.offset += offsetDelta
+		sc.current = nil
+		return false
+	}
+	return sc.inRange()
+}
+
+// readNext čita zapis na trenutnom offsetu i pomera offset iza njega
+func (sc *SSTableCursor) readNext() bool {
+	if sc.exhausted {
+		sc.current = nil
+		return false
+	}
+	read := ReadRecordAtOffset
+	if sc.keysOnly {
+		read = ReadRecordKeyAtOffset
+	}
+	rec, length, err := read(sc.bm, sc.dataPath, sc.offset, sc.blockSize, sc.compression, sc.dict)
+	if err != nil {
+		sc.current = nil
+		sc.exhausted = true
+		return false
+	}
+	sc.current = rec
+	sc.partial = sc.keysOnly && !rec.Tombstone
+	sc.recordOffset = sc.offset
+	sc.offset += int64(length)
+	sc.pos = sc.nextPos
+	sc.nextPos = sc.advance(sc.pos)
+	// Poslednji ključ u tabeli - posle njega data segment je završen
+	if string(rec.Key) == string(sc.summary.MaxKey) {
+		sc.exhausted = true
 	}
 	return true
+}
 
+// Seek pozicionira cursor na prvi zapis čiji je ključ >= seekKey
+func (sc *SSTableCursor) Seek(seekKey string) bool {
+	if sc.sst == nil || string(sc.summary.MaxKey) < seekKey {
+		sc.current = nil
+		sc.exhausted = true
+		return false
+	}
+	offset, p, err := sc.locate(seekKey)
+	if err != nil {
+		sc.current = nil
+		return false
+	}
+	sc.offset = offset
+	sc.nextPos = p
+	sc.exhausted = false
+	for sc.readNext() {
+		if string(sc.current.Key) >= seekKey {
+			return sc.inRange()
+		}
+	}
+	return false
 }
 
-func (sc *SSTableCursor) Next() bool {
-	var offsetDelta int
+// SeekForPrev pozicionira cursor na poslednji zapis čiji je ključ <= seekKey
+func (sc *SSTableCursor) SeekForPrev(seekKey string) bool {
+	if sc.sst == nil || string(sc.summary.MinKey) > seekKey {
+		sc.current = nil
+		return false
+	}
+	if sc.maxKey != "" && seekKey > sc.maxKey {
+		seekKey = sc.maxKey
+	}
+	offset, p, ok, err := sc.locateBefore(seekKey, true)
+	if err != nil || !ok {
+		sc.current = nil
+		return false
+	}
+	return sc.readAt(offset, p)
+}
+
+// Prev pomera cursor na prethodni zapis u opsegu. Kada je položaj trenutnog zapisa u indexu
+// poznat, prethodni se uzima iz istog dela indexa bez nove pretrage.
+func (sc *SSTableCursor) Prev() bool {
+	if sc.sst == nil || sc.current == nil {
+		return false
+	}
+	var offset int64
+	var p indexPos
+	var ok bool
 	var err error
-	if sc.sst.SingleSSTable {
-		sc.current, offsetDelta, err = ReadRecordAtOffsetSingleFile(sc.bm, sc.sst.SingleFilePath, int64(sc.offset), sc.blockSize, sc.compression, sc.dict)
-		if err != nil {
-			return false
-		}
+	if sc.pos.entry >= 0 {
+		offset, p, ok, err = sc.entryBefore(sc.pos)
 	} else {
-		sc.current, offsetDelta, err = ReadRecordAtOffset(sc.bm, sc.sst.DataFilePath, int64(sc.offset), sc.blockSize, sc.compression, sc.dict)
-		if err != nil {
-			return false
-		}
+		offset, p, ok, err = sc.locateBefore(string(sc.current.Key), false)
+	}
+	if err != nil || !ok {
+		sc.current = nil
+		return false
+	}
+	return sc.readAt(offset, p)
+}
+
+// Next pomera cursor na sledeći zapis u opsegu
+func (sc *SSTableCursor) Next() bool {
+	if sc.sst == nil || !sc.readNext() {
+		return false
 	}
-	if string(sc.current.Key) > sc.maxKey {
+	return sc.inRange()
+}
+
+// inRange poništava trenutni zapis ukoliko je izašao iz opsega cursora
+func (sc *SSTableCursor) inRange() bool {
+	if sc.maxKey != "" && string(sc.current.Key) > sc.maxKey {
+		sc.current = nil
+		sc.exhausted = true
 		return false
 	}
-	sc.offset += offsetDelta
 	return true
 }
 
+// SetKeysOnly uključuje čitanje samo ključeva; vrednosti se tada čitaju iz data segmenta
+// samo za zapise za koje se pozove Value
+func (sc *SSTableCursor) SetKeysOnly(keysOnly bool) {
+	sc.keysOnly = keysOnly
+}
+
+// Record vraća trenutni zapis
+func (sc *SSTableCursor) Record() *Record {
+	return sc.current
+}
+
 func (sc *SSTableCursor) Key() string {
 	if sc.current == nil {
 		return ""
@@ -132,10 +346,25 @@ func (sc *SSTableCursor) Key() string {
 	return string(sc.current.Key)
 }
 
+// Value vraća vrednost trenutnog zapisa; odvojena vrednost se čita iz value log-a tek kada se zatraži
 func (sc *SSTableCursor) Value() []byte {
 	if sc.current == nil {
 		return nil
 	}
+	if sc.partial {
+		rec, _, err := ReadRecordAtOffset(sc.bm, sc.dataPath, sc.recordOffset, sc.blockSize, sc.compression, sc.dict)
+		if err != nil {
+			return nil
+		}
+		sc.current, sc.partial = rec, false
+	}
+	if sc.current.Separated {
+		rec, err := sc.values.Resolve(sc.current)
+		if err != nil {
+			return nil
+		}
+		sc.current = rec
+	}
 	return sc.current.Value
 }
 
@@ -0,0 +1,90 @@
+package sstable
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"projekat/structs/blockmanager"
+)
+
+// Prev ide unazad kroz deo indexa trenutnog zapisa, a novi deo čita samo na granici unosa
+// summary-ja; nasumično kretanje napred i nazad mora da prati redosled ključeva u tabeli,
+// i kod starijih tabela bez niza offseta
+func TestSSTableCursorPrevWithinIndexBlock(t *testing.T) {
+	records := testRecords(1500)
+	for _, single := range []bool{false, true} {
+		for _, step := range []int{4, 64} {
+			for _, legacy := range []bool{false, true} {
+				name := fmt.Sprintf("single=%v, korak %d, bez niza offseta %v", single, step, legacy)
+				dir := t.TempDir()
+				bm := blockmanager.NewBlockManager(testBlockSize, 64)
+				dict := NewDictionary()
+				_, sstDir, err := CreateSSTable(records, nil, dir, step, bm, testBlockSize, 0, single, false, dict,
+					filepath.Join(dir, "dict.db"), FilterPolicy{LevelFPRates: []float64{0.01}})
+				if err != nil {
+					t.Fatal(err)
+				}
+				c, err := NewCursor(bm, sstDir, "", "\xff", testBlockSize, false, dict, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if legacy {
+					c.index.offsetsPath = ""
+					c.blockEntry = -1
+				}
+
+				// Unazad od poslednjeg zapisa; deo indexa se menja samo na granici unosa summary-ja
+				loads, entry := 0, -1
+				i := len(records) - 1
+				for ok := c.SeekForPrev("\xff"); ok; ok = c.Prev() {
+					if c.Key() != string(records[i].Key) {
+						t.Fatalf("%s: Prev vratio %s, očekivano %s", name, c.Key(), records[i].Key)
+					}
+					if c.blockEntry != entry {
+						loads, entry = loads+1, c.blockEntry
+					}
+					i--
+				}
+				if i != -1 {
+					t.Fatalf("%s: Prev stao %d zapisa pre početka", name, i+1)
+				}
+				if loads > len(c.summary.Entries) {
+					t.Fatalf("%s: %d čitanja dela indexa za %d unosa summary-ja", name, loads, len(c.summary.Entries))
+				}
+
+				// Nasumična promena smera
+				r := rand.New(rand.NewSource(1))
+				i = 700
+				if !c.Seek(string(records[i].Key)) {
+					t.Fatalf("%s: Seek nije pronašao %s", name, records[i].Key)
+				}
+				for n := 0; n < 2000; n++ {
+					forward := r.Intn(2) == 0
+					var ok bool
+					if forward {
+						ok, i = c.Next(), i+1
+					} else {
+						ok, i = c.Prev(), i-1
+					}
+					if i < 0 || i >= len(records) {
+						if ok {
+							t.Fatalf("%s: cursor prošao kraj tabele na %s", name, c.Key())
+						}
+						// Na kraju tabele cursor se vraća pretragom
+						i = r.Intn(len(records))
+						if !c.Seek(string(records[i].Key)) {
+							t.Fatalf("%s: Seek nije pronašao %s", name, records[i].Key)
+						}
+						continue
+					}
+					if !ok || c.Key() != string(records[i].Key) {
+						t.Fatalf("%s: korak %d (napred %v) vratio %s, očekivano %s", name, n, forward, c.Key(), records[i].Key)
+					}
+				}
+				c.Close()
+			}
+		}
+	}
+}
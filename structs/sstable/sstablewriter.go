@@ -0,0 +1,533 @@
+package sstable
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"projekat/config"
+	"projekat/structs/blockmanager"
+	"projekat/structs/merkletree"
+	"projekat/structs/probabilistic"
+)
+
+// Veličina headera nove SSTabele u jednom fajlu (početak svakog dela i kraj, po 8 bajtova)
+const singleFileHeaderSize = (sectionCount + 1) * 8
+
+// blockStream upisuje bajtove u fajl blok po blok preko BlockManager-a.
+// Pamti sopstveni indeks bloka jer se isti BlockManager koristi i za druge fajlove
+// (npr. rečnik) dok upis traje.
+type blockStream struct {
+	bm        *blockmanager.BlockManager
+	path      string
+	blockSize int
+	blockIdx  int
+	buf       []byte
+	head      []byte
+}
+
+func newBlockStream(bm *blockmanager.BlockManager, path string, blockSize int) *blockStream {
+	return &blockStream{bm: bm, path: path, blockSize: blockSize, buf: make([]byte, 0, blockSize)}
+}
+
+// Write dodaje bajtove u bafer i zapisuje svaki popunjeni blok
+func (s *blockStream) Write(p []byte) error {
+	for len(p) > 0 {
+		n := min(s.blockSize-len(s.buf), len(p))
+		s.buf = append(s.buf, p[:n]...)
+		p = p[n:]
+		if len(s.buf) == s.blockSize {
+			if err := s.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *blockStream) flush() error {
+	if s.blockIdx == 0 {
+		s.head = append([]byte{}, s.buf...)
+	}
+	if err := s.bm.WriteBlockAt(s.path, s.blockIdx, s.buf); err != nil {
+		return err
+	}
+	s.blockIdx++
+	s.buf = s.buf[:0]
+	return nil
+}
+
+// Close zapisuje poslednji, delimično popunjen blok
+func (s *blockStream) Close() error {
+	if len(s.buf) == 0 {
+		return nil
+	}
+	return s.flush()
+}
+
+// rewriteHead prepisuje početak prvog bloka (koristi se za header SSTabele u jednom fajlu)
+func (s *blockStream) rewriteHead(p []byte) error {
+	copy(s.head, p)
+	return s.bm.WriteBlockAt(s.path, 0, s.head)
+}
+
+// spillFile čuva deo tabele koji nastaje tokom upisa (index, summary, ...) u privremenom fajlu,
+// pa memorija writer-a ne raste sa brojem zapisa. Fajl je van foldera tabele, jer se tabela
+// u jednom fajlu prepoznaje po tome što je fajl u folderu jedini.
+type spillFile struct {
+	file *os.File
+	w    *bufio.Writer
+	size int64
+}
+
+func newSpillFile() (*spillFile, error) {
+	f, err := os.CreateTemp("", "sstable-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &spillFile{file: f, w: bufio.NewWriter(f)}, nil
+}
+
+func (s *spillFile) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	s.size += int64(n)
+	return n, err
+}
+
+// reader vraća čitač sadržaja od početka
+func (s *spillFile) reader() (*bufio.Reader, error) {
+	if err := s.w.Flush(); err != nil {
+		return nil, err
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return bufio.NewReader(s.file), nil
+}
+
+// remove zatvara i briše privremeni fajl
+func (s *spillFile) remove() {
+	s.file.Close()
+	os.Remove(s.file.Name())
+}
+
+// sectionPart je deo sekcije tabele - bajtovi iz memorije ili sadržaj privremenog fajla
+type sectionPart struct {
+	buf   []byte
+	spill *spillFile
+}
+
+func sectionSize(parts []sectionPart) int64 {
+	size := int64(0)
+	for _, p := range parts {
+		if p.spill != nil {
+			size += p.spill.size
+		} else {
+			size += int64(len(p.buf))
+		}
+	}
+	return size
+}
+
+// writeSection upisuje delove sekcije redom, privremene fajlove blok po blok
+func writeSection(dst *blockStream, parts []sectionPart) error {
+	for _, p := range parts {
+		if p.spill == nil {
+			if err := dst.Write(p.buf); err != nil {
+				return err
+			}
+			continue
+		}
+		rdr, err := p.spill.reader()
+		if err != nil {
+			return err
+		}
+		chunk := make([]byte, dst.blockSize)
+		for {
+			n, err := io.ReadFull(rdr, chunk)
+			if n > 0 {
+				if err := dst.Write(chunk[:n]); err != nil {
+					return err
+				}
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// FilterPolicy određuje parametre Bloom filtera nove tabele. Stopa lažno pozitivnih
+// rezultata se bira po nivou (poslednja vrednost važi i za sve dublje nivoe), a ako je
+// BitsPerKey > 0, veličina filtera se zadaje brojem bitova po ključu.
+type FilterPolicy struct {
+	LevelFPRates []float64
+	BitsPerKey   int
+	Prefix       PrefixExtractor
+	// Dužina prefiksa u filteru opsega; 0 isključuje filter opsega
+	RangePrefixLength int
+}
+
+// Podrazumevana stopa lažno pozitivnih rezultata ako nije zadata u konfiguraciji
+const defaultFPRate = 0.01
+
+func NewFilterPolicy(cfg config.Config) FilterPolicy {
+	return FilterPolicy{LevelFPRates: cfg.BloomFPRates, BitsPerKey: cfg.BloomBitsPerKey, Prefix: NewPrefixExtractor(cfg),
+		RangePrefixLength: cfg.RangeFilterPrefixLength}
+}
+
+// newFilter pravi prazan filter za tabelu sa count zapisa na datom nivou. Konfiguracija
+// se proverava pri učitavanju; stopa van (0, 1) ovde se zamenjuje podrazumevanom.
+func (fp FilterPolicy) newFilter(count int, level byte) probabilistic.BloomFilter {
+	if fp.BitsPerKey > 0 {
+		return probabilistic.CreateDoubleHashBFBitsPerKey(count, fp.BitsPerKey)
+	}
+	rate := defaultFPRate
+	if len(fp.LevelFPRates) > 0 {
+		rate = fp.LevelFPRates[min(int(level), len(fp.LevelFPRates)-1)]
+	}
+	if !(rate > 0 && rate < 1) {
+		rate = defaultFPRate
+	}
+	return probabilistic.CreateDoubleHashBF(count, rate)
+}
+
+// SSTableWriter formira SSTabelu inkrementalno - data segment se upisuje na disk
+// čim se popuni blok, a index, pomeraji indexa, summary, prefiksi i filter opsega se
+// upisuju u privremene fajlove dok zapisi stižu. U memoriji ostaju samo poslednji blok i,
+// pri završetku, Bloom filteri (nekoliko bitova po ključu).
+type SSTableWriter struct {
+	bm        *blockmanager.BlockManager
+	blockSize int
+	step      int
+	lsm       byte
+	single    bool
+	compress  bool
+	dict      *Dictionary
+	dictPath  string
+	filter    FilterPolicy
+
+	sst    *SSTable
+	sstDir string
+
+	data     *blockStream
+	dataSize uint64
+	leaves   *merkletree.LeafHasher
+
+	index        *spillFile // [dužina ključa][ključ][pomeraj u data segmentu] za svaki zapis
+	indexOffsets *spillFile // Pomeraj svakog unosa indexa
+	summary      *spillFile // Unosi summary-ja (svaki step-ti unos indexa)
+	summaryCount int
+	count        int
+	minKey       []byte
+	maxKey       []byte
+	// Različiti prefiksi ključeva (ključevi stižu sortirani, pa su isti prefiksi uzastopni)
+	prefixes    *spillFile
+	prefixCount int
+	lastPrefix  []byte
+	// Filter opsega; nil ako je isključen
+	ranges *rangeFilterBuilder
+	// Brisanja opsega koja se čuvaju u tabeli
+	rangeTombstones []RangeTombstone
+}
+
+// NewSSTableWriter kreira folder nove SSTabele i priprema upis
+func NewSSTableWriter(dir string, step int, bm *blockmanager.BlockManager, blockSize int,
+	lsm byte, singleFile bool, compress bool, dict *Dictionary, dictPath string, filter FilterPolicy) (*SSTableWriter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	timestamp := time.Now().UnixNano()
+	sstDir := filepath.Join(dir, fmt.Sprintf("%d-sstable", timestamp))
+	// Više tabela se može kreirati u istoj nanosekundi (kompakcija, flush u pozadini), pa se
+	// folder zauzima atomično pomoću Mkdir
+	for {
+		err := os.Mkdir(sstDir, 0755)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		timestamp++
+		sstDir = filepath.Join(dir, fmt.Sprintf("%d-sstable", timestamp))
+	}
+
+	w := &SSTableWriter{
+		bm:        bm,
+		blockSize: blockSize,
+		step:      step,
+		lsm:       lsm,
+		single:    singleFile,
+		compress:  compress,
+		dict:      dict,
+		dictPath:  dictPath,
+		filter:    filter,
+		sstDir:    sstDir,
+		leaves:    merkletree.NewLeafHasher(blockSize),
+	}
+	spills := []**spillFile{&w.index, &w.indexOffsets, &w.summary, &w.prefixes}
+	if filter.RangePrefixLength > 0 {
+		w.ranges = newRangeFilterBuilder(filter.RangePrefixLength)
+		spills = append(spills, &w.ranges.out)
+	}
+	for _, sp := range spills {
+		f, err := newSpillFile()
+		if err != nil {
+			w.Abort()
+			return nil, err
+		}
+		*sp = f
+	}
+	if singleFile {
+		w.sst = NewSingleFileSSTable(sstDir, timestamp)
+		w.data = newBlockStream(bm, w.sst.SingleFilePath, blockSize)
+		// Mesto za header koji se upisuje na kraju
+		if err := w.data.Write(make([]byte, singleFileHeaderSize)); err != nil {
+			w.Abort()
+			return nil, err
+		}
+	} else {
+		w.sst = NewMultiFileSSTable(sstDir, timestamp)
+		w.data = newBlockStream(bm, w.sst.DataFilePath, blockSize)
+	}
+	return w, nil
+}
+
+// Add upisuje sledeći zapis; zapisi moraju stizati sortirani po ključu
+func (w *SSTableWriter) Add(rec Record) error {
+	rec.KeySize = uint64(len(rec.Key))
+	rec.ValueSize = uint64(len(rec.Value))
+	rec.CRC = calculateCRC(rec)
+	rb := recordBytes(rec, w.dict.GetID(string(rec.Key), w.bm, w.dictPath, w.blockSize), w.compress)
+
+	// Summary pokazuje na svaki step-ti zapis u indexu
+	indexOffset := uint64(w.index.size)
+	if w.count%w.step == 0 {
+		binary.Write(w.summary, binary.LittleEndian, rec.KeySize)
+		w.summary.Write(rec.Key)
+		binary.Write(w.summary, binary.LittleEndian, indexOffset)
+		w.summaryCount++
+	}
+	binary.Write(w.indexOffsets, binary.LittleEndian, indexOffset)
+	binary.Write(w.index, binary.LittleEndian, rec.KeySize)
+	w.index.Write(rec.Key)
+	binary.Write(w.index, binary.LittleEndian, w.dataSize)
+
+	if err := w.data.Write(rb); err != nil {
+		return err
+	}
+	w.leaves.Write(rb)
+	w.dataSize += uint64(len(rb))
+
+	if w.filter.Prefix.Enabled() {
+		p, ok := w.filter.Prefix.Extract(rec.Key)
+		if ok && (w.prefixCount == 0 || !bytes.Equal(w.lastPrefix, p)) {
+			binary.Write(w.prefixes, binary.LittleEndian, uint64(len(p)))
+			w.prefixes.Write(p)
+			w.lastPrefix = append(w.lastPrefix[:0], p...)
+			w.prefixCount++
+		}
+	}
+
+	if w.ranges != nil {
+		if err := w.ranges.add(rec.Key); err != nil {
+			return err
+		}
+	}
+
+	if w.count == 0 {
+		w.minKey = append([]byte{}, rec.Key...)
+	}
+	w.maxKey = append(w.maxKey[:0], rec.Key...)
+	w.count++
+	return nil
+}
+
+// AddRangeTombstones dodaje brisanja opsega; ona ne utiču na redosled zapisa
+func (w *SSTableWriter) AddRangeTombstones(rts []RangeTombstone) {
+	w.rangeTombstones = append(w.rangeTombstones, rts...)
+}
+
+// DataSize vraća broj bajtova data segmenta upisanih do sada
+func (w *SSTableWriter) DataSize() int64 {
+	return int64(w.dataSize)
+}
+
+// Count vraća broj upisanih zapisa
+func (w *SSTableWriter) Count() int {
+	return w.count
+}
+
+// Abort briše delimično upisanu SSTabelu
+func (w *SSTableWriter) Abort() {
+	w.removeSpills()
+	os.RemoveAll(w.sstDir)
+}
+
+// removeSpills briše privremene fajlove
+func (w *SSTableWriter) removeSpills() {
+	for _, sp := range []*spillFile{w.index, w.indexOffsets, w.summary, w.prefixes} {
+		if sp != nil {
+			sp.remove()
+		}
+	}
+	if w.ranges != nil {
+		w.ranges.remove()
+	}
+}
+
+// readKeys čita ključeve iz privremenog fajla sa unosima [dužina][ključ] i, ako je
+// trailer > 0, još toliko bajtova posle svakog ključa
+func readKeys(sp *spillFile, trailer int, fn func(key []byte)) error {
+	rdr, err := sp.reader()
+	if err != nil {
+		return err
+	}
+	var key []byte
+	skip := make([]byte, trailer)
+	for {
+		var size uint64
+		if err := binary.Read(rdr, binary.LittleEndian, &size); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if uint64(cap(key)) < size {
+			key = make([]byte, size)
+		}
+		key = key[:size]
+		if _, err := io.ReadFull(rdr, key); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(rdr, skip); err != nil {
+			return err
+		}
+		fn(key)
+	}
+}
+
+// Finish upisuje index, summary, filter i metadata i vraća gotovu SSTabelu
+func (w *SSTableWriter) Finish() (*SSTable, string, error) {
+	if w.count == 0 {
+		w.Abort()
+		return nil, "", errors.New("no records to create SSTable")
+	}
+	sst, err := w.finish()
+	if err != nil {
+		w.Abort()
+		return nil, "", err
+	}
+	w.removeSpills()
+	return sst, w.sstDir, nil
+}
+
+func (w *SSTableWriter) finish() (*SSTable, error) {
+	// Bloom filter se pravi tek sada kada je poznat broj zapisa - ključevi se čitaju iz indexa
+	bloom := w.filter.newFilter(w.count, w.lsm)
+	if err := readKeys(w.index, 8, func(key []byte) { bloom.AddElement(string(key)) }); err != nil {
+		return nil, err
+	}
+
+	summaryHeader := &bytes.Buffer{}
+	binary.Write(summaryHeader, binary.LittleEndian, w.lsm)
+	binary.Write(summaryHeader, binary.LittleEndian, uint64(len(w.minKey)))
+	summaryHeader.Write(w.minKey)
+	binary.Write(summaryHeader, binary.LittleEndian, uint64(len(w.maxKey)))
+	summaryHeader.Write(w.maxKey)
+	binary.Write(summaryHeader, binary.LittleEndian, uint64(w.summaryCount))
+
+	filterBytes := bloom.Serialize()
+	mt := w.leaves.Finish()
+	metadata := mt.Serialize()
+
+	var prefixBytes []byte
+	if w.prefixCount > 0 {
+		prefixBloom := w.filter.newFilter(w.prefixCount, w.lsm)
+		if err := readKeys(w.prefixes, 0, func(p []byte) { prefixBloom.AddElement(string(p)) }); err != nil {
+			return nil, err
+		}
+		prefixBytes = encodePrefixFilter(w.filter.Prefix, &prefixBloom)
+	}
+	rangeFilter := []sectionPart{}
+	if w.ranges != nil {
+		var err error
+		if rangeFilter, err = w.ranges.sections(); err != nil {
+			return nil, err
+		}
+	}
+	indexOffsetsHeader := make([]byte, 0, indexOffsetsHeaderSize)
+	indexOffsetsHeader = binary.LittleEndian.AppendUint64(indexOffsetsHeader, uint64(w.step))
+	indexOffsetsHeader = binary.LittleEndian.AppendUint64(indexOffsetsHeader, uint64(w.count))
+	rangeTombstoneBytes := encodeRangeTombstones(w.rangeTombstones)
+
+	// Redosled sekcija posle data segmenta
+	sections := [][]sectionPart{
+		{{spill: w.index}},
+		{{buf: summaryHeader.Bytes()}, {spill: w.summary}},
+		{{buf: filterBytes}},
+		{{buf: metadata}},
+		{{buf: prefixBytes}},
+		rangeFilter,
+		{{buf: indexOffsetsHeader}, {spill: w.indexOffsets}},
+		{{buf: rangeTombstoneBytes}},
+	}
+
+	if w.single {
+		// Ostali delovi se nastavljaju odmah iza data segmenta u istom fajlu
+		offsetMap := make([]int64, sectionCount+1)
+		offsetMap[sectionData] = singleFileHeaderSize
+		offsetMap[sectionIndex] = offsetMap[sectionData] + int64(w.dataSize)
+		for i, section := range sections {
+			offsetMap[sectionIndex+i+1] = offsetMap[sectionIndex+i] + sectionSize(section)
+		}
+		for _, section := range sections {
+			if err := writeSection(w.data, section); err != nil {
+				return nil, err
+			}
+		}
+		if err := w.data.Close(); err != nil {
+			return nil, err
+		}
+		header := &bytes.Buffer{}
+		for _, off := range offsetMap {
+			binary.Write(header, binary.LittleEndian, uint64(off))
+		}
+		if err := w.data.rewriteHead(header.Bytes()); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := w.data.Close(); err != nil {
+			return nil, err
+		}
+		paths := []string{w.sst.IndexFilePath, w.sst.SummaryFilePath, w.sst.FilterFilePath, w.sst.MetadataFilePath,
+			w.sst.PrefixFilterFilePath, w.sst.RangeFilterFilePath, w.sst.IndexOffsetsFilePath, w.sst.RangeTombstonesFilePath}
+		for i, section := range sections {
+			// Opcioni delovi se ne zapisuju ako su prazni
+			if sectionSize(section) == 0 {
+				continue
+			}
+			out := newBlockStream(w.bm, paths[i], w.blockSize)
+			if err := writeSection(out, section); err != nil {
+				return nil, err
+			}
+			if err := out.Close(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	w.sst.Filter = &bloom
+	w.sst.Metadata = &mt
+	return w.sst, nil
+}
@@ -0,0 +1,93 @@
+package sstable
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"projekat/config"
+	"projekat/structs/blockmanager"
+)
+
+func testRecords(n int) []Record {
+	records := make([]Record, 0, n)
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("user:%03d:%05d", i%700, i))
+		records = append(records, Record{Key: key, Value: []byte(fmt.Sprint("vrednost-", i)), Timestamp: [16]byte{byte(i), byte(i >> 8)}})
+	}
+	sort.Slice(records, func(i, j int) bool { return string(records[i].Key) < string(records[j].Key) })
+	return records
+}
+
+// Writer upisuje index, summary i filtere preko privremenih fajlova; tabela mora biti ista
+// kao da je formirana u memoriji, a privremeni fajlovi obrisani
+func TestSSTableWriterRoundTrip(t *testing.T) {
+	records := testRecords(3000)
+	filter := FilterPolicy{LevelFPRates: []float64{0.01}, RangePrefixLength: 6,
+		Prefix: NewPrefixExtractor(config.Config{PrefixExtractor: "Fixed", PrefixLength: 8})}
+	for _, single := range []bool{false, true} {
+		t.Run(fmt.Sprintf("single=%v", single), func(t *testing.T) {
+			tmp := t.TempDir()
+			t.Setenv("TMPDIR", tmp)
+			dir := t.TempDir()
+			bm := blockmanager.NewBlockManager(testBlockSize, 64)
+			dict := NewDictionary()
+			_, sstDir, err := CreateSSTable(records, nil, dir, 10, bm, testBlockSize, 0, single, false, dict,
+				filepath.Join(dir, "dict.db"), filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if left, _ := os.ReadDir(tmp); len(left) != 0 {
+				t.Fatalf("privremeni fajlovi nisu obrisani: %v", left)
+			}
+
+			reader, err := OpenTableReader(sstDir, bm, testBlockSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(reader.Summary.MinKey) != string(records[0].Key) || string(reader.Summary.MaxKey) != string(records[len(records)-1].Key) {
+				t.Fatalf("pogrešan opseg summary-ja: %s - %s", reader.Summary.MinKey, reader.Summary.MaxKey)
+			}
+			for _, rec := range records {
+				found, ok, err := reader.Search(rec.Key, bm, testBlockSize, false, dict)
+				if err != nil || !ok || string(found.Value) != string(rec.Value) {
+					t.Fatalf("ključ %s: pronađen=%v greška=%v", rec.Key, ok, err)
+				}
+				if !reader.MayContainPrefix(string(rec.Key[:8])) {
+					t.Fatalf("prefiksni filter odbacuje %s", rec.Key[:8])
+				}
+				if !reader.MayContainRange(string(rec.Key), string(rec.Key)) {
+					t.Fatalf("filter opsega odbacuje %s", rec.Key)
+				}
+			}
+			if _, ok, _ := reader.Search([]byte("user:000:99999"), bm, testBlockSize, false, dict); ok {
+				t.Fatal("pronađen ključ koji nije upisan")
+			}
+
+			cursor, err := NewCursor(bm, sstDir, "", "\xff", testBlockSize, false, dict, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			i := 0
+			for ok := cursor.Seek(""); ok; ok = cursor.Next() {
+				if cursor.Key() != string(records[i].Key) {
+					t.Fatalf("cursor na poziciji %d: %s, očekivano %s", i, cursor.Key(), records[i].Key)
+				}
+				i++
+			}
+			if i != len(records) {
+				t.Fatalf("cursor je obišao %d zapisa, očekivano %d", i, len(records))
+			}
+
+			// Metadata se računa tokom upisa; u jednom fajlu se data segment čita bez dopune bloka
+			if single {
+				corrupt, err := ValidateMerkleTree(bm, reader.Table, testBlockSize)
+				if err != nil || len(corrupt) != 0 {
+					t.Fatalf("Merkle provera: %v %v", corrupt, err)
+				}
+			}
+		})
+	}
+}
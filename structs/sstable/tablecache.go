@@ -0,0 +1,288 @@
+package sstable
+
+import (
+	"bytes"
+	"container/list"
+	"sync"
+
+	"projekat/structs/blockmanager"
+	"projekat/structs/probabilistic"
+)
+
+// FilterStats broji ishode provera Bloom filtera jedne tabele
+type FilterStats struct {
+	Probes         uint64
+	Negatives      uint64
+	TruePositives  uint64
+	FalsePositives uint64
+}
+
+// FalsePositiveRate vraća izmerenu stopu lažno pozitivnih rezultata
+func (fs FilterStats) FalsePositiveRate() float64 {
+	if fs.FalsePositives+fs.Negatives == 0 {
+		return 0
+	}
+	return float64(fs.FalsePositives) / float64(fs.FalsePositives+fs.Negatives)
+}
+
+// TableReader drži delove SSTabele potrebne za pretragu (header, summary i Bloom filter)
+// učitane u memoriji, tako da se za ključ koji nije u tabeli ne čita ništa sa diska
+type TableReader struct {
+	Dir     string
+	Table   *SSTable
+	Summary *Summary
+	Filter  *probabilistic.BloomFilter
+	Stats   *FilterStats
+	// Prefiksni filter; nil ako ga tabela nema
+	PrefixFilter *PrefixFilter
+	// Filter opsega; nil ako ga tabela nema
+	RangeFilter *RangeFilter
+	// Brisanja opsega zapisana u tabeli
+	RangeTombstones []RangeTombstone
+
+	dataPath  string
+	dataStart int64
+	index     indexLayout
+}
+
+// OpenTableReader čita header, summary i filter tabele iz zadatog foldera
+func OpenTableReader(dir string, bm *blockmanager.BlockManager, blockSize int) (*TableReader, error) {
+	sst, err := ReadTableFromDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := ReadSummaryFromTable(sst, bm, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := ReadFilterFromTable(sst, bm, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	prefixFilter, err := ReadPrefixFilterFromTable(sst, bm, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	rangeFilter, err := ReadRangeFilterFromTable(sst, bm, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	rangeTombstones, err := ReadRangeTombstonesFromTable(sst, bm, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	reader := &TableReader{Dir: dir, Table: sst, Summary: sum, Filter: filter, Stats: &FilterStats{}, PrefixFilter: prefixFilter,
+		RangeFilter: rangeFilter, RangeTombstones: rangeTombstones}
+	reader.index, err = loadIndexLayout(sst, bm, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	reader.dataPath, reader.dataStart = sst.DataFilePath, reader.index.dataStart
+	if sst.SingleSSTable {
+		reader.dataPath = sst.SingleFilePath
+	}
+	sst.Filter = filter
+	return reader, nil
+}
+
+// Search traži ključ u tabeli: opseg summary-ja i Bloom filter se proveravaju u memoriji,
+// a sa diska se čitaju samo jedan deo indexa i traženi zapis
+func (r *TableReader) Search(key []byte, bm *blockmanager.BlockManager, blockSize int, compress bool,
+	dict *Dictionary) (*Record, bool, error) {
+	if bytes.Compare(key, r.Summary.MinKey) < 0 || bytes.Compare(key, r.Summary.MaxKey) > 0 {
+		return nil, false, nil
+	}
+	if !r.probe(key) {
+		return nil, false, nil
+	}
+	block, err := r.index.readBlock(bm, r.Summary, findSummaryEntry(r.Summary, key), blockSize)
+	if err != nil {
+		return nil, false, err
+	}
+	dataOff, found, err := block.find(key)
+	if err != nil {
+		return nil, false, err
+	}
+	if !found {
+		r.Stats.FalsePositives++
+		return nil, false, nil
+	}
+	rec, _, err := ReadRecordAtOffset(bm, r.dataPath, r.dataStart+int64(dataOff), blockSize, compress, dict)
+	if err != nil {
+		return nil, false, err
+	}
+	r.Stats.TruePositives++
+	return rec, true, nil
+}
+
+// probe proverava ključ u Bloom filteru i beleži ishod
+func (r *TableReader) probe(key []byte) bool {
+	r.Stats.Probes++
+	if !r.Filter.IsAdded(string(key)) {
+		r.Stats.Negatives++
+		return false
+	}
+	return true
+}
+
+// SearchMany traži više sortiranih ključeva u tabeli. Ključevi koji dele isti deo indexa
+// (isti unos summary-ja) koriste jedno čitanje tog dela. Vraća pronađene zapise po ključu.
+func (r *TableReader) SearchMany(keys [][]byte, bm *blockmanager.BlockManager, blockSize int, compress bool,
+	dict *Dictionary) (map[string]*Record, error) {
+	result := make(map[string]*Record)
+	var block indexBlock
+	lastEntry := -1
+	for _, key := range keys {
+		if bytes.Compare(key, r.Summary.MinKey) < 0 || bytes.Compare(key, r.Summary.MaxKey) > 0 {
+			continue
+		}
+		if !r.probe(key) {
+			continue
+		}
+		j := findSummaryEntry(r.Summary, key)
+		if j != lastEntry {
+			var err error
+			block, err = r.index.readBlock(bm, r.Summary, j, blockSize)
+			if err != nil {
+				return nil, err
+			}
+			lastEntry = j
+		}
+		dataOff, found, err := block.find(key)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			r.Stats.FalsePositives++
+			continue
+		}
+		rec, _, err := ReadRecordAtOffset(bm, r.dataPath, r.dataStart+int64(dataOff), blockSize, compress, dict)
+		if err != nil {
+			return nil, err
+		}
+		result[string(key)] = rec
+		r.Stats.TruePositives++
+	}
+	return result, nil
+}
+
+// TableCache čuva otvorene TableReader-e za najskorije korišćene tabele.
+// Tabele koje kompakcija obriše ili premesti moraju se izbaciti pozivom Evict.
+// Statistika filtera, opseg ključeva i brisanja opsega se čuvaju i kada reader ispadne iz keša,
+// sve dok se tabela ne izbaci, pa ni provera opsega ni pretraga brisanja opsega ne zahtevaju
+// ponovno otvaranje tabele. Keš je bezbedan za istovremeni pristup.
+type TableCache struct {
+	mu        sync.Mutex
+	bm        *blockmanager.BlockManager
+	blockSize int
+	capacity  int
+	entries   map[string]*list.Element
+	order     *list.List
+	stats     map[string]*FilterStats
+	ranges    map[string]tableRange
+	// Value log iz kog se čitaju odvojene vrednosti
+	values *ValueLog
+}
+
+func NewTableCache(capacity int, bm *blockmanager.BlockManager, blockSize int, values *ValueLog) *TableCache {
+	return &TableCache{
+		values:    values,
+		bm:        bm,
+		blockSize: blockSize,
+		capacity:  capacity,
+		entries:   make(map[string]*list.Element),
+		order:     list.New(),
+		stats:     make(map[string]*FilterStats),
+		ranges:    make(map[string]tableRange),
+	}
+}
+
+// tableRange je najmanji i najveći ključ tabele i brisanja opsega zapisana u njoj
+type tableRange struct {
+	minKey          []byte
+	maxKey          []byte
+	rangeTombstones []RangeTombstone
+}
+
+// KeyRange vraća najmanji i najveći ključ tabele; tabela se otvara samo ako opseg nije zapamćen
+func (tc *TableCache) KeyRange(dir string) ([]byte, []byte, error) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if r, ok := tc.ranges[dir]; ok {
+		return r.minKey, r.maxKey, nil
+	}
+	reader, err := tc.get(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return reader.Summary.MinKey, reader.Summary.MaxKey, nil
+}
+
+// RangeTombstones vraća brisanja opsega tabele; tabela se otvara samo ako ona nisu zapamćena
+func (tc *TableCache) RangeTombstones(dir string) ([]RangeTombstone, error) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if r, ok := tc.ranges[dir]; ok {
+		return r.rangeTombstones, nil
+	}
+	reader, err := tc.get(dir)
+	if err != nil {
+		return nil, err
+	}
+	return reader.RangeTombstones, nil
+}
+
+// Get vraća reader tabele iz keša, odnosno otvara tabelu i dodaje je u keš
+func (tc *TableCache) Get(dir string) (*TableReader, error) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return tc.get(dir)
+}
+
+func (tc *TableCache) get(dir string) (*TableReader, error) {
+	if elem, ok := tc.entries[dir]; ok {
+		tc.order.MoveToFront(elem)
+		return elem.Value.(*TableReader), nil
+	}
+	reader, err := OpenTableReader(dir, tc.bm, tc.blockSize)
+	if err != nil {
+		return nil, err
+	}
+	if stats, ok := tc.stats[dir]; ok {
+		reader.Stats = stats
+	} else {
+		tc.stats[dir] = reader.Stats
+	}
+	tc.ranges[dir] = tableRange{minKey: reader.Summary.MinKey, maxKey: reader.Summary.MaxKey,
+		rangeTombstones: reader.RangeTombstones}
+	if tc.capacity <= 0 {
+		return reader, nil
+	}
+	if tc.order.Len() >= tc.capacity {
+		oldest := tc.order.Back()
+		tc.order.Remove(oldest)
+		delete(tc.entries, oldest.Value.(*TableReader).Dir)
+	}
+	tc.entries[dir] = tc.order.PushFront(reader)
+	return reader, nil
+}
+
+// Evict izbacuje tabelu iz keša
+func (tc *TableCache) Evict(dir string) {
+	if tc == nil {
+		return
+	}
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if elem, ok := tc.entries[dir]; ok {
+		tc.order.Remove(elem)
+		delete(tc.entries, dir)
+	}
+	delete(tc.stats, dir)
+	delete(tc.ranges, dir)
+}
+
+// ResolveValue zamenjuje pokazivač u zapisu vrednošću iz value log-a
+func (tc *TableCache) ResolveValue(rec *Record) (*Record, error) {
+	return tc.values.Resolve(rec)
+}
@@ -0,0 +1,390 @@
+package sstable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"projekat/structs/blockmanager"
+)
+
+// Zaglavlje unosa value log-a: [CRC (4)][dužina ključa (8)][dužina vrednosti (8)]
+const vlogEntryHeaderSize = 20
+
+// Veličina serijalizovanog pokazivača na vrednost
+const ValuePointerSize = 24
+
+// ValuePointer pokazuje na unos u value log-u: segment, offset unosa u segmentu i dužinu celog unosa
+type ValuePointer struct {
+	Segment uint64
+	Offset  uint64
+	Length  uint64
+}
+
+func (p ValuePointer) Encode() []byte {
+	buf := make([]byte, ValuePointerSize)
+	binary.LittleEndian.PutUint64(buf[0:8], p.Segment)
+	binary.LittleEndian.PutUint64(buf[8:16], p.Offset)
+	binary.LittleEndian.PutUint64(buf[16:24], p.Length)
+	return buf
+}
+
+func DecodeValuePointer(data []byte) (ValuePointer, error) {
+	if len(data) != ValuePointerSize {
+		return ValuePointer{}, errors.New("neispravan pokazivač na vrednost")
+	}
+	return ValuePointer{
+		Segment: binary.LittleEndian.Uint64(data[0:8]),
+		Offset:  binary.LittleEndian.Uint64(data[8:16]),
+		Length:  binary.LittleEndian.Uint64(data[16:24]),
+	}, nil
+}
+
+// ValueLogEntry je jedan unos value log-a pročitan pri čišćenju segmenta
+type ValueLogEntry struct {
+	Key     []byte
+	Value   []byte
+	Pointer ValuePointer
+}
+
+// ValueLog čuva velike vrednosti odvojeno od SSTabela (WiscKey). Pri flush-u Memtable-a
+// vrednosti od bar threshold bajtova se upisuju u value log jednom, a SSTabela umesto njih
+// čuva pokazivač, pa kompakcije prepisuju samo ključeve i pokazivače. Log se sastoji od
+// segmenata koji se samo dopisuju; prostor zauzet pregaženim ili obrisanim vrednostima
+// oslobađa se brisanjem najstarijeg segmenta nakon što se njegove žive vrednosti ponovo upišu.
+// Spisak segmenata i aktivni segment su pod zaključavanjem, jer flusher dopisuje vrednosti dok
+// komande čitaju log i čiste ga. Vrednosti flush-a koji je u toku još nisu dostupne iz LSM
+// stabla, pa se segmenti u koje on piše ne čiste dok se upis ne potvrdi (Begin/Commit), a
+// neuspeo upis se uklanja iz log-a (Rollback) da ga ponovni pokušaj ne bi duplirao.
+type ValueLog struct {
+	mu          sync.Mutex
+	bm          *blockmanager.BlockManager
+	blockSize   int
+	dir         string
+	threshold   int
+	segmentSize int64
+	segments    []uint64
+	// Aktivni segment, njegova dužina i sadržaj poslednjeg (nepopunjenog) bloka
+	head     uint64
+	headSize int64
+	tail     []byte
+	// Početak upisa koji je u toku (Begin); segmenti od njega nadalje se ne čiste
+	pending *ValueLogMark
+}
+
+// ValueLogMark je kraj log-a pre upisa: aktivni segment, njegova dužina i poslednji blok
+type ValueLogMark struct {
+	head     uint64
+	headSize int64
+	tail     []byte
+}
+
+// OpenValueLog otvara value log u zadatom folderu; threshold 0 isključuje odvajanje vrednosti
+func OpenValueLog(dir string, bm *blockmanager.BlockManager, blockSize int, threshold int, segmentSize int64) (*ValueLog, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	vl := &ValueLog{bm: bm, blockSize: blockSize, dir: dir, threshold: threshold, segmentSize: segmentSize}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		var id uint64
+		if _, err := fmt.Sscanf(f.Name(), "vlog_%d.log", &id); err == nil && strings.HasSuffix(f.Name(), ".log") {
+			vl.segments = append(vl.segments, id)
+		}
+	}
+	sort.Slice(vl.segments, func(i, j int) bool { return vl.segments[i] < vl.segments[j] })
+	if len(vl.segments) == 0 {
+		return vl, nil
+	}
+	// Dužina aktivnog segmenta se određuje prolaskom kroz njegove unose
+	vl.head = vl.segments[len(vl.segments)-1]
+	entries, err := vl.ReadSegment(vl.head)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(entries); n > 0 {
+		last := entries[n-1].Pointer
+		vl.headSize = int64(last.Offset + last.Length)
+	}
+	if rest := vl.headSize % int64(blockSize); rest > 0 {
+		vl.tail, err = readSegment(bm, vl.segmentPath(vl.head), vl.headSize-rest, int(rest), blockSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return vl, nil
+}
+
+func (vl *ValueLog) segmentPath(id uint64) string {
+	return filepath.Join(vl.dir, fmt.Sprintf("vlog_%04d.log", id))
+}
+
+// Separate upisuje vrednosti od bar threshold bajtova u log i menja ih pokazivačima
+func (vl *ValueLog) Separate(records []Record) error {
+	if vl == nil || vl.threshold <= 0 {
+		return nil
+	}
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+	for i := range records {
+		rec := &records[i]
+		if rec.Tombstone || rec.Separated || len(rec.Value) < vl.threshold {
+			continue
+		}
+		ptr, err := vl.append(rec.Key, rec.Value)
+		if err != nil {
+			return err
+		}
+		rec.Value = ptr.Encode()
+		rec.Separated = true
+	}
+	return nil
+}
+
+// append dopisuje unos na kraj aktivnog segmenta, a kada je segment pun prelazi na novi.
+// Poziva se pod zaključavanjem.
+func (vl *ValueLog) append(key, value []byte) (ValuePointer, error) {
+	entry := make([]byte, vlogEntryHeaderSize, vlogEntryHeaderSize+len(key)+len(value))
+	binary.LittleEndian.PutUint64(entry[4:12], uint64(len(key)))
+	binary.LittleEndian.PutUint64(entry[12:20], uint64(len(value)))
+	entry = append(entry, key...)
+	entry = append(entry, value...)
+	binary.LittleEndian.PutUint32(entry[0:4], crc32.ChecksumIEEE(entry[4:]))
+
+	if len(vl.segments) == 0 || (vl.headSize > 0 && vl.headSize+int64(len(entry)) > vl.segmentSize) {
+		if len(vl.segments) > 0 {
+			vl.head++
+		}
+		vl.segments = append(vl.segments, vl.head)
+		vl.headSize = 0
+		vl.tail = nil
+	}
+	// Poslednji blok se prepisuje zajedno sa novim unosom
+	buf := append(vl.tail, entry...)
+	first := int(vl.headSize / int64(vl.blockSize))
+	for pos := 0; pos < len(buf); pos += vl.blockSize {
+		if err := vl.bm.WriteBlockAt(vl.segmentPath(vl.head), first+pos/vl.blockSize, buf[pos:min(pos+vl.blockSize, len(buf))]); err != nil {
+			return ValuePointer{}, err
+		}
+	}
+	ptr := ValuePointer{Segment: vl.head, Offset: uint64(vl.headSize), Length: uint64(len(entry))}
+	vl.headSize += int64(len(entry))
+	vl.tail = append([]byte{}, buf[len(buf)/vl.blockSize*vl.blockSize:]...)
+	return ptr, nil
+}
+
+// Read čita vrednost na koju pokazuje pokazivač i proverava CRC unosa
+func (vl *ValueLog) Read(ptr ValuePointer) ([]byte, error) {
+	if ptr.Length < vlogEntryHeaderSize {
+		return nil, errors.New("neispravan pokazivač na vrednost")
+	}
+	entry, err := readSegment(vl.bm, vl.segmentPath(ptr.Segment), int64(ptr.Offset), int(ptr.Length), vl.blockSize)
+	if err != nil {
+		return nil, err
+	}
+	_, value, err := decodeVlogEntry(entry)
+	return value, err
+}
+
+// decodeVlogEntry proverava unos i vraća njegov ključ i vrednost
+func decodeVlogEntry(entry []byte) ([]byte, []byte, error) {
+	keyLen := binary.LittleEndian.Uint64(entry[4:12])
+	valLen := binary.LittleEndian.Uint64(entry[12:20])
+	if uint64(len(entry)) != vlogEntryHeaderSize+keyLen+valLen {
+		return nil, nil, errors.New("neispravan unos value log-a")
+	}
+	if crc32.ChecksumIEEE(entry[4:]) != binary.LittleEndian.Uint32(entry[0:4]) {
+		return nil, nil, errors.New("CRC mismatch – corrupted value log entry")
+	}
+	return entry[vlogEntryHeaderSize : vlogEntryHeaderSize+keyLen], entry[vlogEntryHeaderSize+keyLen:], nil
+}
+
+// Resolve vraća zapis sa vrednošću pročitanom iz log-a; zapisi bez pokazivača se vraćaju nepromenjeni
+func (vl *ValueLog) Resolve(rec *Record) (*Record, error) {
+	if rec == nil || !rec.Separated {
+		return rec, nil
+	}
+	if vl == nil {
+		return nil, errors.New("zapis pokazuje na value log koji nije otvoren")
+	}
+	ptr, err := DecodeValuePointer(rec.Value)
+	if err != nil {
+		return nil, err
+	}
+	value, err := vl.Read(ptr)
+	if err != nil {
+		return nil, err
+	}
+	resolved := *rec
+	resolved.Value = value
+	resolved.ValueSize = uint64(len(value))
+	resolved.Separated = false
+	return &resolved, nil
+}
+
+// Begin označava početak upisa vrednosti jednog flush-a. Do Commit ili Rollback segmenti
+// u koje on piše nisu kandidati za čišćenje. Upisuje samo flusher, pa je upis najviše jedan.
+func (vl *ValueLog) Begin() {
+	if vl == nil {
+		return
+	}
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+	vl.pending = &ValueLogMark{head: vl.head, headSize: vl.headSize, tail: bytes.Clone(vl.tail)}
+}
+
+// Commit potvrđuje upis započet sa Begin; poziva se kada je tabela sa pokazivačima u LSM stablu
+func (vl *ValueLog) Commit() {
+	if vl == nil {
+		return
+	}
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+	vl.pending = nil
+}
+
+// Rollback uklanja sve što je upisano od Begin: novi segmenti se brišu, a aktivni segment se
+// skraćuje na dužinu pre upisa
+func (vl *ValueLog) Rollback() error {
+	if vl == nil {
+		return nil
+	}
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+	mark := vl.pending
+	if mark == nil {
+		return nil
+	}
+	vl.pending = nil
+	for len(vl.segments) > 0 && vl.segments[len(vl.segments)-1] > mark.head {
+		id := vl.segments[len(vl.segments)-1]
+		vl.segments = vl.segments[:len(vl.segments)-1]
+		if err := os.Remove(vl.segmentPath(id)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	vl.head, vl.headSize, vl.tail = mark.head, mark.headSize, mark.tail
+	if len(vl.segments) == 0 || vl.segments[len(vl.segments)-1] != mark.head {
+		return nil
+	}
+	if mark.headSize == 0 && len(vl.segments) == 1 {
+		// Log je bio prazan - segment je napravio ovaj upis
+		vl.segments = nil
+		if err := os.Remove(vl.segmentPath(mark.head)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	// Segment se skraćuje na ceo broj blokova, a poslednji blok se prepisuje sadržajem pre upisa
+	path := vl.segmentPath(mark.head)
+	blocks := (mark.headSize + int64(vl.blockSize) - 1) / int64(vl.blockSize)
+	if err := os.Truncate(path, blocks*int64(vl.blockSize)); err != nil {
+		return err
+	}
+	if len(mark.tail) == 0 {
+		return nil
+	}
+	return vl.bm.WriteBlockAt(path, int(blocks-1), mark.tail)
+}
+
+// OldestSegment vraća najstariji segment koji više nije aktivan (kandidat za čišćenje)
+func (vl *ValueLog) OldestSegment() (uint64, bool) {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+	if len(vl.segments) < 2 || vl.isPending(vl.segments[0]) {
+		return 0, false
+	}
+	return vl.segments[0], true
+}
+
+// isPending proverava da li upis koji je u toku piše u segment; poziva se pod zaključavanjem
+func (vl *ValueLog) isPending(id uint64) bool {
+	return vl.pending != nil && id >= vl.pending.head
+}
+
+// ReadSegment čita sve unose segmenta redom; čitanje staje na prvom praznom ili oštećenom unosu
+func (vl *ValueLog) ReadSegment(id uint64) ([]ValueLogEntry, error) {
+	path := vl.segmentPath(id)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []ValueLogEntry
+	offset := int64(0)
+	for offset+vlogEntryHeaderSize <= info.Size() {
+		header, err := readSegment(vl.bm, path, offset, vlogEntryHeaderSize, vl.blockSize)
+		if err != nil {
+			return nil, err
+		}
+		keyLen := binary.LittleEndian.Uint64(header[4:12])
+		valLen := binary.LittleEndian.Uint64(header[12:20])
+		length := vlogEntryHeaderSize + int64(keyLen) + int64(valLen)
+		// Prazan ključ znači da je ostatak padding poslednjeg bloka
+		if keyLen == 0 || offset+length > info.Size() {
+			break
+		}
+		entry, err := readSegment(vl.bm, path, offset, int(length), vl.blockSize)
+		if err != nil {
+			return nil, err
+		}
+		key, value, err := decodeVlogEntry(entry)
+		if err != nil {
+			break
+		}
+		entries = append(entries, ValueLogEntry{
+			Key:     bytes.Clone(key),
+			Value:   bytes.Clone(value),
+			Pointer: ValuePointer{Segment: id, Offset: uint64(offset), Length: uint64(length)},
+		})
+		offset += length
+	}
+	return entries, nil
+}
+
+// RemoveSegment briše segment iz log-a; aktivni segment se ne može obrisati
+func (vl *ValueLog) RemoveSegment(id uint64) error {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+	if id == vl.head {
+		return errors.New("aktivni segment value log-a se ne može obrisati")
+	}
+	if vl.isPending(id) {
+		return errors.New("u segment value log-a se upravo upisuje")
+	}
+	for i, seg := range vl.segments {
+		if seg == id {
+			vl.segments = append(vl.segments[:i], vl.segments[i+1:]...)
+			return os.Remove(vl.segmentPath(id))
+		}
+	}
+	return errors.New("segment value log-a ne postoji")
+}
+
+// ValueLogStats opisuje zauzeće value log-a
+type ValueLogStats struct {
+	Segments  int
+	Bytes     int64
+	Threshold int
+}
+
+func (vl *ValueLog) Stats() ValueLogStats {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+	stats := ValueLogStats{Segments: len(vl.segments), Threshold: vl.threshold}
+	for _, id := range vl.segments {
+		if info, err := os.Stat(vl.segmentPath(id)); err == nil {
+			stats.Bytes += info.Size()
+		}
+	}
+	return stats
+}
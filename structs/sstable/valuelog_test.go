@@ -0,0 +1,156 @@
+package sstable
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"projekat/structs/blockmanager"
+)
+
+func openTestValueLog(t *testing.T, dir string, segmentSize int64) *ValueLog {
+	vl, err := OpenValueLog(dir, blockmanager.NewBlockManager(testBlockSize, 64), testBlockSize, 32, segmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vl
+}
+
+// vlogRecords pravi zapise čije su vrednosti naizmenično ispod i iznad praga odvajanja
+func vlogRecords(prefix string, n int) []Record {
+	records := make([]Record, 0, n)
+	for i := 0; i < n; i++ {
+		value := fmt.Sprintf("%s-%d", prefix, i)
+		if i%2 == 0 {
+			value += string(bytes.Repeat([]byte{'v'}, 100))
+		}
+		records = append(records, put(fmt.Sprintf("%s%03d", prefix, i), value, uint64(i+1)))
+	}
+	return records
+}
+
+// Resolve vraća vrednost na koju pokazuje zapis, a zapise bez pokazivača ne menja
+func TestValueLogResolve(t *testing.T) {
+	dir := t.TempDir()
+	vl := openTestValueLog(t, dir, 1024)
+	records := vlogRecords("k", 40)
+	original := make([]Record, len(records))
+	copy(original, records)
+	if err := vl.Separate(records); err != nil {
+		t.Fatal(err)
+	}
+	for i := range records {
+		if records[i].Separated != (len(original[i].Value) >= 32) {
+			t.Fatalf("%s: odvojena %v, vrednost od %d B", records[i].Key, records[i].Separated, len(original[i].Value))
+		}
+		rec, err := vl.Resolve(&records[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Separated || string(rec.Value) != string(original[i].Value) || rec.ValueSize != uint64(len(original[i].Value)) {
+			t.Fatalf("%s: Resolve vratio %q", records[i].Key, rec.Value)
+		}
+	}
+	if st := vl.Stats(); st.Segments < 2 {
+		t.Fatalf("očekivano više segmenata, dobijeno %d", st.Segments)
+	}
+
+	// Posle ponovnog otvaranja pokazivači i dalje važe
+	reopened := openTestValueLog(t, dir, 1024)
+	if rec, err := reopened.Resolve(&records[0]); err != nil || string(rec.Value) != string(original[0].Value) {
+		t.Fatalf("posle otvaranja: %v", err)
+	}
+	var closed *ValueLog
+	if _, err := closed.Resolve(&records[0]); err == nil {
+		t.Fatal("zapis sa pokazivačem razrešen bez value log-a")
+	}
+
+	// Oštećen unos se otkriva CRC-om
+	ptr, err := DecodeValuePointer(records[0].Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := reopened.segmentPath(ptr.Segment)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[ptr.Offset+ptr.Length-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openTestValueLog(t, dir, 1024).Resolve(&records[0]); err == nil {
+		t.Fatal("oštećen unos nije otkriven")
+	}
+}
+
+// Neuspeo flush se uklanja iz log-a, pa ponovni pokušaj upisuje vrednosti na ista mesta; dok
+// upis traje, segmenti u koje piše nisu kandidati za čišćenje
+func TestValueLogRollback(t *testing.T) {
+	dir := t.TempDir()
+	vl := openTestValueLog(t, dir, 1024)
+	if err := vl.Separate(vlogRecords("a", 10)); err != nil {
+		t.Fatal(err)
+	}
+	before := vl.Stats()
+
+	vl.Begin()
+	failed := vlogRecords("b", 60)
+	if err := vl.Separate(failed); err != nil {
+		t.Fatal(err)
+	}
+	if st := vl.Stats(); st.Segments <= before.Segments {
+		t.Fatalf("upis nije prešao u novi segment: %+v", st)
+	}
+	if id, ok := vl.OldestSegment(); ok {
+		t.Fatalf("segment %d u koji se upisuje ponuđen za čišćenje", id)
+	}
+	if err := vl.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if st := vl.Stats(); st != before {
+		t.Fatalf("posle poništavanja %+v, očekivano %+v", st, before)
+	}
+
+	vl.Begin()
+	retried := vlogRecords("b", 60)
+	if err := vl.Separate(retried); err != nil {
+		t.Fatal(err)
+	}
+	vl.Commit()
+	for i := range retried {
+		if string(retried[i].Value) != string(failed[i].Value) {
+			t.Fatalf("%s: ponovni upis na drugom mestu", retried[i].Key)
+		}
+	}
+	if _, ok := vl.OldestSegment(); !ok {
+		t.Fatal("posle potvrde nema segmenta za čišćenje")
+	}
+
+	// Ponovo otvoren log vidi samo potvrđene unose
+	reopened := openTestValueLog(t, dir, 1024)
+	if reopened.Stats() != vl.Stats() || reopened.headSize != vl.headSize {
+		t.Fatalf("posle otvaranja %+v (%d B aktivnog segmenta), očekivano %+v (%d B)",
+			reopened.Stats(), reopened.headSize, vl.Stats(), vl.headSize)
+	}
+	for i := range retried {
+		rec, err := reopened.Resolve(&retried[i])
+		if err != nil || string(rec.Value) != string(vlogRecords("b", 60)[i].Value) {
+			t.Fatalf("%s: %v", retried[i].Key, err)
+		}
+	}
+
+	// Poništen prvi upis ostavlja prazan log
+	empty := openTestValueLog(t, t.TempDir(), 1024)
+	empty.Begin()
+	if err := empty.Separate(vlogRecords("c", 30)); err != nil {
+		t.Fatal(err)
+	}
+	if err := empty.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if st := empty.Stats(); st.Segments != 0 || st.Bytes != 0 {
+		t.Fatalf("poništen prvi upis ostavio %+v", st)
+	}
+}
@@ -22,6 +22,8 @@ type Record struct {
 	ValueSize uint64   // Velicina vrednsoti
 	Key       []byte   // Kljuc
 	Value     []byte   // Vrednost
+	// Brisanje opsega [Key, Value); u bajtu groba se zapisuje kao 2
+	RangeTombstone bool
 }
 
 // Struktura Write-Ahead Log-a (WAL)
@@ -35,6 +37,7 @@ type WAL struct {
 	walBlocksPerSegment int                        // Broj blokova po segmentu
 	LastSeg             uint32                     // Indeks poslednjeg segmenta
 	FirstSeg            uint32                     // Redni broj prvog segmenta
+	lastWrite           uint64                     // Vreme poslednjeg upisa (UnixNano)
 }
 
 func (r *Record) CalculateSize() int {
@@ -44,9 +47,12 @@ func (r *Record) CalculateSize() int {
 func (r *Record) RecordToBytes() []byte {
 	bytes := make([]byte, 0)
 	bytes = append(bytes, r.Timestamp[:]...)
-	if r.Tombstone {
+	switch {
+	case r.RangeTombstone:
+		bytes = append(bytes, byte(2))
+	case r.Tombstone:
 		bytes = append(bytes, byte(0))
-	} else {
+	default:
 		bytes = append(bytes, byte(1))
 	}
 	bytes = append(bytes, r.Type)
@@ -67,11 +73,8 @@ func (r *Record) BytesToRecord(byteptr *[]byte, seek int) (int, bool) {
 	seek += 4
 	copy(r.Timestamp[:], (*byteptr)[seek:seek+16])
 	seek += 16
-	if (*byteptr)[seek] == 0 {
-		r.Tombstone = true
-	} else {
-		r.Tombstone = false
-	}
+	r.Tombstone = (*byteptr)[seek] != 1
+	r.RangeTombstone = (*byteptr)[seek] == 2
 	seek += 1
 	r.Type = (*byteptr)[seek]
 	seek += 1
@@ -153,21 +156,43 @@ func NewWAL(dirPath string, walMaxRecordsPerSegment int, walBlocksPerSegment int
 		blockSize:           blockSize,
 		LastSeg:             last,
 		FirstSeg:            first,
+		// Vreme upisa pre otvaranja nije poznato, pa se uzima trenutak otvaranja
+		lastWrite: uint64(time.Now().UnixNano()),
 	}, nil
 }
 
+// LastWrite vraća vreme poslednjeg upisa u WAL (ili otvaranja WAL-a ako upisa nije bilo)
+func (w *WAL) LastWrite() uint64 {
+	return w.lastWrite
+}
+
 // AppendRecord upisuje zapis u WAL
 func (w *WAL) AppendRecord(tombstone bool, key, value []byte) ([16]byte, error) {
-	record := Record{
+	return w.appendRecord(Record{
 		Tombstone: tombstone,
 		KeySize:   uint64(len(key)),
 		ValueSize: uint64(len(value)),
 		Key:       key,
 		Value:     value,
-	}
+	})
+}
+
+// AppendRangeTombstone upisuje brisanje opsega [start, end) u WAL
+func (w *WAL) AppendRangeTombstone(start, end []byte) ([16]byte, error) {
+	return w.appendRecord(Record{
+		Tombstone:      true,
+		RangeTombstone: true,
+		KeySize:        uint64(len(start)),
+		ValueSize:      uint64(len(end)),
+		Key:            start,
+		Value:          end,
+	})
+}
 
+func (w *WAL) appendRecord(record Record) ([16]byte, error) {
 	// Postavi time-stamp
-	ts := binary.LittleEndian.AppendUint64([]byte{}, uint64(time.Now().UnixNano()))
+	w.lastWrite = uint64(time.Now().UnixNano())
+	ts := binary.LittleEndian.AppendUint64([]byte{}, w.lastWrite)
 	ts = append(ts, make([]byte, 8)...)
 	copy(record.Timestamp[:], ts[:])
 	// Radimo u petlji - tražimo mesto
@@ -241,7 +266,8 @@ func (w *WAL) SegmentRecord(rec Record, blockSpace int) [][]byte {
 	valueIndex := 0
 	for i := 0; i < len(seglens); i++ {
 		newRec := Record{
-			Tombstone: rec.Tombstone,
+			Tombstone:      rec.Tombstone,
+			RangeTombstone: rec.RangeTombstone,
 		}
 		if seglens[i] < int(rec.KeySize)-keyIndex {
 			newRec.KeySize = uint64(seglens[i])
@@ -351,10 +377,11 @@ func (w *WAL) ReadRecords() (map[uint32][]Record, error) {
 				case 1: // FIRST
 					// Započi rekonstrukciju partialRecord-a
 					partialRecord = &Record{
-						Timestamp: newRecord.Timestamp,
-						Tombstone: newRecord.Tombstone,
-						Key:       append([]byte{}, newRecord.Key...),
-						Value:     append([]byte{}, newRecord.Value...),
+						Timestamp:      newRecord.Timestamp,
+						Tombstone:      newRecord.Tombstone,
+						RangeTombstone: newRecord.RangeTombstone,
+						Key:            append([]byte{}, newRecord.Key...),
+						Value:          append([]byte{}, newRecord.Value...),
 					}
 
 				case 2: // MIDDLE
@@ -373,13 +400,14 @@ func (w *WAL) ReadRecords() (map[uint32][]Record, error) {
 						partialRecord.Value = append(partialRecord.Value, newRecord.Value...)
 
 						finalRec := Record{
-							Timestamp: partialRecord.Timestamp,
-							Tombstone: partialRecord.Tombstone,
-							Type:      0,
-							KeySize:   uint64(len(partialRecord.Key)),
-							ValueSize: uint64(len(partialRecord.Value)),
-							Key:       partialRecord.Key,
-							Value:     partialRecord.Value,
+							Timestamp:      partialRecord.Timestamp,
+							Tombstone:      partialRecord.Tombstone,
+							RangeTombstone: partialRecord.RangeTombstone,
+							Type:           0,
+							KeySize:        uint64(len(partialRecord.Key)),
+							ValueSize:      uint64(len(partialRecord.Value)),
+							Key:            partialRecord.Key,
+							Value:          partialRecord.Value,
 						}
 						finalRec.RecordToBytes()
 
@@ -1,15 +1,21 @@
 package utils
 
 import (
-	"encoding/binary"
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"projekat/config"
 	"projekat/structs/blockmanager"
+	"projekat/structs/cursor"
+	"projekat/structs/lrucache"
 	"projekat/structs/memtable"
 	"projekat/structs/sstable"
 	"projekat/structs/wal"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -76,116 +82,541 @@ func MaybeQuote(s string) string {
 	return s
 }
 
-func WriteToMemory(ts [16]byte, tombstone bool, key string, value []byte, bm *blockmanager.BlockManager,
-	memtables *[]memtable.MemtableInterface, mtIndex *int, wal *wal.WAL, mtnum int) *[]sstable.Record {
-	for i := range *memtables {
-		_, _, exists := (*memtables)[i].Get(key)
-		if exists {
-			(*memtables)[i].Add(ts, tombstone, key, value)
+// PrefixEnd vraća najmanji ključ veći od svih ključeva sa prefiksom (kraj opsega [prefiks, kraj));
+// prazan string ako takav ne postoji (prazan prefiks ili prefiks od samih bajtova 0xff)
+func PrefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
 		}
 	}
-	(*memtables)[*mtIndex].Add(ts, tombstone, key, value)
+	return ""
+}
+
+// ParsePageArg tumači argument strane skeniranja: broj strane (od 1) ili token za nastavak
+// koji pripada zadatom opsegu. Za token vraća broj strane 0.
+func ParsePageArg(arg string, scope uint32) (int, *cursor.ContinuationToken, error) {
+	if pageNum, err := strconv.Atoi(arg); err == nil {
+		if pageNum < 1 {
+			return 0, nil, errors.New("broj strane mora biti pozitivan")
+		}
+		return pageNum, nil, nil
+	}
+	token, err := cursor.DecodeContinuationToken(arg, scope)
+	if err != nil {
+		return 0, nil, err
+	}
+	return 0, &token, nil
+}
+
+// ScanOptions su opcije skeniranja navedene posle obaveznih argumenata komande
+type ScanOptions struct {
+	Descending bool
+	KeysOnly   bool
+	// Filter vrednosti (nil ako nije zadat) i njegov opis, koji ulazi u opseg tokena
+	Filter cursor.ValueFilter
+	Spec   []string
+}
+
+// Poređenja dužine vrednosti za LEN filter
+var lengthPredicates = map[string]func(a, b int) bool{
+	"<":  func(a, b int) bool { return a < b },
+	"<=": func(a, b int) bool { return a <= b },
+	">":  func(a, b int) bool { return a > b },
+	">=": func(a, b int) bool { return a >= b },
+	"=":  func(a, b int) bool { return a == b },
+	"!=": func(a, b int) bool { return a != b },
+}
+
+// ParseScanOptions tumači opcije skeniranja: ASC|DESC, KEYS_ONLY i filtere vrednosti
+// CONTAINS <tekst>, MATCHES <regex> i LEN <op> <broj>. Više filtera mora biti ispunjeno
+// istovremeno. Kod brojanja (count) smer i KEYS_ONLY nemaju smisla pa nisu dozvoljeni.
+func ParseScanOptions(args []string, count bool) (ScanOptions, error) {
+	var opts ScanOptions
+	var filters []cursor.ValueFilter
+	for i := 0; i < len(args); i++ {
+		option := strings.ToUpper(args[i])
+		switch option {
+		case "ASC", "DESC", "KEYS_ONLY":
+			if count {
+				return opts, fmt.Errorf("opcija %s nije dozvoljena pri brojanju", option)
+			}
+			if option == "KEYS_ONLY" {
+				opts.KeysOnly = true
+			} else {
+				opts.Descending = option == "DESC"
+			}
+		case "CONTAINS":
+			if i+1 >= len(args) {
+				return opts, errors.New("CONTAINS zahteva <tekst>")
+			}
+			text := []byte(args[i+1])
+			filters = append(filters, func(value []byte) bool { return bytes.Contains(value, text) })
+			opts.Spec = append(opts.Spec, option, args[i+1])
+			i++
+		case "MATCHES":
+			if i+1 >= len(args) {
+				return opts, errors.New("MATCHES zahteva <regex>")
+			}
+			re, err := regexp.Compile(args[i+1])
+			if err != nil {
+				return opts, fmt.Errorf("neispravan regex: %v", err)
+			}
+			filters = append(filters, re.Match)
+			opts.Spec = append(opts.Spec, option, args[i+1])
+			i++
+		case "LEN":
+			if i+2 >= len(args) {
+				return opts, errors.New("LEN zahteva <op> <broj>")
+			}
+			compare, ok := lengthPredicates[args[i+1]]
+			n, err := strconv.Atoi(args[i+2])
+			if !ok || err != nil || n < 0 {
+				return opts, errors.New("LEN zahteva operator (<, <=, >, >=, =, !=) i nenegativan broj")
+			}
+			filters = append(filters, func(value []byte) bool { return compare(len(value), n) })
+			opts.Spec = append(opts.Spec, option, args[i+1], args[i+2])
+			i += 2
+		default:
+			return opts, fmt.Errorf("nepoznata opcija %s", args[i])
+		}
+	}
+	if len(filters) > 0 {
+		opts.Filter = func(value []byte) bool {
+			for _, filter := range filters {
+				if !filter(value) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return opts, nil
+}
+
+// NewScanCursor pravi multicursor nad svim Memtable-ima i SSTabelama koje mogu sadržati ključ
+// iz opsega [minKey, maxKey]. Za skeniranje po prefiksu (prefix nije prazan) tabele se biraju
+// prefiksnim filterom, a inače range filterom. Brisanja opsega iz Memtable-a i svih tabela
+// se primenjuju pri spajanju.
+func NewScanCursor(minKey, maxKey, prefix string, memtables []memtable.MemtableInterface, lsm map[byte][]string,
+	cfg config.Config, bm *blockmanager.BlockManager, dict *sstable.Dictionary, tables *sstable.TableCache,
+	values *sstable.ValueLog) *cursor.MultiCursor {
+	cursors := make([]cursor.Cursor, 0, len(memtables))
+	rangeTombstones := make([]sstable.RangeTombstone, 0)
+	for _, mt := range memtables {
+		c := mt.NewCursor()
+		// Cursor radix stabla odmah prelazi na podstablo sa prefiksom
+		if pc, ok := c.(cursor.PrefixCursor); ok && prefix != "" {
+			pc.SetPrefix(prefix)
+		}
+		cursors = append(cursors, c)
+		rangeTombstones = append(rangeTombstones, mt.RangeTombstones()...)
+	}
+	for _, level := range lsm {
+		for _, path := range level {
+			reader, err := tables.Get(path)
+			if err == nil {
+				// Brisanja opsega važe i kada tabela nema nijedan ključ iz opsega skeniranja
+				rangeTombstones = append(rangeTombstones, reader.RangeTombstones...)
+				if prefix != "" && !reader.MayContainPrefix(prefix) {
+					continue
+				}
+				if prefix == "" && !reader.MayContainRange(minKey, maxKey) {
+					continue
+				}
+			}
+			sc, err := sstable.NewCursor(bm, path, minKey, maxKey, cfg.BlockSize, cfg.SSTableCompression, dict, values)
+			if err != nil {
+				fmt.Println("Greška prilikom formiranja kursora.")
+				continue
+			}
+			cursors = append(cursors, &sc)
+		}
+	}
+	// Multi cursor spaja sve cursore i vraća samo najnoviju verziju svakog ključa koji nije obrisan
+	mc := cursor.NewMultiCursor(minKey, maxKey, cursors...)
+	rangeTombstones = slices.DeleteFunc(rangeTombstones, func(rt sstable.RangeTombstone) bool {
+		return !rt.Overlaps([]byte(minKey), []byte(maxKey))
+	})
+	if len(rangeTombstones) > 0 {
+		mc.SetRangeTombstones(func(key string) uint64 {
+			return sstable.CoveringTimestamp(rangeTombstones, []byte(key))
+		})
+	}
+	return mc
+}
+
+// WriteToMemory upisuje zapis u aktivni Memtable. Pun Memtable se zamrzava i predaje flusher-u,
+// a upis čeka samo ako je nepromenljivih Memtable-a više nego što je dozvoljeno.
+func WriteToMemory(ts [16]byte, tombstone bool, key string, value []byte, memtables *memtable.Memtables,
+	wal *wal.WAL, cache *lrucache.RowCache) {
+	// Keširan rezultat čitanja sa diska više ne važi
+	cache.Invalidate(key)
+	rotated := memtables.Add(ts, tombstone, key, value, wal.LastSeg)
 	fmt.Printf("Uspešno dodato: [%s -> %s]\n", MaybeQuote(string(key)), MaybeQuote(string(value)))
-	(*memtables)[*mtIndex].SetWatermark(wal.LastSeg)
-	// Proveravamo da li je trenutni memtable popunjen
-	if (*memtables)[*mtIndex].IsFull() {
+	if rotated {
+		fmt.Println("Dostignuta maksimalna veličina Memtable-a, prelazim na sledeći...")
+	}
+	if memtables.WaitForRoom() {
+		fmt.Println("Upis je sačekao da se nepromenljivi Memtable-i upišu na disk")
+	}
+}
+
+// DeleteFromMemory upisuje tombstone u aktivni Memtable, i kada ključa nema u Memtable-u
+func DeleteFromMemory(ts [16]byte, key string, memtables *memtable.Memtables, wal *wal.WAL, cache *lrucache.RowCache) {
+	// Keširan rezultat čitanja sa diska više ne važi
+	cache.Invalidate(key)
+	if memtables.Delete(ts, key, wal.LastSeg) {
 		fmt.Println("Dostignuta maksimalna veličina Memtable-a, prelazim na sledeći...")
-		*mtIndex = (*mtIndex + 1) % mtnum
-		// Ako je i sledeći memtable pun - svi su puni
-		// Flushujemo memtable i stavljamo njegov sadržaj u SSTable
-		if (*memtables)[*mtIndex].IsFull() {
-			// Low watermark provera za brisanje WALa
-			// Brišemo sve WAL segmente između trenutno najstarijeg i watermarka
-			watermark := (*memtables)[*mtIndex].GetWatermark()
-			for i := wal.FirstSeg; i < watermark; i++ {
-				deletePath := wal.GetSegmentFilename(i)
-				err := os.Remove(filepath.Join(wal.Dir, deletePath))
-				if err != nil {
-					fmt.Printf("Greška pri brisanju WAL segmenata")
+	}
+	if memtables.WaitForRoom() {
+		fmt.Println("Upis je sačekao da se nepromenljivi Memtable-i upišu na disk")
+	}
+}
+
+// FlushMemtable upisuje nepromenljivi Memtable u SSTabelu. Izvršava se bez zaključavanja
+// Memtable-a, pa ne menja ništa što komande čitaju; vraća funkciju koja pod zaključavanjem
+// objavljuje tabelu u LSM stablu, prazni Memtable i briše WAL segmente čiji su svi zapisi sada
+// na disku (one pre watermarka Memtable-a). Memtable-i se upisuju od najstarijeg, pa su zapisi
+// svih neupisanih Memtable-a u segmentima od watermarka nadalje. Kompakcija menja tabele koje
+// komande čitaju, pa se i ona izvršava pri objavi. Ako upis ne uspe, Memtable i WAL ostaju netaknuti.
+func FlushMemtable(mt memtable.MemtableInterface, wal *wal.WAL, sstableDir string, bm *blockmanager.BlockManager,
+	lsm *map[byte][]string, cfg config.Config, dict *sstable.Dictionary, dictPath string,
+	strategy sstable.CompactionStrategy, cache *lrucache.RowCache, values *sstable.ValueLog) (func(), error) {
+	watermark := mt.GetWatermark()
+	batch := memtable.ConvertMemToSST(&mt)
+	// Vrednosti upisane u value log se ne čiste dok tabela ne uđe u LSM stablo, a ako upis ne
+	// uspe, uklanjaju se iz log-a da ih ponovni pokušaj ne bi upisao još jednom
+	values.Begin()
+	newSSTdir, err := WriteToDisk(batch, sstableDir, bm, cfg, dict, dictPath, values)
+	if err != nil {
+		if rerr := values.Rollback(); rerr != nil {
+			fmt.Printf("Greška pri poništavanju upisa u value log: %v\n", rerr)
+		}
+		return nil, err
+	}
+	return func() {
+		// Zapisi iz Memtable-a postaju vidljivi tek sa diska, pa se njihovi ključevi izbacuju iz keša
+		for _, rec := range batch.Records {
+			cache.Invalidate(string(rec.Key))
+		}
+		for _, rt := range batch.RangeTombstones {
+			cache.InvalidateRange(string(rt.Start), string(rt.End))
+		}
+		(*lsm)[0] = append((*lsm)[0], newSSTdir)
+		values.Commit()
+		memtable.ResetMemtable(mt)
+		for i := wal.FirstSeg; i < watermark; i++ {
+			deletePath := wal.GetSegmentFilename(i)
+			err := os.Remove(filepath.Join(wal.Dir, deletePath))
+			if err != nil {
+				fmt.Printf("Greška pri brisanju WAL segmenata")
+			}
+		}
+		wal.FirstSeg = max(wal.FirstSeg, watermark)
+		// Provera i izvršenje kompakcija po izabranoj strategiji. SSTabela je već upisana, pa greška
+		// kompakcije ne poništava flush - ulazne tabele ostaju u LSM stablu.
+		if err := strategy.MaybeCompact(lsm); err != nil {
+			fmt.Printf("Greška pri kompakciji: %v\n", err)
+		}
+		fmt.Println("SSTable uspešno kreiran!")
+	}, nil
+}
+
+// WriteToDisk upisuje zapise Memtable-a u novu SSTabelu (velike vrednosti u value log) i vraća
+// njen folder. Tabela još nije deo LSM stabla.
+func WriteToDisk(batch *memtable.FlushBatch, sstableDir string, bm *blockmanager.BlockManager, cfg config.Config,
+	dict *sstable.Dictionary, dictPath string, values *sstable.ValueLog) (string, error) {
+	// Velike vrednosti se upisuju u value log, a u SSTabelu idu samo pokazivači
+	if err := values.Separate(batch.Records); err != nil {
+		return "", err
+	}
+	_, newSSTdir, err := sstable.CreateSSTable(batch.Records, batch.RangeTombstones, sstableDir, cfg.SummaryStep, bm,
+		cfg.BlockSize, 0, cfg.SSTableSingleFile, cfg.SSTableCompression, dict, dictPath, sstable.NewFilterPolicy(cfg))
+	return newSSTdir, err
+}
+
+// ApplyRangeTombstone upisuje brisanje opsega [start, end) u aktivni (prvi) Memtable: ključevi iz
+// opsega koji su živi u nekom Memtable-u dobijaju tombstone, a sam opseg se pamti
+// da bi sakrio starije verzije ključeva sa diska. Tombstone početnog ključa upisuje pozivalac
+// (preko DeleteFromMemory), pa Memtable sa brisanjem opsega nikad nije prazan pri flush-u.
+func ApplyRangeTombstone(ts [16]byte, start, end string, memtables []memtable.MemtableInterface) {
+	keys := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, mt := range memtables {
+		c := mt.NewCursor()
+		for ok := c.Seek(start); ok && c.Key() < end; ok = c.Next() {
+			if !seen[c.Key()] {
+				seen[c.Key()] = true
+				if !c.Tombstone() {
+					keys = append(keys, c.Key())
 				}
 			}
-			wal.FirstSeg = watermark
-			fmt.Println("Prevodim sadržaj Memtable-a u SSTable")
-			sstrecords := memtable.ConvertMemToSST(&(*memtables)[*mtIndex])
-			return sstrecords
 		}
+		c.Close()
 	}
-	return nil
+	for _, key := range keys {
+		memtables[0].Delete(ts, key)
+	}
+	memtables[0].AddRangeTombstone(ts, start, end)
 }
 
-func WriteToDisk(sstrecords *[]sstable.Record, sstableDir string, bm *blockmanager.BlockManager,
-	lsm *map[byte][]string, cfg config.Config, dict *sstable.Dictionary, dictPath string) error {
-	_, newSSTdir, err := sstable.CreateSSTable(*sstrecords, sstableDir, cfg.SummaryStep, bm, cfg.BlockSize,
-		0, cfg.SSTableSingleFile, cfg.SSTableCompression, dict, dictPath)
-	if err != nil {
-		return err
-	}
-	(*lsm)[0] = append((*lsm)[0], newSSTdir)
-	// Funkcija za proveru i izvršenje kompakcija
-	switch cfg.CompactionAlgorithm {
-	case "SizeTiered":
-		err := sstable.SizeTieredCompaction(bm, lsm, sstableDir, cfg.MaxCountInLevel,
-			cfg.BlockSize, cfg.SummaryStep, cfg.SSTableSingleFile, cfg.SSTableCompression, dict, dictPath)
-		if err != nil {
-			return err
+// DeletedByRange proverava da li ključ briše neko brisanje opsega iz Memtable-a. Svi podaci
+// na disku su stariji od Memtable-a, pa takav ključ ne postoji ako ga nema u Memtable-ima.
+func DeletedByRange(key string, memtables []memtable.MemtableInterface) bool {
+	for _, mt := range memtables {
+		if sstable.CoveringTimestamp(mt.RangeTombstones(), []byte(key)) > 0 {
+			return true
 		}
-	case "Leveled":
-		err := sstable.LeveledCompaction(bm, lsm, sstableDir, cfg.MaxCountInLevel,
-			cfg.BlockSize, cfg.SummaryStep, cfg.SSTableSingleFile, cfg.SSTableCompression, dict, dictPath)
-		if err != nil {
-			return err
+	}
+	return false
+}
+
+// DiskRangeTombstones vraća brisanja opsega iz svih SSTabela koja obuhvataju bar jedan ključ iz
+// [minKey, maxKey]. Brisanja opsega su zapamćena u TableCache-u, pa se tabele ne otvaraju ponovo.
+func DiskRangeTombstones(lsm map[byte][]string, tables *sstable.TableCache, minKey, maxKey []byte) []sstable.RangeTombstone {
+	rts := make([]sstable.RangeTombstone, 0)
+	for _, level := range lsm {
+		for _, path := range level {
+			tableRts, err := tables.RangeTombstones(path)
+			if err != nil {
+				continue
+			}
+			for _, rt := range tableRts {
+				if rt.Overlaps(minKey, maxKey) {
+					rts = append(rts, rt)
+				}
+			}
 		}
 	}
-	fmt.Println("SSTable uspešno kreiran!")
-	return nil
+	return rts
 }
 
+// ReadFromDisk traži ključ u SSTabelama od najnovije ka najstarijoj: nivo 0 od poslednje
+// dodate tabele, pa niži nivoi redom. Prvi pronađeni zapis je ujedno i najnovija verzija ključa,
+// pa se pretraga tu završava (tombstone znači da ključ ne postoji). Odvojena vrednost se
+// čita iz value log-a.
 func ReadFromDisk(key string, maxLevel byte, lsm map[byte][]string, cfg config.Config,
-	bm *blockmanager.BlockManager, dict *sstable.Dictionary) *sstable.Record {
-	records := make([]*sstable.Record, 0)
-	for level := byte(0); level <= maxLevel; level++ {
-		sstableDirs, exists := lsm[level]
-		if !exists {
-			continue
-		}
+	bm *blockmanager.BlockManager, dict *sstable.Dictionary, tables *sstable.TableCache) *sstable.Record {
+	record := FindOnDisk(key, maxLevel, lsm, cfg, bm, dict, tables)
+	if record == nil || record.Tombstone {
+		return nil
+	}
+	record, err := tables.ResolveValue(record)
+	if err != nil {
+		fmt.Println("Greška u čitanju vrednosti iz value log-a")
+		return nil
+	}
+	return record
+}
 
-		for _, dir := range sstableDirs {
-			table, err := sstable.ReadTableFromDir(dir)
+// FindOnDisk vraća najnoviju verziju ključa na disku onakvu kakva je zapisana u SSTabeli
+// (tombstone ili pokazivač na vrednost u value log-u). Verzija obrisana novijim brisanjem
+// opsega iz bilo koje tabele vraća se kao tombstone.
+func FindOnDisk(key string, maxLevel byte, lsm map[byte][]string, cfg config.Config,
+	bm *blockmanager.BlockManager, dict *sstable.Dictionary, tables *sstable.TableCache) *sstable.Record {
+	for level := byte(0); level <= maxLevel; level++ {
+		sstableDirs := lsm[level]
+		// Kasnije dodate tabele na nivou sadrže novije podatke
+		for i := len(sstableDirs) - 1; i >= 0; i-- {
+			reader, err := tables.Get(sstableDirs[i])
 			if err != nil {
 				fmt.Println("Greška u čitanju foldera SSTabele")
+				continue
+			}
+			record, found, err := reader.Search([]byte(key), bm, cfg.BlockSize, cfg.SSTableCompression, dict)
+			if err != nil {
+				fmt.Println("Greška u pretrazi SSTabele")
+				continue
+			}
+			if found {
+				return sstable.ApplyRangeTombstones(record, DiskRangeTombstones(lsm, tables, []byte(key), []byte(key)))
+			}
+		}
+	}
+	return nil
+}
+
+// IsLiveValue proverava da li je unos value log-a i dalje najnovija vrednost svog ključa:
+// ključ ne sme imati noviju verziju u Memtable-ima, a najnoviji zapis na disku mora
+// pokazivati baš na taj unos
+func IsLiveValue(entry sstable.ValueLogEntry, memtables []memtable.MemtableInterface, lsm map[byte][]string,
+	cfg config.Config, bm *blockmanager.BlockManager, dict *sstable.Dictionary, tables *sstable.TableCache) bool {
+	key := string(entry.Key)
+	for _, mt := range memtables {
+		if _, _, found := mt.Get(key); found {
+			return false
+		}
+	}
+	if DeletedByRange(key, memtables) {
+		return false
+	}
+	maxLevel := byte(0)
+	for level := range lsm {
+		maxLevel = max(maxLevel, level)
+	}
+	record := FindOnDisk(key, maxLevel, lsm, cfg, bm, dict, tables)
+	if record == nil || !record.Separated {
+		return false
+	}
+	ptr, err := sstable.DecodeValuePointer(record.Value)
+	return err == nil && ptr == entry.Pointer
+}
+
+// Status ključa u rezultatu MultiGet-a
+type KeyStatus int
+
+const (
+	KeyMissing KeyStatus = iota
+	KeyFound
+	KeyDeleted
+)
+
+// MultiGetResult je rezultat pretrage jednog ključa
+type MultiGetResult struct {
+	Key    string
+	Value  []byte
+	Status KeyStatus
+}
+
+// MultiGet traži više ključeva u jednom prolazu: prvo Memtable-i i keš, a ključevi koji nisu
+// razrešeni se sortiraju i traže zajedno u svakoj SSTabeli (od najnovije ka najstarijoj),
+// tako da se svaki deo indexa čita najviše jednom po tabeli. Rezultati prate redosled ulaza.
+func MultiGet(keys []string, memtables []memtable.MemtableInterface, cache *lrucache.RowCache, lsm map[byte][]string,
+	cfg config.Config, bm *blockmanager.BlockManager, dict *sstable.Dictionary, tables *sstable.TableCache) []MultiGetResult {
+	resolved := make(map[string]MultiGetResult)
+	seen := make(map[string]bool)
+	pending := make([]string, 0)
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		// Memtable-i sadrže najnoviju verziju ključa
+		res := MultiGetResult{Key: key, Status: KeyMissing}
+		for _, mt := range memtables {
+			value, deleted, found := mt.Get(key)
+			if found && !deleted {
+				res = MultiGetResult{Key: key, Value: value, Status: KeyFound}
+			} else if found {
+				res.Status = KeyDeleted
 			}
-			record, found := sstable.SearchSSTable(table, key, cfg, bm, dict)
 			if found {
-				records = append(records, record)
-				// u leveled kompakciji podatak se pojavljuje samo jednom u nivou
-				// podatak u najvišem nivou je ujedno i najnoviji - možemo ga vratiti odmah
-				if cfg.CompactionAlgorithm == "Leveled" {
-					return record
+				break
+			}
+		}
+		if res.Status == KeyMissing && DeletedByRange(key, memtables) {
+			res.Status = KeyDeleted
+		}
+		if res.Status == KeyMissing {
+			if value, exists, cached := cache.Get(key); cached {
+				if exists {
+					res = MultiGetResult{Key: key, Value: value, Status: KeyFound}
 				}
+				resolved[key] = res
+				continue
 			}
 		}
+		if res.Status != KeyMissing {
+			resolved[key] = res
+			continue
+		}
+		pending = append(pending, key)
 	}
-	if len(records) == 0 {
-		return nil
+
+	slices.Sort(pending)
+	for _, rec := range ReadManyFromDisk(pending, lsm, cfg, bm, dict, tables) {
+		key := string(rec.Key)
+		if rec.Tombstone {
+			cache.PutMissing(key)
+			resolved[key] = MultiGetResult{Key: key, Status: KeyDeleted}
+			continue
+		}
+		rec, err := tables.ResolveValue(rec)
+		if err != nil {
+			fmt.Println("Greška u čitanju vrednosti iz value log-a")
+			resolved[key] = MultiGetResult{Key: key, Status: KeyMissing}
+			continue
+		}
+		cache.Put(key, rec.Value)
+		resolved[key] = MultiGetResult{Key: key, Value: rec.Value, Status: KeyFound}
 	}
-	retIndex := 0
-	for i, rec := range records {
-		// vraćamo zapis sa najnovijim timestampom
-		if binary.LittleEndian.Uint64(rec.Timestamp[:8]) > binary.LittleEndian.Uint64(records[retIndex].Timestamp[:8]) {
-			retIndex = i
+	// Ključevi kojih nema ni u jednoj tabeli
+	for _, key := range pending {
+		if _, ok := resolved[key]; !ok {
+			cache.PutMissing(key)
 		}
 	}
-	if records[retIndex].Tombstone {
-		return nil
+
+	results := make([]MultiGetResult, 0, len(keys))
+	for _, key := range keys {
+		res, ok := resolved[key]
+		if !ok {
+			res = MultiGetResult{Key: key, Status: KeyMissing}
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// ReadManyFromDisk traži sortirane ključeve u SSTabelama istim redosledom kao ReadFromDisk.
+// Ključ se izbacuje iz pretrage čim se pronađe; vraćeni zapisi mogu biti i tombstone-ovi
+// (uključujući zapise obrisane brisanjem opsega).
+func ReadManyFromDisk(keys []string, lsm map[byte][]string, cfg config.Config, bm *blockmanager.BlockManager,
+	dict *sstable.Dictionary, tables *sstable.TableCache) []*sstable.Record {
+	records := make([]*sstable.Record, 0, len(keys))
+	pending := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		pending = append(pending, []byte(key))
+	}
+	maxLevel := byte(0)
+	for level := range lsm {
+		maxLevel = max(maxLevel, level)
+	}
+	var rangeTombstones []sstable.RangeTombstone
+	if len(pending) > 0 {
+		rangeTombstones = DiskRangeTombstones(lsm, tables, pending[0], pending[len(pending)-1])
+	}
+	for level := byte(0); level <= maxLevel && len(pending) > 0; level++ {
+		sstableDirs := lsm[level]
+		for i := len(sstableDirs) - 1; i >= 0 && len(pending) > 0; i-- {
+			reader, err := tables.Get(sstableDirs[i])
+			if err != nil {
+				fmt.Println("Greška u čitanju foldera SSTabele")
+				continue
+			}
+			found, err := reader.SearchMany(pending, bm, cfg.BlockSize, cfg.SSTableCompression, dict)
+			if err != nil {
+				fmt.Println("Greška u pretrazi SSTabele")
+				continue
+			}
+			if len(found) == 0 {
+				continue
+			}
+			remaining := pending[:0]
+			for _, key := range pending {
+				if rec, ok := found[string(key)]; ok {
+					records = append(records, sstable.ApplyRangeTombstones(rec, rangeTombstones))
+				} else {
+					remaining = append(remaining, key)
+				}
+			}
+			pending = remaining
+		}
 	}
-	return records[retIndex]
+	return records
 }
 
 // Komande koje trose tokene (sve sem HELP i EXIT)
 var CommandsWithTokens = map[string]bool{
-	"GET": true, "PUT": true, "DELETE": true,
+	"GET": true, "PUT": true, "DELETE": true, "DELETE_RANGE": true, "DELETE_PREFIX": true,
 	"PREFIX_SCAN": true, "RANGE_SCAN": true,
 	"PREFIX_ITERATE": true, "RANGE_ITERATE": true,
+	"COUNT_PREFIX": true, "COUNT_RANGE": true,
+	"COMPACT": true, "LEVELS": true, "MGET": true, "FILTER_STATS": true, "CACHE_STATS": true,
+	"VLOG_GC": true, "VLOG_STATS": true,
 	"BLOOM_CREATE": true, "BLOOM_ADD": true, "BLOOM_CHECK": true,
 	"CMS_CREATE": true, "CMS_ADD": true, "CMS_COUNT": true,
 	"HLL_CREATE": true, "HLL_ADD": true, "HLL_COUNT": true,
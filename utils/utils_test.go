@@ -0,0 +1,214 @@
+package utils
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"projekat/config"
+	"projekat/structs/blockmanager"
+	"projekat/structs/containers"
+	"projekat/structs/memtable"
+	"projekat/structs/sstable"
+)
+
+const (
+	benchKeys     = 4000
+	benchTables   = 8
+	benchPerFlush = 1000
+)
+
+// benchLSM pravi LSM stablo od benchTables flush-eva na nivou 0; svaki flush sadrži novu verziju
+// nasumično izabranih ključeva, pa se opsezi tabela preklapaju. Ako je compacted true, sve tabele
+// se spajaju u jednu tabelu nivoa 1.
+func benchLSM(b *testing.B, compacted bool) (config.Config, *blockmanager.BlockManager, *sstable.Dictionary,
+	*sstable.TableCache, map[byte][]string) {
+	cfg := config.Config{
+		BlockSize:           512,
+		SummaryStep:         4,
+		SSTableSingleFile:   true,
+		BloomFPRates:        []float64{0.01},
+		CompactionAlgorithm: "SizeTiered",
+		MaxCountInLevel:     benchTables,
+		TableCacheSize:      64,
+	}
+	dir := b.TempDir()
+	bm := blockmanager.NewBlockManager(cfg.BlockSize, 16)
+	dict := sstable.NewDictionary()
+	dictPath := filepath.Join(dir, "dict.db")
+	tables := sstable.NewTableCache(cfg.TableCacheSize, bm, cfg.BlockSize, nil)
+	lsm := make(map[byte][]string)
+
+	r := rand.New(rand.NewSource(1))
+	for t := 0; t < benchTables; t++ {
+		chosen := make(map[int]bool)
+		for len(chosen) < benchPerFlush {
+			chosen[r.Intn(benchKeys)] = true
+		}
+		records := make([]sstable.Record, 0, len(chosen))
+		for i := range chosen {
+			value := []byte(fmt.Sprintf("verzija-%d", t))
+			records = append(records, sstable.Record{Key: []byte(fmt.Sprintf("user:%05d", i)), Value: value,
+				ValueSize: uint64(len(value)), Timestamp: [16]byte{byte(t + 1)}})
+		}
+		sort.Slice(records, func(i, j int) bool { return string(records[i].Key) < string(records[j].Key) })
+		_, sstDir, err := sstable.CreateSSTable(records, nil, dir, cfg.SummaryStep, bm, cfg.BlockSize, 0,
+			cfg.SSTableSingleFile, false, dict, dictPath, sstable.NewFilterPolicy(cfg))
+		if err != nil {
+			b.Fatal(err)
+		}
+		lsm[0] = append(lsm[0], sstDir)
+	}
+	if compacted {
+		strategy, err := sstable.NewCompactionStrategy(cfg, bm, dir, dict, dictPath, tables)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := strategy.CompactAll(&lsm); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return cfg, bm, dict, tables, lsm
+}
+
+// BenchmarkPointLookup meri broj blokova pročitanih sa diska po pretrazi ključa: pretraga od
+// najnovije tabele koja staje na prvom pogotku (ReadFromDisk) i, radi poređenja, pretraga koja
+// obilazi sve tabele kao pre. Kompakcija svodi preklapajuće tabele na jednu, pa se čita manje blokova.
+func BenchmarkPointLookup(b *testing.B) {
+	for _, compacted := range []bool{false, true} {
+		for _, mode := range []string{"newestFirst", "allTables"} {
+			b.Run(fmt.Sprintf("compacted=%v/%s", compacted, mode), func(b *testing.B) {
+				cfg, bm, dict, tables, lsm := benchLSM(b, compacted)
+				maxLevel := byte(0)
+				for level := range lsm {
+					maxLevel = max(maxLevel, level)
+				}
+				r := rand.New(rand.NewSource(2))
+				bm.BlocksRead = 0
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					key := fmt.Sprintf("user:%05d", r.Intn(benchKeys))
+					if mode == "newestFirst" {
+						ReadFromDisk(key, maxLevel, lsm, cfg, bm, dict, tables)
+						continue
+					}
+					for _, dirs := range lsm {
+						for _, dir := range dirs {
+							reader, err := tables.Get(dir)
+							if err != nil {
+								b.Fatal(err)
+							}
+							if _, _, err := reader.Search([]byte(key), bm, cfg.BlockSize, false, dict); err != nil {
+								b.Fatal(err)
+							}
+						}
+					}
+				}
+				b.ReportMetric(float64(bm.BlocksRead)/float64(b.N), "blocks/op")
+				b.ReportMetric(float64(bm.BlocksRead*cfg.BlockSize)/float64(b.N), "diskB/op")
+			})
+		}
+	}
+}
+
+// Pogodak na disku primenjuje brisanja opsega iz drugih tabela bez njihovog ponovnog otvaranja,
+// i kada je keš tabela premali da ih sve drži
+func TestFindOnDiskRangeTombstones(t *testing.T) {
+	cfg := config.Config{BlockSize: 512, SummaryStep: 4, SSTableSingleFile: true, BloomFPRates: []float64{0.01}}
+	dir := t.TempDir()
+	bm := blockmanager.NewBlockManager(cfg.BlockSize, 1)
+	dict := sstable.NewDictionary()
+	tables := sstable.NewTableCache(1, bm, cfg.BlockSize, nil)
+	lsm := make(map[byte][]string)
+	add := func(records []sstable.Record, rts []sstable.RangeTombstone) {
+		_, sstDir, err := sstable.CreateSSTable(records, rts, dir, cfg.SummaryStep, bm, cfg.BlockSize, 0,
+			cfg.SSTableSingleFile, false, dict, filepath.Join(dir, "dict.db"), sstable.NewFilterPolicy(cfg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lsm[0] = append(lsm[0], sstDir)
+	}
+	put := func(key string, at byte) sstable.Record {
+		return sstable.Record{Key: []byte(key), Value: []byte("v"), ValueSize: 1, Timestamp: [16]byte{at}}
+	}
+	add([]sstable.Record{put("a", 1), put("m", 1)}, nil)
+	add([]sstable.Record{{Key: []byte("b"), Tombstone: true, Timestamp: [16]byte{2}}},
+		[]sstable.RangeTombstone{{Start: []byte("b"), End: []byte("n"), Timestamp: [16]byte{2}}})
+	for i := 0; i < 6; i++ {
+		add([]sstable.Record{put(fmt.Sprintf("x%d", i), byte(3+i))}, nil)
+	}
+
+	lookup := func(key string) (*sstable.Record, int) {
+		before := bm.BlocksRead
+		rec := FindOnDisk(key, 0, lsm, cfg, bm, dict, tables)
+		return rec, bm.BlocksRead - before
+	}
+	if rec, _ := lookup("m"); rec == nil || !rec.Tombstone {
+		t.Fatalf("ključ obuhvaćen brisanjem opsega: %+v", rec)
+	}
+	if rec, _ := lookup("a"); rec == nil || rec.Tombstone {
+		t.Fatalf("ključ van brisanja opsega: %+v", rec)
+	}
+	// Pretraga otvara samo tabelu u kojoj je ključ pronađen, a ne sve tabele zbog brisanja opsega
+	lookup("a")
+	if _, reads := lookup("x5"); reads >= len(lsm[0]) {
+		t.Fatalf("pretraga je pročitala %d blokova za %d tabela", reads, len(lsm[0]))
+	}
+}
+
+// VLOG_GC ponovo upisuje samo unose value log-a koji su najnovija vrednost svog ključa: ključ
+// nema noviju verziju u Memtable-u niti brisanje opsega, a najnoviji zapis na disku pokazuje
+// baš na taj unos
+func TestIsLiveValue(t *testing.T) {
+	cfg := config.Config{BlockSize: 512, SummaryStep: 4, SSTableSingleFile: true, BloomFPRates: []float64{0.01}}
+	dir := t.TempDir()
+	bm := blockmanager.NewBlockManager(cfg.BlockSize, 16)
+	dict := sstable.NewDictionary()
+	tables := sstable.NewTableCache(4, bm, cfg.BlockSize, nil)
+	lsm := make(map[byte][]string)
+	values, err := sstable.OpenValueLog(filepath.Join(dir, "vlog"), bm, cfg.BlockSize, 32, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	add := func(records []sstable.Record) {
+		if err := values.Separate(records); err != nil {
+			t.Fatal(err)
+		}
+		_, sstDir, err := sstable.CreateSSTable(records, nil, dir, cfg.SummaryStep, bm, cfg.BlockSize, 0,
+			cfg.SSTableSingleFile, false, dict, filepath.Join(dir, "dict.db"), sstable.NewFilterPolicy(cfg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lsm[0] = append(lsm[0], sstDir)
+	}
+	put := func(key, value string, at byte) sstable.Record {
+		return sstable.Record{Key: []byte(key), Value: []byte(value), ValueSize: uint64(len(value)), Timestamp: [16]byte{at}}
+	}
+	big := func(key string, version int) string { return fmt.Sprintf("%s-%d-%040d", key, version, 0) }
+
+	add([]sstable.Record{put("a", big("a", 1), 1), put("b", big("b", 1), 1), put("c", big("c", 1), 1),
+		put("d", big("d", 1), 1), put("e", big("e", 1), 1)})
+	// Noviji zapisi na disku: c sa malom vrednošću u tabeli, e sa novim unosom u value log-u
+	add([]sstable.Record{put("c", "malo", 2), put("e", big("e", 2), 2)})
+	mt := containers.NewHashMapMemtable(10, 0)
+	mt.Add([16]byte{3}, false, "b", []byte("novo"))
+	mt.AddRangeTombstone([16]byte{3}, "d", "e")
+
+	entries, err := values.ReadSegment(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{big("a", 1): true, big("b", 1): false, big("c", 1): false, big("d", 1): false,
+		big("e", 1): false, big("e", 2): true}
+	if len(entries) != len(want) {
+		t.Fatalf("value log ima %d unosa, očekivano %d", len(entries), len(want))
+	}
+	for _, entry := range entries {
+		live := IsLiveValue(entry, []memtable.MemtableInterface{mt}, lsm, cfg, bm, dict, tables)
+		if live != want[string(entry.Value)] {
+			t.Fatalf("unos %s (%s): živ %v, očekivano %v", entry.Key, entry.Value, live, want[string(entry.Value)])
+		}
+	}
+}